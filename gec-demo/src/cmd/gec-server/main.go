@@ -4,9 +4,12 @@ package main
 
 import (
 	"os"
+	"os/signal"
+	"syscall"
 
-	"gec-demo/src/internal/print"
 	"gec-demo/src/internal/api"
+	"gec-demo/src/internal/gec"
+	"gec-demo/src/internal/print"
 )
 
 // Entry point for the GEC server binary.
@@ -18,5 +21,23 @@ func main() {
 		port = "8089"
 	}
 
+	// Reload the data files when they change on disk or on SIGHUP
+	if interval := gec.GetReloadInterval(); interval > 0 {
+		go gec.WatchDataDirs(interval, nil)
+	}
+	go reloadOnSignal()
+
 	api.StartServer(port)
 }
+
+// Reload the data files every time the process receives SIGHUP
+func reloadOnSignal() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		print.Info("Received SIGHUP, reloading data files")
+		if err := gec.ReloadData(); err != nil {
+			print.Error("%v", err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// src/cmd/langprofile/main.go
+// Builds the language detection profiles from one training text per language
+package main
+
+import (
+	"encoding/gob"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gec-demo/src/internal/langdetect"
+	"gec-demo/src/internal/print"
+)
+
+// Reads every `<code>.txt` in -corpus and writes the gob of n-gram profiles to -out
+func main() {
+	corpus := flag.String("corpus", "src/internal/langdetect/corpus", "Directory of <code>.txt training texts")
+	out := flag.String("out", "src/internal/langdetect/data/profiles.gob", "Profile file to write")
+	size := flag.Int("size", 2000, "Most common n-grams kept per language")
+	flag.Parse()
+
+	paths, err := filepath.Glob(filepath.Join(*corpus, "*.txt"))
+	if err != nil || len(paths) == 0 {
+		print.Critical("No training texts found in %q: %v", *corpus, err)
+		os.Exit(1)
+	}
+
+	profiles := make(map[string]map[string]float64)
+	for _, path := range paths {
+		text, err := os.ReadFile(path)
+		if err != nil {
+			print.Critical("%v", err)
+			os.Exit(1)
+		}
+		code := strings.TrimSuffix(filepath.Base(path), ".txt")
+		profiles[code] = langdetect.BuildProfile(string(text), *size)
+		print.Info("%s: %d n-grams", code, len(profiles[code])-1)
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		print.Critical("%v", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+	if err := gob.NewEncoder(file).Encode(profiles); err != nil {
+		print.Critical("Failed encoding profiles: %v", err)
+		os.Exit(1)
+	}
+}
@@ -1,12 +1,19 @@
 // src/internal/api/serve.go
-// routes + handlers (POST /api/gec, /healthCheck)
+// routes + handlers (POST /api/gec, POST /api/gec/batch, POST /api/gec/apply, POST /api/gec/document, /api/dictionaries, POST /api/admin/reload, /healthCheck)
 package api
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"gec-demo/src/internal/gec"
 	"gec-demo/src/internal/print"
@@ -16,8 +23,8 @@ import (
 func enableCORS(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -34,27 +41,63 @@ func healthCheck(w http.ResponseWriter, _ *http.Request) {
 	_, _ = w.Write([]byte("ok\n"))
 }
 
-// Endpoint: POST /api/gec
-func gecHandler(w http.ResponseWriter, r *http.Request) {
+// Checks the method & content type, then decodes the JSON body into `req`.
+// Writes the error response and returns false if the request is invalid.
+func decodeRequest(w http.ResponseWriter, r *http.Request, req any) bool {
 	// Only accept POST requests
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+		return false
 	}
+	return decodeBody(w, r, req)
+}
 
+// Checks the content type, then decodes the JSON body into `req`
+func decodeBody(w http.ResponseWriter, r *http.Request, req any) bool {
 	ct := r.Header.Get("Content-Type")
 	if ct == "" || !strings.HasPrefix(strings.ToLower(ct), "application/json") {
 		http.Error(w, "Content-Type must be application/json", http.StatusBadRequest)
-		return
+		return false
 	}
 
 	// Decode the request body
-	var req gec.GecRequest
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
 
-	if err := decoder.Decode(&req); err != nil {
+	if err := decoder.Decode(req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Request body is larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return false
+		}
 		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// Encode and send a JSON response
+func writeJSON(w http.ResponseWriter, response any) {
+	writeJSONStatus(w, http.StatusOK, response)
+}
+
+// Encode and send a JSON response with the status code
+func writeJSONStatus(w http.ResponseWriter, status int, response any) {
+	// Set response headers
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	// Encode and send response
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		print.Info("Error encoding response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// Endpoint: POST /api/gec
+func gecHandler(w http.ResponseWriter, r *http.Request) {
+	var req gec.GecRequest
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
@@ -64,21 +107,354 @@ func gecHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	opts, err := req.Resolve()
+	if err != nil {
+		optionsError(w, err)
+		return
+	}
+
+	// Stream results per sentence if the client asks for it
+	accept := strings.ToLower(r.Header.Get("Accept"))
+	stream := strings.Contains(accept, "text/event-stream") || strings.Contains(accept, "application/x-ndjson")
+	if stream && opts.Format != gec.FormatText {
+		http.Error(w, fmt.Sprintf("Streaming is only supported for format %q", gec.FormatText), http.StatusBadRequest)
+		return
+	}
+	switch {
+	case strings.Contains(accept, "text/event-stream"):
+		streamResponse(w, req.Text, opts, true)
+		return
+	case strings.Contains(accept, "application/x-ndjson"):
+		streamResponse(w, req.Text, opts, false)
+		return
+	}
+
 	// Process the grammar check
-	response, err := gec.MarkupGrammar(req.Text)
+	response, err := gec.MarkupGrammar(req.Text, opts)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error processing grammar: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Set response headers
-	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, response)
+}
 
-	// Encode and send response
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		print.Info("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+// Stream the grammar check as Server-Sent Events (sse=true) or newline delimited JSON
+func streamResponse(w http.ResponseWriter, text string, opts gec.CheckOptions, sse bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+
+	// Write one record and flush it to the client
+	emit := func(event any) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if sse {
+			name := "message"
+			switch event.(type) {
+			case gec.GecStreamSentence:
+				name = "sentence"
+			case gec.GecStreamSummary:
+				name = "summary"
+			case gec.GecStreamError:
+				name = "error"
+			}
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+		} else {
+			_, err = fmt.Fprintf(w, "%s\n", data)
+		}
+		flusher.Flush()
+		return err
+	}
+
+	if err := gec.MarkupGrammarStream(text, opts, emit); err != nil {
+		// Headers are already sent, so report the error as the last record
+		print.Error("Error streaming grammar: %v", err)
+		_ = emit(gec.GecStreamError{Event: "error", Error: fmt.Sprintf("Error processing grammar: %v", err)})
+	}
+}
+
+// Endpoint: POST /api/gec/batch
+func gecBatchHandler(w http.ResponseWriter, r *http.Request) {
+	var req gec.GecBatchRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	// Validate the request
+	if len(req.Documents) == 0 {
+		http.Error(w, "Documents field is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Documents) > gec.MaxBatchDocuments {
+		http.Error(w, fmt.Sprintf("Too many documents: %d > %d", len(req.Documents), gec.MaxBatchDocuments), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	opts, err := req.Resolve()
+	if err != nil {
+		optionsError(w, err)
+		return
+	}
+
+	// Process the grammar check. Errors are reported per document
+	response := gec.MarkupGrammarBatch(req.Documents, opts)
+	writeJSON(w, response)
+}
+
+// Endpoint: POST /api/gec/apply
+func applyHandler(w http.ResponseWriter, r *http.Request) {
+	var req gec.ApplyRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	// Apply the accepted markups and rebase the rest
+	text, markups, err := gec.ApplyMarkupsEncoded(req.Text, req.TextMarkups, req.AcceptedIDs, req.OffsetEncoding)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error applying markups: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	writeJSON(w, gec.ApplyResponse{Text: text, TextMarkups: markups})
+}
+
+// Endpoint: POST /api/gec/document
+// Checks a .docx or .odt file uploaded as multipart/form-data in the `file` field, with the request options as JSON in
+// the optional `options` field. Returns a report keyed by paragraph & run, or the .docx with the corrections as tracked changes
+func documentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Leave room for the other form fields around the file
+	r.Body = http.MaxBytesReader(w, r.Body, gec.MaxDocumentSize+1<<20)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Document is larger than %d bytes", gec.MaxDocumentSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Invalid multipart form: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "File field is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading file: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var req gec.GecOptions
+	if options := r.FormValue("options"); options != "" {
+		decoder := json.NewDecoder(strings.NewReader(options))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid options field: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	opts, err := req.Resolve()
+	if err != nil {
+		optionsError(w, err)
+		return
+	}
+	if opts.Format != gec.FormatText {
+		http.Error(w, "The format option is not supported for documents", http.StatusBadRequest)
+		return
+	}
+
+	switch output := r.FormValue("output"); output {
+	case "", "report":
+		response, err := gec.CheckOfficeDocument(data, opts)
+		if err != nil {
+			documentError(w, err)
+			return
+		}
+		writeJSON(w, response)
+
+	case gec.DocumentDocx:
+		corrected, err := gec.CorrectDocx(data, opts)
+		if err != nil {
+			documentError(w, err)
+			return
+		}
+		name := strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename)) + "-corrected.docx"
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.wordprocessingml.document")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+		_, _ = w.Write(corrected)
+
+	default:
+		http.Error(w, fmt.Sprintf("Unknown output %q. Valid outputs: report, docx", output), http.StatusBadRequest)
+	}
+}
+
+// Send the status code matching an error reading an uploaded document
+func documentError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, gec.ErrUnsupportedDocument):
+		status = http.StatusUnsupportedMediaType
+	case errors.Is(err, gec.ErrInvalidDocument):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, fmt.Sprintf("Error checking document: %v", err), status)
+}
+
+// Endpoint: GET, POST /api/dictionaries
+// Dictionary endpoints need the same bearer token as /api/admin/reload
+func dictionariesHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, gec.MaxDictionaryRequestSize)
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, gec.DictionaryListResponse{Dictionaries: gec.DictionaryNames()})
+
+	case http.MethodPost:
+		var req gec.DictionaryRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		words, err := gec.CreateDictionary(req.Name, req.Words)
+		if err != nil {
+			dictionaryError(w, err)
+			return
+		}
+		writeJSONStatus(w, http.StatusCreated, gec.DictionaryResponse{Name: req.Name, Words: words.Words()})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Endpoint: GET, DELETE /api/dictionaries/{name} & POST, DELETE /api/dictionaries/{name}/words
+func dictionaryHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, gec.MaxDictionaryRequestSize)
+
+	name, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/dictionaries/"), "/")
+	if sub != "" && sub != "words" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var words gec.CustomWords
+	var err error
+	switch {
+	case sub == "" && r.Method == http.MethodGet:
+		words, err = gec.GetDictionary(name)
+
+	case sub == "" && r.Method == http.MethodDelete:
+		if err = gec.DeleteDictionary(name); err != nil {
+			dictionaryError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+
+	case sub == "words" && (r.Method == http.MethodPost || r.Method == http.MethodDelete):
+		var req gec.DictionaryRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+		if r.Method == http.MethodPost {
+			words, err = gec.AddDictionaryWords(name, req.Words)
+		} else {
+			words, err = gec.RemoveDictionaryWords(name, req.Words)
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		dictionaryError(w, err)
+		return
+	}
+	writeJSON(w, gec.DictionaryResponse{Name: name, Words: words.Words()})
+}
+
+// Send the status code matching an error resolving the request options
+func optionsError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, gec.ErrUnsupportedLanguage) {
+		status = http.StatusUnprocessableEntity
+	}
+	http.Error(w, fmt.Sprintf("Invalid options: %v", err), status)
+}
+
+// Send the status code matching a dictionary error
+func dictionaryError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, gec.ErrDictionaryNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, gec.ErrDictionaryExists):
+		status = http.StatusConflict
+	case errors.Is(err, gec.ErrInvalidDictionary):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// Check the request sent GEC_ADMIN_TOKEN as a bearer token.
+// Writes the error response and returns false if admin endpoints are disabled or the token is wrong
+func authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := strings.TrimSpace(os.Getenv("GEC_ADMIN_TOKEN"))
+	if token == "" {
+		http.Error(w, "Admin endpoints are disabled", http.StatusNotFound)
+		return false
+	}
+	sent, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// Endpoint: POST /api/admin/reload
+// Reloads the data files from disk. Only enabled when GEC_ADMIN_TOKEN is set, and must be sent as a bearer token
+func reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	start := time.Now()
+	if err := gec.ReloadData(); err != nil {
+		http.Error(w, fmt.Sprintf("Error reloading data: %v", err), http.StatusInternalServerError)
+		return
 	}
+	writeJSON(w, gec.ReloadResponse{Status: "reloaded", ServiceTime: time.Since(start).Seconds()})
 }
 
 func StartServer(port string) {
@@ -91,6 +467,12 @@ func StartServer(port string) {
 
 	// Routes
 	http.HandleFunc("/api/gec", enableCORS(gecHandler))
+	http.HandleFunc("/api/gec/batch", enableCORS(gecBatchHandler))
+	http.HandleFunc("/api/gec/apply", enableCORS(applyHandler))
+	http.HandleFunc("/api/gec/document", enableCORS(documentHandler))
+	http.HandleFunc("/api/dictionaries", enableCORS(dictionariesHandler))
+	http.HandleFunc("/api/dictionaries/", enableCORS(dictionaryHandler))
+	http.HandleFunc("/api/admin/reload", enableCORS(reloadHandler))
 	http.HandleFunc("/healthCheck", enableCORS(healthCheck))
 
 	// Serve static webpage 
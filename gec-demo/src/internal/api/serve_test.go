@@ -0,0 +1,359 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gec-demo/src/internal/gec"
+)
+
+func TestGecHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		expCode     int
+	}{
+		{
+			name:        "Method not allowed",
+			method:      http.MethodGet,
+			contentType: "application/json",
+			body:        "",
+			expCode:     http.StatusMethodNotAllowed,
+		},
+		{
+			name:        "Wrong content type",
+			method:      http.MethodPost,
+			contentType: "text/plain",
+			body:        `{"text": "Hello world."}`,
+			expCode:     http.StatusBadRequest,
+		},
+		{
+			name:        "Unknown field",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"text": "Hello world.", "foo": 1}`,
+			expCode:     http.StatusBadRequest,
+		},
+		{
+			name:        "Empty text",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"text": "   "}`,
+			expCode:     http.StatusBadRequest,
+		},
+		{
+			name:        "Unsupported language",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"text": "we should go home.", "language": "tlh"}`,
+			expCode:     http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "Valid request",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"text": "we should go home."}`,
+			expCode:     http.StatusOK,
+		},
+		{
+			name:        "Explicit language",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"text": "we should go home.", "language": "EN"}`,
+			expCode:     http.StatusOK,
+		},
+		{
+			name:        "Markdown",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"text": "# Title\n\nwe should go **home**.", "format": "markdown"}`,
+			expCode:     http.StatusOK,
+		},
+		{
+			name:        "Unknown format",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"text": "we should go home.", "format": "rtf"}`,
+			expCode:     http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/gec", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+
+			gecHandler(rec, req)
+			if rec.Code != tt.expCode {
+				t.Fatalf("Status = %d, expected %d. Body: %s", rec.Code, tt.expCode, rec.Body.String())
+			}
+			if tt.expCode != http.StatusOK {
+				return
+			}
+
+			var resp gec.GecResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed decoding response: %v", err)
+			}
+			if resp.CorrectedText == "" {
+				t.Errorf("Response is missing the corrected text")
+			}
+			if resp.Language != gec.DefaultLanguage {
+				t.Errorf("Language = %q, expected %q", resp.Language, gec.DefaultLanguage)
+			}
+		})
+	}
+}
+
+func TestGecBatchHandler(t *testing.T) {
+	body := `{"documents": [{"id": "1", "text": "we should go."}, {"id": "2", "text": ""}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/gec/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	gecBatchHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, expected %d. Body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp gec.GecBatchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed decoding response: %v", err)
+	}
+	if len(resp.Results) != 2 {
+		t.Fatalf("Got %d results, expected 2", len(resp.Results))
+	}
+	if resp.Results[0].ID != "1" || resp.Results[0].GecResponse == nil || resp.Results[0].Error != "" {
+		t.Errorf("Document 1 should succeed, got %+v", resp.Results[0])
+	}
+	if resp.Results[1].ID != "2" || resp.Results[1].Error == "" {
+		t.Errorf("Document 2 should return an error, got %+v", resp.Results[1])
+	}
+}
+
+func TestGecHandlerStream(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/gec", strings.NewReader(`{"text": "we should go. It is late."}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+	rec := httptest.NewRecorder()
+
+	gecHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, expected %d. Body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, expected application/x-ndjson", ct)
+	}
+
+	// Every line is a record, ending with the summary
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Got %d records, expected 3. Body: %s", len(lines), rec.Body.String())
+	}
+	var summary gec.GecStreamSummary
+	if err := json.Unmarshal([]byte(lines[2]), &summary); err != nil {
+		t.Fatalf("Failed decoding summary: %v", err)
+	}
+	if summary.Event != "summary" || summary.CorrectedText == "" {
+		t.Errorf("Last record should be the summary, got %s", lines[2])
+	}
+}
+
+func TestGecHandlerStreamFormat(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/gec", strings.NewReader(`{"text": "we should go.", "format": "markdown"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	rec := httptest.NewRecorder()
+
+	gecHandler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Status = %d, expected %d. Body: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+}
+
+// A multipart form with a file & form fields
+func documentForm(t *testing.T, file []byte, fields map[string]string) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if file != nil {
+		fw, err := mw.CreateFormFile("file", "essay.docx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = fw.Write(file)
+	}
+	for name, value := range fields {
+		_ = mw.WriteField(name, value)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &body, mw.FormDataContentType()
+}
+
+func TestDocumentHandler(t *testing.T) {
+	var docx bytes.Buffer
+	zw := zip.NewWriter(&docx)
+	for name, content := range map[string]string{
+		"[Content_Types].xml":          `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"></Types>`,
+		"word/_rels/document.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"></Relationships>`,
+		"word/document.xml":            `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p><w:r><w:t>we should go home.</w:t></w:r></w:p></w:body></w:document>`,
+	} {
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte(content))
+	}
+	_ = zw.Close()
+
+	tests := []struct {
+		name        string
+		method      string
+		file        []byte
+		fields      map[string]string
+		expCode     int
+		contentType string
+	}{
+		{name: "Method not allowed", method: http.MethodGet, file: docx.Bytes(), expCode: http.StatusMethodNotAllowed},
+		{name: "Missing file", method: http.MethodPost, expCode: http.StatusBadRequest},
+		{name: "Not a document", method: http.MethodPost, file: []byte("plain text"), expCode: http.StatusUnsupportedMediaType},
+		{name: "Invalid options", method: http.MethodPost, file: docx.Bytes(), fields: map[string]string{"options": `{"foo": 1}`}, expCode: http.StatusBadRequest},
+		{name: "Format option", method: http.MethodPost, file: docx.Bytes(), fields: map[string]string{"options": `{"format": "html"}`}, expCode: http.StatusBadRequest},
+		{name: "Unknown output", method: http.MethodPost, file: docx.Bytes(), fields: map[string]string{"output": "pdf"}, expCode: http.StatusBadRequest},
+		{name: "Report", method: http.MethodPost, file: docx.Bytes(), fields: map[string]string{"options": `{"offset_encoding": "utf16"}`}, expCode: http.StatusOK, contentType: "application/json"},
+		{name: "Corrected docx", method: http.MethodPost, file: docx.Bytes(), fields: map[string]string{"output": "docx"}, expCode: http.StatusOK, contentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	}
+
+	for _, tt := range tests {
+		body, contentType := documentForm(t, tt.file, tt.fields)
+		req := httptest.NewRequest(tt.method, "/api/gec/document", body)
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+
+		documentHandler(rec, req)
+		if rec.Code != tt.expCode {
+			t.Errorf("%s: Status = %d, expected %d. Body: %s", tt.name, rec.Code, tt.expCode, rec.Body.String())
+			continue
+		}
+		if tt.contentType != "" && rec.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%s: Content-Type = %q, expected %q", tt.name, rec.Header().Get("Content-Type"), tt.contentType)
+		}
+	}
+}
+
+func TestDictionaryHandlers(t *testing.T) {
+	if err := gec.LoadDictionaries(t.TempDir()); err != nil {
+		t.Fatalf("LoadDictionaries() returned an error: %v", err)
+	}
+	t.Setenv("GEC_ADMIN_TOKEN", "secret")
+
+	tooMany := make([]string, gec.MaxDictionaryWords+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("word%c%c%c", 'a'+i%26, 'a'+i/26%26, 'a'+i/676%26)
+	}
+	tooManyBody, _ := json.Marshal(gec.DictionaryRequest{Words: tooMany})
+
+	// Steps run in order against the same store
+	steps := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		auth     string // Defaults to the admin token
+		expCode  int
+		expWords []string
+	}{
+		{name: "Missing token", method: http.MethodGet, path: "/api/dictionaries", auth: "-", expCode: http.StatusUnauthorized},
+		{name: "Wrong token", method: http.MethodPost, path: "/api/dictionaries", body: `{"name": "acme"}`, auth: "Bearer guess", expCode: http.StatusUnauthorized},
+		{name: "Wrong token for words", method: http.MethodDelete, path: "/api/dictionaries/acme", auth: "Bearer guess", expCode: http.StatusUnauthorized},
+		{name: "Create", method: http.MethodPost, path: "/api/dictionaries", body: `{"name": "acme", "words": ["Acmecorp"]}`, expCode: http.StatusCreated, expWords: []string{"Acmecorp"}},
+		{name: "Create duplicate", method: http.MethodPost, path: "/api/dictionaries", body: `{"name": "acme"}`, expCode: http.StatusConflict},
+		{name: "Invalid name", method: http.MethodPost, path: "/api/dictionaries", body: `{"name": "a/b"}`, expCode: http.StatusBadRequest},
+		{name: "Add words", method: http.MethodPost, path: "/api/dictionaries/acme/words", body: `{"words": ["Gizmo", "Widgetron"]}`, expCode: http.StatusOK, expWords: []string{"Acmecorp", "Gizmo", "Widgetron"}},
+		{name: "Too many words", method: http.MethodPost, path: "/api/dictionaries/acme/words", body: string(tooManyBody), expCode: http.StatusBadRequest},
+		{name: "Body too large", method: http.MethodPost, path: "/api/dictionaries/acme/words", body: `{"words": ["` + strings.Repeat("a", int(gec.MaxDictionaryRequestSize)) + `"]}`, expCode: http.StatusRequestEntityTooLarge},
+		{name: "Remove words", method: http.MethodDelete, path: "/api/dictionaries/acme/words", body: `{"words": ["gizmo"]}`, expCode: http.StatusOK, expWords: []string{"Acmecorp", "Widgetron"}},
+		{name: "Get", method: http.MethodGet, path: "/api/dictionaries/acme", expCode: http.StatusOK, expWords: []string{"Acmecorp", "Widgetron"}},
+		{name: "Unknown dictionary", method: http.MethodGet, path: "/api/dictionaries/other", expCode: http.StatusNotFound},
+		{name: "Unknown path", method: http.MethodGet, path: "/api/dictionaries/acme/other", expCode: http.StatusNotFound},
+		{name: "Delete", method: http.MethodDelete, path: "/api/dictionaries/acme", expCode: http.StatusNoContent},
+		{name: "Deleted", method: http.MethodGet, path: "/api/dictionaries/acme", expCode: http.StatusNotFound},
+	}
+
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		req.Header.Set("Content-Type", "application/json")
+		switch step.auth {
+		case "":
+			req.Header.Set("Authorization", "Bearer secret")
+		case "-":
+		default:
+			req.Header.Set("Authorization", step.auth)
+		}
+		rec := httptest.NewRecorder()
+
+		if step.path == "/api/dictionaries" {
+			dictionariesHandler(rec, req)
+		} else {
+			dictionaryHandler(rec, req)
+		}
+		if rec.Code != step.expCode {
+			t.Fatalf("%s: Status = %d, expected %d. Body: %s", step.name, rec.Code, step.expCode, rec.Body.String())
+		}
+		if rec.Code == http.StatusCreated && rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: Content-Type = %q, expected %q", step.name, rec.Header().Get("Content-Type"), "application/json")
+		}
+		if step.expWords == nil {
+			continue
+		}
+
+		var resp gec.DictionaryResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: Failed decoding response: %v", step.name, err)
+		}
+		if strings.Join(resp.Words, ",") != strings.Join(step.expWords, ",") {
+			t.Errorf("%s: Words = %v, expected %v", step.name, resp.Words, step.expWords)
+		}
+	}
+}
+
+func TestReloadHandler(t *testing.T) {
+	t.Setenv("GEC_DICTIONARY_DIR", t.TempDir())
+	t.Setenv("GEC_PROFANITY_DIR", t.TempDir())
+
+	tests := []struct {
+		name    string
+		token   string // GEC_ADMIN_TOKEN
+		auth    string
+		method  string
+		expCode int
+	}{
+		{name: "Disabled", token: "", auth: "Bearer secret", method: http.MethodPost, expCode: http.StatusNotFound},
+		{name: "Missing token", token: "secret", auth: "", method: http.MethodPost, expCode: http.StatusUnauthorized},
+		{name: "Wrong token", token: "secret", auth: "Bearer guess", method: http.MethodPost, expCode: http.StatusUnauthorized},
+		{name: "Wrong method", token: "secret", auth: "Bearer secret", method: http.MethodGet, expCode: http.StatusMethodNotAllowed},
+		{name: "Reload", token: "secret", auth: "Bearer secret", method: http.MethodPost, expCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("GEC_ADMIN_TOKEN", tt.token)
+		req := httptest.NewRequest(tt.method, "/api/admin/reload", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+
+		reloadHandler(rec, req)
+		if rec.Code != tt.expCode {
+			t.Errorf("%s: Status = %d, expected %d. Body: %s", tt.name, rec.Code, tt.expCode, rec.Body.String())
+		}
+	}
+}
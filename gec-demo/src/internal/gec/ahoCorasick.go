@@ -0,0 +1,120 @@
+// src/internal/gec/ahoCorasick.go
+package gec
+
+import (
+	"unicode"
+)
+
+// Aho-Corasick automaton over lowercase runes. Finds every occurrence of many words in one pass
+type ahoCorasick struct {
+	nodes []acNode
+	words []acWord
+}
+
+type acNode struct {
+	next map[rune]int32
+	fail int32
+	out  []int32 // Words ending at this node, including those reached through fail links
+}
+
+type acWord struct {
+	length   int  // Runes
+	wordHead bool // First rune is a word character
+	wordTail bool // Last rune is a word character
+	severity string
+}
+
+// Build the automaton. Words are matched case insensitively
+func newAhoCorasick(words map[string]string) *ahoCorasick {
+	ac := &ahoCorasick{nodes: []acNode{{next: map[rune]int32{}}}}
+
+	// Insert every word into the trie
+	for word, severity := range words {
+		runes := []rune(word)
+		if len(runes) == 0 {
+			continue
+		}
+		node := int32(0)
+		for _, r := range runes {
+			r = unicode.ToLower(r)
+			child, ok := ac.nodes[node].next[r]
+			if !ok {
+				child = int32(len(ac.nodes))
+				ac.nodes = append(ac.nodes, acNode{next: map[rune]int32{}})
+				ac.nodes[node].next[r] = child
+			}
+			node = child
+		}
+		ac.nodes[node].out = append(ac.nodes[node].out, int32(len(ac.words)))
+		ac.words = append(ac.words, acWord{
+			length:   len(runes),
+			wordHead: isWordRune(runes[0]),
+			wordTail: isWordRune(runes[len(runes)-1]),
+			severity: severity,
+		})
+	}
+
+	// Breadth first search to link each node to its longest proper suffix in the trie
+	queue := make([]int32, 0, len(ac.nodes))
+	for _, child := range ac.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for r, child := range ac.nodes[node].next {
+			fail := ac.nodes[node].fail
+			for {
+				if next, ok := ac.nodes[fail].next[r]; ok && next != child {
+					ac.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = ac.nodes[fail].fail
+			}
+			ac.nodes[child].out = append(ac.nodes[child].out, ac.nodes[ac.nodes[child].fail].out...)
+			queue = append(queue, child)
+		}
+	}
+	return ac
+}
+
+// Call `found` with the rune offsets of every match that sits on word boundaries,
+// the same way `\bword\b` would match
+func (ac *ahoCorasick) findAll(runes []rune, found func(start, end int, severity string)) {
+	node := int32(0)
+	for i, r := range runes {
+		r = unicode.ToLower(r)
+		for {
+			if next, ok := ac.nodes[node].next[r]; ok {
+				node = next
+				break
+			}
+			if node == 0 {
+				break
+			}
+			node = ac.nodes[node].fail
+		}
+
+		for _, w := range ac.nodes[node].out {
+			word := ac.words[w]
+			start, end := i+1-word.length, i+1
+			if atWordBoundary(runes, start-1, word.wordHead) && atWordBoundary(runes, end, word.wordTail) {
+				found(start, end, word.severity)
+			}
+		}
+	}
+}
+
+// Check for a `\b` between a match and the rune just outside it, given whether the edge of the match is a word character
+func atWordBoundary(runes []rune, outside int, insideIsWord bool) bool {
+	outsideIsWord := outside >= 0 && outside < len(runes) && isWordRune(runes[outside])
+	return insideIsWord != outsideIsWord
+}
+
+// Word characters as defined by `\b` in Go regexps (ASCII letters, digits & underscore)
+func isWordRune(r rune) bool {
+	return r <= unicode.MaxASCII && (r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+}
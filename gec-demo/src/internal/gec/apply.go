@@ -0,0 +1,156 @@
+// src/internal/gec/apply.go
+package gec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Give each markup a deterministic ID hashed from its offset, the original text it covers and its replacements.
+// The same fix on the same text always gets the same ID, so clients can use them for suppress-lists and analytics.
+func assignMarkupIDs(text string, markups []Markup) []Markup {
+	runes := []rune(text)
+	seen := make(map[string]int)
+	for i := range markups {
+		id := markupID(runes, markups[i])
+		seen[id]++
+		if seen[id] > 1 {
+			id = fmt.Sprintf("%s-%d", id, seen[id])
+		}
+		markups[i].ID = id
+	}
+	return markups
+}
+
+// Hash a markup's offset, original text and replacements into a short hex ID.
+// Replacements are sorted so reordering suggestions does not change the ID
+func markupID(runes []rune, m Markup) string {
+	start := min(max(m.Index, 0), len(runes))
+	end := min(max(m.Index+m.Length, start), len(runes))
+	repls := append([]string(nil), m.Replacements...)
+	sort.Strings(repls)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s", m.Index, string(runes[start:end]))
+	for _, r := range repls {
+		fmt.Fprintf(h, "\x00%s", r)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// Apply the first replacement of each accepted markup to the text, working right to left.
+// Returns the new text and the markups that were not applied, with their offsets rebased and IDs recomputed for the new text.
+// Markups overlapping an applied edit are dropped since they no longer point at their original text.
+func ApplyMarkups(text string, markups []Markup, acceptedIDs []string) (string, []Markup, error) {
+	runes := []rune(text)
+	textLen := len(runes)
+
+	byID := make(map[string]Markup, len(markups))
+	for _, m := range markups {
+		if m.ID == "" {
+			return "", nil, fmt.Errorf("markup at index %d is missing an id", m.Index)
+		}
+		if _, dup := byID[m.ID]; dup {
+			return "", nil, fmt.Errorf("duplicate markup id %q", m.ID)
+		}
+		byID[m.ID] = m
+	}
+
+	// Collect and validate the accepted edits
+	var accepted []Markup
+	acceptedSet := make(map[string]bool, len(acceptedIDs))
+	for _, id := range acceptedIDs {
+		m, ok := byID[id]
+		if !ok {
+			return "", nil, fmt.Errorf("accepted id %q does not match any markup", id)
+		}
+		if acceptedSet[id] {
+			continue
+		}
+		if len(m.Replacements) == 0 {
+			return "", nil, fmt.Errorf("markup %q has no replacement to apply", id)
+		}
+		if m.Index < 0 || m.Length < 0 || m.Index > textLen || (m.Index+m.Length > textLen && !(m.Index == textLen && m.Length == 1)) {
+			return "", nil, fmt.Errorf("markup %q (index %d, length %d) is stale: text has %d characters", id, m.Index, m.Length, textLen)
+		}
+		if markupID(runes, m) != strings.SplitN(id, "-", 2)[0] {
+			return "", nil, fmt.Errorf("markup %q is stale: it no longer matches the text", id)
+		}
+		acceptedSet[id] = true
+		accepted = append(accepted, m)
+	}
+
+	sort.Slice(accepted, func(i, j int) bool {
+		return accepted[i].Index < accepted[j].Index
+	})
+	for i := 1; i < len(accepted); i++ {
+		if markupEnd(accepted[i-1], textLen) > accepted[i].Index {
+			return "", nil, fmt.Errorf("accepted markups %q and %q overlap", accepted[i-1].ID, accepted[i].ID)
+		}
+	}
+
+	// Apply the edits right to left so earlier offsets stay valid
+	for i := len(accepted) - 1; i >= 0; i-- {
+		m := accepted[i]
+		end := markupEnd(m, textLen)
+		edited := make([]rune, 0, len(runes)+len(m.Replacements[0]))
+		edited = append(edited, runes[:m.Index]...)
+		edited = append(edited, []rune(m.Replacements[0])...)
+		edited = append(edited, runes[end:]...)
+		runes = edited
+	}
+
+	// Rebase the markups that were not applied
+	remaining := []Markup{}
+	for _, m := range markups {
+		if acceptedSet[m.ID] {
+			continue
+		}
+
+		shift := 0
+		overlaps := false
+		for _, a := range accepted {
+			aEnd := markupEnd(a, textLen)
+			switch {
+			case aEnd <= m.Index:
+				shift += len([]rune(a.Replacements[0])) - (aEnd - a.Index)
+			case a.Index < m.Index+m.Length:
+				overlaps = true
+			}
+		}
+		if overlaps {
+			continue
+		}
+		m.Index += shift
+		remaining = append(remaining, m)
+	}
+
+	newText := string(runes)
+	return newText, assignMarkupIDs(newText, remaining), nil
+}
+
+// Same as ApplyMarkups() with markup offsets in the given unit instead of runes
+func ApplyMarkupsEncoded(text string, markups []Markup, acceptedIDs []string, encoding string) (string, []Markup, error) {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding != "" && !contains(offsetEncodings, encoding) {
+		return "", nil, fmt.Errorf("unknown offset_encoding %q. Valid encodings: %s", encoding, strings.Join(offsetEncodings, ", "))
+	}
+
+	markups, err := decodeMarkupOffsets(text, markups, encoding)
+	if err != nil {
+		return "", nil, err
+	}
+	newText, remaining, err := ApplyMarkups(text, markups, acceptedIDs)
+	if err != nil {
+		return "", nil, err
+	}
+	return newText, encodeMarkupOffsets(newText, remaining, encoding), nil
+}
+
+// Rune offset where a markup ends, clamped to the text length for end-of-text markups
+func markupEnd(m Markup, textLen int) int {
+	return min(m.Index+m.Length, textLen)
+}
@@ -0,0 +1,137 @@
+package gec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyMarkups(t *testing.T) {
+	text := "we shood buy an car."
+	markups := []Markup{
+		{ID: "e6e9db5b473390cf", Index: 0, Length: 2, Category: CategoryGrammar, Replacements: []string{"We"}},
+		{ID: "812b95cbf6defc2d", Index: 3, Length: 5, Category: CategorySpelling, Replacements: []string{"should", "shod"}},
+		{ID: "0c8a0e993d829be1", Index: 13, Length: 2, Category: CategoryGrammar, Replacements: []string{"a"}},
+		{ID: "9dc1341366aa85e6", Index: 19, Length: 1, Category: CategoryGrammar, Replacements: []string{""}},
+	}
+
+	tests := []struct {
+		name     string
+		accepted []string
+		expText  string
+		expIDs   []string
+		expIndex []int
+		hasErr   bool
+	}{
+		{
+			name:     "Accept none",
+			accepted: nil,
+			expText:  text,
+			expIDs:   []string{"e6e9db5b473390cf", "812b95cbf6defc2d", "0c8a0e993d829be1", "9dc1341366aa85e6"},
+			expIndex: []int{0, 3, 13, 19},
+		},
+		{
+			name:     "Accept all",
+			accepted: []string{"0c8a0e993d829be1", "e6e9db5b473390cf", "812b95cbf6defc2d", "9dc1341366aa85e6"},
+			expText:  "We should buy a car",
+			expIDs:   []string{},
+			expIndex: []int{},
+		},
+		{
+			name:     "Rebase remaining markups",
+			accepted: []string{"812b95cbf6defc2d"},
+			expText:  "we should buy an car.",
+			expIDs:   []string{"e6e9db5b473390cf", "5286da05c3641f70", "3a809425702a55f8"},
+			expIndex: []int{0, 14, 20},
+		},
+		{
+			name:     "Unknown id",
+			accepted: []string{"1:1"},
+			hasErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, remaining, err := ApplyMarkups(text, markups, tt.accepted)
+			if tt.hasErr {
+				if err == nil {
+					t.Errorf("ApplyMarkups() should return an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyMarkups() returned an error: %v", err)
+			}
+			if result != tt.expText {
+				t.Errorf("\nResult: %q\nExpected: %q", result, tt.expText)
+			}
+
+			ids, inds := []string{}, []int{}
+			for _, m := range remaining {
+				ids = append(ids, m.ID)
+				inds = append(inds, m.Index)
+			}
+			if !reflect.DeepEqual(ids, tt.expIDs) || !reflect.DeepEqual(inds, tt.expIndex) {
+				t.Errorf("\nRemaining: %v %v\nExpected: %v %v", ids, inds, tt.expIDs, tt.expIndex)
+			}
+		})
+	}
+}
+
+func TestApplyMarkupsRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		idText  string // Text the IDs were generated from, when it differs
+		markups []Markup
+	}{
+		{
+			name: "Overlapping edits",
+			text: "we shood go",
+			markups: []Markup{
+				{Index: 0, Length: 5, Replacements: []string{"We s"}},
+				{Index: 3, Length: 5, Replacements: []string{"should"}},
+			},
+		},
+		{
+			name: "Stale edit",
+			text: "we",
+			markups: []Markup{
+				{Index: 3, Length: 5, Replacements: []string{"should"}},
+			},
+		},
+		{
+			name:   "Text changed",
+			text:   "we shood go",
+			idText: "we shoud go",
+			markups: []Markup{
+				{Index: 3, Length: 5, Replacements: []string{"should"}},
+			},
+		},
+		{
+			name: "No replacement",
+			text: "we shood go",
+			markups: []Markup{
+				{Index: 3, Length: 5, Category: CategoryProfanity, Replacements: []string{}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idText := tt.text
+			if tt.idText != "" {
+				idText = tt.idText
+			}
+			markups := assignMarkupIDs(idText, tt.markups)
+
+			var ids []string
+			for _, m := range markups {
+				ids = append(ids, m.ID)
+			}
+			if _, _, err := ApplyMarkups(tt.text, markups, ids); err == nil {
+				t.Errorf("ApplyMarkups() should return an error")
+			}
+		})
+	}
+}
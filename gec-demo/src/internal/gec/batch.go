@@ -0,0 +1,281 @@
+// src/internal/gec/batch.go
+package gec
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gec-demo/src/internal/print"
+)
+
+const (
+	// Private use character reserved for separating documents in a batch
+	batchMarker = '\uE000'
+
+	// Newline literal placed between documents sharing a work item.
+	// The native runtime passes newline literals through untouched, so it survives decoding.
+	batchSeparator = "\n" + string(batchMarker) + "\n"
+)
+
+var (
+	MaxBatchDocuments = 1000 // Maximum number of documents accepted in one batch request
+	MaxBatchTexts     = 500  // Maximum texts per work item (Matches MAX_BATCH_SIZE in config.h)
+)
+
+// A document in a batch that is ready to be sent to the model
+type batchDoc struct {
+	index     int
+	text      string // Normalized text
+	prose     string // Text of the document checked, without the markup of structured formats
+	doc       *proseDocument
+	norm      *textNormalization
+	opts      CheckOptions // Request options, in the document's detected language
+	detected  languageDetection
+	protected []protectedSpan // Protected spans of the normalized text
+	allTexts  []string
+	misspells []Misspell
+}
+
+// Run G.E.C. on many documents, sharing work items between them.
+// Errors are reported per document instead of failing the whole batch.
+func MarkupGrammarBatch(docs []GecBatchDocument, opts CheckOptions) *GecBatchResponse {
+	startTime := time.Now()
+	results := make([]GecBatchResult, len(docs))
+
+	// Clean, spell check and split each document into sentences
+	var ready []batchDoc
+	for i, doc := range docs {
+		results[i].ID = doc.ID
+
+		if strings.TrimSpace(doc.Text) == "" {
+			results[i].Error = "Text field is required"
+			continue
+		}
+		if strings.ContainsRune(doc.Text, batchMarker) {
+			results[i].Error = "Text contains the reserved character U+E000"
+			continue
+		}
+
+		// Each document is checked in its own detected language
+		prose, pdoc, proseOpts := prepareDocument(doc.Text, opts)
+		text, norm := normalizeText(prose)
+		docOpts, detected := proseOpts.detectLanguage(text)
+		protected := findProtectedSpans(text, docOpts)
+		misspells, err := FindMisspells(text, docOpts, protected)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+
+		bdoc := batchDoc{index: i, text: text, prose: prose, doc: pdoc, norm: norm, opts: docOpts, detected: detected, protected: protected, misspells: misspells}
+
+		if !docOpts.Grammar {
+			// Skip the model and only return the spelling errors
+			results[i].GecResponse, err = bdoc.finish(docs[i].Text, text, nil, 0, opts)
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+			continue
+		}
+
+		bdoc.allTexts = PreprocessText(text, docOpts, protected)
+		if len(bdoc.allTexts) <= 0 {
+			results[i].Error = "PreprocessText() returns an empty list"
+			continue
+		}
+		ready = append(ready, bdoc)
+	}
+
+	// Send every group of documents before waiting, so the channels stay busy
+	groups := groupBatchDocs(ready)
+	items := make([]WorkItem, len(groups))
+	sendErrs := make([]error, len(groups))
+	ranges := make([][]textRange, len(groups))
+	for g, group := range groups {
+		var allTexts []string
+		var spans [][]protectedSpan
+		allTexts, spans, ranges[g] = joinBatchTexts(group)
+		items[g], sendErrs[g] = SendWorkItem("", allTexts, spans, group[0].opts.Language, group[0].opts.Decoding)
+	}
+
+	for g, group := range groups {
+		corrected, sentences, gram_results, errs := waitBatchGroup(items[g], sendErrs[g], group, ranges[g])
+		for d, doc := range group {
+			res := &results[doc.index]
+			if errs[d] != nil {
+				res.Error = fmt.Sprintf("error running GEC, %v", errs[d])
+				continue
+			}
+
+			var err error
+			res.GecResponse, err = doc.finish(docs[doc.index].Text, corrected[d], sentences[d], gram_results[d].ServiceTime, opts)
+			if err != nil {
+				res.Error = err.Error()
+				continue
+			}
+			res.Decoding = narrowedDecoding(doc.opts.Decoding, gram_results[d])
+		}
+	}
+
+	return &GecBatchResponse{
+		Results:     results,
+		ServiceTime: time.Since(startTime).Seconds(),
+	}
+}
+
+// Build the response for a document from its corrected text and the model's correction of each of its sentences,
+// mapped back onto the original text
+func (doc batchDoc) finish(origText, corrected string, sentences []SentenceResult, serviceTime float64, opts CheckOptions) (*GecResponse, error) {
+	gec_result, err := BuildResponse(doc.text, corrected, sentences, doc.misspells, serviceTime, doc.opts, doc.protected)
+	if err != nil {
+		return nil, err
+	}
+	doc.norm.finishResponse(doc.prose, gec_result, doc.opts)
+	doc.detected.apply(gec_result)
+	doc.doc.finishResponse(origText, gec_result, opts)
+	return gec_result, nil
+}
+
+// Split documents into groups that fit in a single work item. Each group only holds documents in one language
+func groupBatchDocs(docs []batchDoc) (groups [][]batchDoc) {
+	// Keep the documents in order within each language
+	var codes []string
+	byLanguage := make(map[string][]batchDoc)
+	for _, doc := range docs {
+		code := doc.opts.Language.code()
+		if _, ok := byLanguage[code]; !ok {
+			codes = append(codes, code)
+		}
+		byLanguage[code] = append(byLanguage[code], doc)
+	}
+	for _, code := range codes {
+		groups = append(groups, groupLanguageDocs(byLanguage[code])...)
+	}
+	return groups
+}
+
+// Split documents in the same language into groups that fit in a single work item
+func groupLanguageDocs(docs []batchDoc) (groups [][]batchDoc) {
+	var current []batchDoc
+	total := 0
+	for _, doc := range docs {
+		if len(current) > 0 && total+len(doc.allTexts) > MaxBatchTexts {
+			groups = append(groups, current)
+			current = nil
+			total = 0
+		}
+		current = append(current, doc)
+		total += len(doc.allTexts)
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
+
+// Texts of one document in a work item shared by a batch, allTexts[start:end]
+type textRange struct {
+	start, end int
+}
+
+// Combine the texts of a group of documents with the batch separator between them, along with the protected spans
+// of each text and where each document's texts are. Separators merge into neighbouring newline literals so no two
+// newline literals are adjacent.
+func joinBatchTexts(group []batchDoc) (allTexts []string, spans [][]protectedSpan, ranges []textRange) {
+	for d, doc := range group {
+		texts := append([]string{}, doc.allTexts...)
+		if d > 0 {
+			last := len(allTexts) - 1
+			switch {
+			case strings.Contains(allTexts[last], "\n"):
+				allTexts[last] += batchSeparator
+			case strings.Contains(texts[0], "\n"):
+				texts[0] = batchSeparator + texts[0]
+			default:
+				allTexts = append(allTexts, batchSeparator)
+				spans = append(spans, nil)
+			}
+		}
+		ranges = append(ranges, textRange{start: len(allTexts), end: len(allTexts) + len(texts)})
+		allTexts = append(allTexts, texts...)
+		spans = append(spans, sentenceSpans(doc.text, doc.allTexts, doc.protected)...)
+	}
+	return allTexts, spans, ranges
+}
+
+// Wait for a group's result and split it back into one corrected text and the sentence results per document.
+// When the model loses a separator, each document is rebuilt from the corrections of its own sentences, and only
+// those it can't be rebuilt for are sent again on their own
+func waitBatchGroup(item WorkItem, sendErr error, group []batchDoc, ranges []textRange) ([]string, [][]SentenceResult, []*GrammarResult, []error) {
+	corrected := make([]string, len(group))
+	sentences := make([][]SentenceResult, len(group))
+	results := make([]*GrammarResult, len(group))
+	errs := make([]error, len(group))
+	if sendErr == nil {
+		results[0], sendErr = WaitWorkItem(item)
+	}
+	if sendErr != nil {
+		for d := range errs {
+			errs[d] = sendErr
+		}
+		return corrected, sentences, results, errs
+	}
+	gram_result := results[0]
+
+	split := strings.Split(gram_result.CorrectText, batchSeparator)
+	if len(split) == len(group) {
+		// Documents whose sentences can't be told apart are aligned with their corrected text instead
+		for d, r := range ranges {
+			corrected[d], results[d] = split[d], gram_result
+			sentences[d], _ = rangeSentences(gram_result.Sentences, len(item.AllTexts), r)
+		}
+		return corrected, sentences, results, errs
+	}
+	print.Warning("Batch result split into %d texts, expected %d. Splitting it by sentence instead", len(split), len(group))
+
+	var resend []int
+	for d, r := range ranges {
+		own, ok := rangeSentences(gram_result.Sentences, len(item.AllTexts), r)
+		if !ok {
+			resend = append(resend, d)
+			continue
+		}
+		corrected[d], sentences[d], results[d] = joinSentences(own), own, gram_result
+	}
+
+	// Send every document again before waiting, so the channels stay busy
+	items := make([]WorkItem, len(resend))
+	sendErrs := make([]error, len(resend))
+	for k, d := range resend {
+		doc := group[d]
+		print.Warning("Sending document %d of the batch again on its own", doc.index)
+		items[k], sendErrs[k] = SendWorkItem("", doc.allTexts, sentenceSpans(doc.text, doc.allTexts, doc.protected), doc.opts.Language, doc.opts.Decoding)
+	}
+	for k, d := range resend {
+		results[d], errs[d] = nil, sendErrs[k]
+		if errs[d] == nil {
+			results[d], errs[d] = WaitWorkItem(items[k])
+		}
+		if errs[d] == nil {
+			corrected[d], sentences[d] = results[d].CorrectText, results[d].Sentences
+		}
+	}
+	return corrected, sentences, results, errs
+}
+
+// The model's correction of each text in `r`, without the separators merged into the newline literals at its ends.
+// Returns false when the model corrected them together with texts outside `r` or the separator ended up in them
+func rangeSentences(sentences []SentenceResult, numTexts int, r textRange) ([]SentenceResult, bool) {
+	if len(sentences) != numTexts || sentences[r.start].Merged || (r.end < numTexts && sentences[r.end].Merged) {
+		return nil, false
+	}
+	own := append([]SentenceResult{}, sentences[r.start:r.end]...)
+	for i := range own {
+		own[i].Text = strings.ReplaceAll(own[i].Text, batchSeparator, "")
+		if strings.ContainsRune(own[i].Text, batchMarker) {
+			return nil, false
+		}
+	}
+	return own, true
+}
@@ -0,0 +1,71 @@
+// src/internal/gec/confidence.go
+package gec
+
+import "math"
+
+// What to do with grammar suggestions below a request's min_confidence
+const (
+	LowConfidenceHide = "hide"
+	LowConfidenceHint = "hint"
+)
+
+var lowConfidenceModes = []string{LowConfidenceHide, LowConfidenceHint}
+
+// Score changes to a corrected text by the tokens the model generated it from: the geometric mean of the
+// probabilities of the tokens a change covers, or of the tokens touching the point where text was removed.
+// Returns nil when the text wasn't scored
+func tokenScorer(corrected string, tokens []TokenScore) func(start, end int) *float64 {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	// Byte offset of each rune of the corrected text, and of its end
+	offsets := make([]int, 0, len(corrected)+1)
+	for i := range corrected {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(corrected))
+
+	return func(start, end int) *float64 {
+		if start < 0 || end < start || end >= len(offsets) {
+			return nil
+		}
+		startByte, endByte := offsets[start], offsets[end]
+
+		sum, count := 0.0, 0
+		for _, tok := range tokens {
+			covers := tok.Start < endByte && startByte < tok.End
+			if startByte == endByte {
+				covers = tok.Start <= startByte && startByte <= tok.End
+			}
+			if covers {
+				sum += tok.LogProb
+				count++
+			}
+		}
+		if count == 0 {
+			return nil
+		}
+		confidence := math.Round(math.Exp(sum/float64(count))*1000) / 1000
+		return &confidence
+	}
+}
+
+// Hide the grammar suggestions the model is less confident in than the request's min_confidence, or mark them
+// as hints. Suggestions without a confidence are kept as they are
+func (opts CheckOptions) filterConfidence(differences []Markup) []Markup {
+	if opts.MinConfidence <= 0 {
+		return differences
+	}
+	kept := differences[:0]
+	for _, diff := range differences {
+		if diff.Confidence != nil && *diff.Confidence < opts.MinConfidence {
+			if opts.LowConfidence != LowConfidenceHint {
+				continue
+			}
+			diff.Hint = true
+		}
+		kept = append(kept, diff)
+	}
+	return kept
+}
@@ -0,0 +1,85 @@
+// src/internal/gec/corrector.go
+package gec
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Corrector is an inference backend that grammatically corrects a batch of texts from PreprocessText()
+type Corrector interface {
+	// Correct the sentences & newline literals and return the joined corrected text.
+	// Backends without a decoder ignore the decoding options
+	Correct(allTexts []string, opts DecodeOptions) GrammarResult
+
+	// Free any resources held by the backend
+	Close()
+}
+
+// Creates a Corrector bound to the given device
+type NewCorrectorFunc func(gpuId int) (Corrector, error)
+
+var correctorBackends = map[string]NewCorrectorFunc{}
+
+// Register a Corrector backend under a name so it can be selected at startup
+func RegisterCorrector(name string, newFunc NewCorrectorFunc) bool {
+	correctorBackends[name] = newFunc
+	return true
+}
+
+// Names of all registered Corrector backends
+func CorrectorNames() []string {
+	names := make([]string, 0, len(correctorBackends))
+	for name := range correctorBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Create a new Corrector from a registered backend name
+func NewCorrector(name string, gpuId int) (Corrector, error) {
+	newFunc, ok := correctorBackends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown GEC backend %q. Available backends: %s", name, strings.Join(CorrectorNames(), ", "))
+	}
+	return newFunc(gpuId)
+}
+
+// Reads GEC_BACKEND from env. Falls back to the native "geco" backend when it is compiled in, otherwise "rules"
+func GetBackendName() string {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("GEC_BACKEND")))
+	if name != "" {
+		return name
+	}
+	if _, ok := correctorBackends["geco"]; ok {
+		return "geco"
+	}
+	return "rules"
+}
+
+// Join the corrections of each sentence into the corrected text
+func joinSentences(sentences []SentenceResult) string {
+	var texts []string
+	for _, sent := range sentences {
+		if !sent.Merged {
+			texts = append(texts, sent.Text)
+		}
+	}
+	return joinTexts(texts)
+}
+
+// Join corrected texts the same way the native decoder does:
+// sentences are separated by a space, newline literals are appended without surrounding spaces
+func joinTexts(allTexts []string) string {
+	var out strings.Builder
+	for i, t := range allTexts {
+		if i > 0 && !strings.Contains(t, "\n") && !strings.Contains(allTexts[i-1], "\n") {
+			out.WriteString(" ")
+		}
+		out.WriteString(t)
+	}
+	return out.String()
+}
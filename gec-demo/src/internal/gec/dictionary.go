@@ -0,0 +1,267 @@
+// src/internal/gec/dictionary.go
+package gec
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	MaxDictionaryWords             = 10000   // Maximum number of words in one custom dictionary
+	MaxDictionaryRequestSize int64 = 1 << 20 // Maximum size of a dictionary request body
+)
+
+var (
+	ErrDictionaryNotFound = errors.New("dictionary not found")
+	ErrDictionaryExists   = errors.New("dictionary already exists")
+	ErrInvalidDictionary  = errors.New("invalid dictionary")
+
+	dictNameRe   = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`) // Dictionary names double as file names
+	dictionaries = &dictionaryStore{dir: GetDictionaryDir(), dicts: map[string]CustomWords{}}
+)
+
+// Words a tenant added to their own dictionary. Lookups are case insensitive.
+// Never modified once created, so it is safe to share between requests
+type CustomWords map[string]string // Lowercase word -> word as it was added
+
+// Check if the dictionary contains a word. Safe to call on a nil dictionary
+func (cw CustomWords) Has(word string) bool {
+	_, ok := cw[strings.ToLower(word)]
+	return ok
+}
+
+// Words in the dictionary in sorted order
+func (cw CustomWords) Words() []string {
+	words := make([]string, 0, len(cw))
+	for _, w := range cw {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// Named custom dictionaries, each saved as a text file with one word per line
+type dictionaryStore struct {
+	mu    sync.RWMutex
+	dir   string
+	dicts map[string]CustomWords
+}
+
+// Reads GEC_DICTIONARY_DIR from env. Defaults to "dictionaries" in the working directory
+func GetDictionaryDir() string {
+	if dir := strings.TrimSpace(os.Getenv("GEC_DICTIONARY_DIR")); dir != "" {
+		return dir
+	}
+	return "dictionaries"
+}
+
+// Load every saved dictionary from `dir` and save future changes there.
+// A missing directory is created on the first change. The lock is held while reading, so a change
+// saved during a reload is never swapped out for the file's older contents
+func LoadDictionaries(dir string) error {
+	dictionaries.mu.Lock()
+	defer dictionaries.mu.Unlock()
+
+	dicts := make(map[string]CustomWords)
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return fmt.Errorf("failed listing dictionaries: %w", err)
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".txt")
+		if !dictNameRe.MatchString(name) {
+			continue
+		}
+		words, err := readDictionaryFile(path)
+		if err != nil {
+			return err
+		}
+		dicts[name] = words
+	}
+
+	dictionaries.dir = dir
+	dictionaries.dicts = dicts
+	return nil
+}
+
+// Read a dictionary file in the same format as data/spelling_custom.txt
+func readDictionaryFile(path string) (CustomWords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening dictionary file: %w", err)
+	}
+	defer file.Close()
+
+	words := make(CustomWords)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+
+		// Ignore blank lines or those starting with `#`
+		if strings.HasPrefix(word, "#") || word == "" {
+			continue
+		}
+		if validStr.MatchString(word) {
+			words[strings.ToLower(word)] = word
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed scanning dictionary file: %w", err)
+	}
+	return words, nil
+}
+
+// Names of all custom dictionaries
+func DictionaryNames() []string {
+	dictionaries.mu.RLock()
+	defer dictionaries.mu.RUnlock()
+
+	names := make([]string, 0, len(dictionaries.dicts))
+	for name := range dictionaries.dicts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get a custom dictionary by name
+func GetDictionary(name string) (CustomWords, error) {
+	dictionaries.mu.RLock()
+	defer dictionaries.mu.RUnlock()
+
+	words, ok := dictionaries.dicts[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrDictionaryNotFound, name)
+	}
+	return words, nil
+}
+
+// Create a new custom dictionary, optionally with some words
+func CreateDictionary(name string, words []string) (CustomWords, error) {
+	if !dictNameRe.MatchString(name) {
+		return nil, fmt.Errorf("%w: name %q must be 1-64 letters, digits, '-' or '_'", ErrInvalidDictionary, name)
+	}
+	if err := validateWords(words); err != nil {
+		return nil, err
+	}
+
+	dictionaries.mu.Lock()
+	defer dictionaries.mu.Unlock()
+	if _, ok := dictionaries.dicts[name]; ok {
+		return nil, fmt.Errorf("%w: %q", ErrDictionaryExists, name)
+	}
+	return dictionaries.save(name, CustomWords{}, words, nil)
+}
+
+// Delete a custom dictionary and its saved file
+func DeleteDictionary(name string) error {
+	dictionaries.mu.Lock()
+	defer dictionaries.mu.Unlock()
+	if _, ok := dictionaries.dicts[name]; !ok {
+		return fmt.Errorf("%w: %q", ErrDictionaryNotFound, name)
+	}
+
+	err := os.Remove(dictionaries.path(name))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed removing dictionary file: %w", err)
+	}
+	delete(dictionaries.dicts, name)
+	return nil
+}
+
+// Add words to a custom dictionary
+func AddDictionaryWords(name string, words []string) (CustomWords, error) {
+	if err := validateWords(words); err != nil {
+		return nil, err
+	}
+
+	dictionaries.mu.Lock()
+	defer dictionaries.mu.Unlock()
+	current, ok := dictionaries.dicts[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrDictionaryNotFound, name)
+	}
+	return dictionaries.save(name, current, words, nil)
+}
+
+// Remove words from a custom dictionary. Words it doesn't contain are ignored
+func RemoveDictionaryWords(name string, words []string) (CustomWords, error) {
+	dictionaries.mu.Lock()
+	defer dictionaries.mu.Unlock()
+	current, ok := dictionaries.dicts[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrDictionaryNotFound, name)
+	}
+	return dictionaries.save(name, current, nil, words)
+}
+
+// Check every word can be added to a dictionary
+func validateWords(words []string) error {
+	for _, word := range words {
+		if !validStr.MatchString(strings.TrimSpace(word)) {
+			return fmt.Errorf("%w: word %q may only contain letters, spaces and hyphens", ErrInvalidDictionary, word)
+		}
+	}
+	return nil
+}
+
+func (ds *dictionaryStore) path(name string) string {
+	return filepath.Join(ds.dir, name+".txt")
+}
+
+// Copy the dictionary with the changes, write it to disk, then swap it in.
+// Must be called with the lock held
+func (ds *dictionaryStore) save(name string, current CustomWords, add, remove []string) (CustomWords, error) {
+	updated := make(CustomWords, len(current)+len(add))
+	for k, v := range current {
+		updated[k] = v
+	}
+	for _, word := range add {
+		word = strings.TrimSpace(word)
+		updated[strings.ToLower(word)] = word
+	}
+	for _, word := range remove {
+		delete(updated, strings.ToLower(strings.TrimSpace(word)))
+	}
+	if len(updated) > MaxDictionaryWords {
+		return nil, fmt.Errorf("%w: more than %d words", ErrInvalidDictionary, MaxDictionaryWords)
+	}
+
+	if err := os.MkdirAll(ds.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed creating dictionary directory: %w", err)
+	}
+
+	// Write to a temp file and rename it so a crash never leaves a partial dictionary
+	tmp, err := os.CreateTemp(ds.dir, name+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed creating dictionary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	fmt.Fprintf(w, "# Custom dictionary %q\n", name)
+	for _, word := range updated.Words() {
+		fmt.Fprintln(w, word)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed writing dictionary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed writing dictionary file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), ds.path(name)); err != nil {
+		return nil, fmt.Errorf("failed saving dictionary file: %w", err)
+	}
+
+	ds.dicts[name] = updated
+	return updated, nil
+}
@@ -0,0 +1,172 @@
+package gec
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDictionaries(t *testing.T) {
+	dir := t.TempDir()
+	if err := LoadDictionaries(dir); err != nil {
+		t.Fatalf("LoadDictionaries() returned an error: %v", err)
+	}
+
+	if _, err := CreateDictionary("acme", []string{"Acmecorp", "Widgetron"}); err != nil {
+		t.Fatalf("CreateDictionary() returned an error: %v", err)
+	}
+	if _, err := AddDictionaryWords("acme", []string{"Gizmo"}); err != nil {
+		t.Fatalf("AddDictionaryWords() returned an error: %v", err)
+	}
+	if _, err := RemoveDictionaryWords("acme", []string{"widgetron"}); err != nil {
+		t.Fatalf("RemoveDictionaryWords() returned an error: %v", err)
+	}
+
+	// Reload from disk to check the changes were saved
+	if err := LoadDictionaries(dir); err != nil {
+		t.Fatalf("LoadDictionaries() returned an error: %v", err)
+	}
+	words, err := GetDictionary("acme")
+	if err != nil {
+		t.Fatalf("GetDictionary() returned an error: %v", err)
+	}
+	expected := []string{"Acmecorp", "Gizmo"}
+	if !reflect.DeepEqual(words.Words(), expected) {
+		t.Errorf("\nWords: %v\nExpected: %v", words.Words(), expected)
+	}
+	if !words.Has("ACMECORP") || words.Has("Widgetron") {
+		t.Errorf("Has() should be case insensitive and reflect removed words")
+	}
+
+	errTests := []struct {
+		name   string
+		run    func() error
+		expErr error
+	}{
+		{
+			name:   "Duplicate name",
+			run:    func() error { _, err := CreateDictionary("acme", nil); return err },
+			expErr: ErrDictionaryExists,
+		},
+		{
+			name:   "Invalid name",
+			run:    func() error { _, err := CreateDictionary("../acme", nil); return err },
+			expErr: ErrInvalidDictionary,
+		},
+		{
+			name:   "Invalid word",
+			run:    func() error { _, err := AddDictionaryWords("acme", []string{"v2.0"}); return err },
+			expErr: ErrInvalidDictionary,
+		},
+		{
+			name:   "Unknown dictionary",
+			run:    func() error { _, err := AddDictionaryWords("other", []string{"word"}); return err },
+			expErr: ErrDictionaryNotFound,
+		},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, tt.expErr) {
+				t.Errorf("Error = %v, expected %v", err, tt.expErr)
+			}
+		})
+	}
+
+	if err := DeleteDictionary("acme"); err != nil {
+		t.Fatalf("DeleteDictionary() returned an error: %v", err)
+	}
+	if len(DictionaryNames()) != 0 {
+		t.Errorf("Dictionaries left after delete: %v", DictionaryNames())
+	}
+}
+
+func TestDictionariesReloadWhileSaving(t *testing.T) {
+	dir := t.TempDir()
+	if err := LoadDictionaries(dir); err != nil {
+		t.Fatalf("LoadDictionaries() returned an error: %v", err)
+	}
+	if _, err := CreateDictionary("acme", nil); err != nil {
+		t.Fatalf("CreateDictionary() returned an error: %v", err)
+	}
+
+	// Reload as the watcher would while words are being added
+	stop := make(chan struct{})
+	reloaded := make(chan struct{})
+	go func() {
+		defer close(reloaded)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := LoadDictionaries(dir); err != nil {
+				t.Errorf("LoadDictionaries() returned an error: %v", err)
+				return
+			}
+		}
+	}()
+
+	var expected []string
+	for _, word := range []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel"} {
+		if _, err := AddDictionaryWords("acme", []string{word}); err != nil {
+			t.Fatalf("AddDictionaryWords() returned an error: %v", err)
+		}
+		expected = append(expected, word)
+	}
+	close(stop)
+	<-reloaded
+
+	words, err := GetDictionary("acme")
+	if err != nil {
+		t.Fatalf("GetDictionary() returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(words.Words(), expected) {
+		t.Errorf("\nWords: %v\nExpected: %v", words.Words(), expected)
+	}
+}
+
+func TestSpellCheckerDictionary(t *testing.T) {
+	if err := LoadDictionaries(t.TempDir()); err != nil {
+		t.Fatalf("LoadDictionaries() returned an error: %v", err)
+	}
+	if _, err := CreateDictionary("acme", []string{"acmecorp"}); err != nil {
+		t.Fatalf("CreateDictionary() returned an error: %v", err)
+	}
+
+	off := false
+	text := "Acmecorp makes teh best tools."
+	tests := []struct {
+		name     string
+		options  GecOptions
+		expected []int // Indexes of the spelling markups
+	}{
+		{name: "Shared dictionary", options: GecOptions{Grammar: &off}, expected: []int{0, 15}},
+		{name: "Custom dictionary", options: GecOptions{Grammar: &off, Dictionary: "acme"}, expected: []int{15}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := tt.options.Resolve()
+			if err != nil {
+				t.Fatalf("Resolve() returned an error: %v", err)
+			}
+			result, err := MarkupGrammar(text, opts)
+			if err != nil {
+				t.Fatalf("MarkupGrammar() returned an error: %v", err)
+			}
+
+			var inds []int
+			for _, m := range result.TextMarkups {
+				inds = append(inds, m.Index)
+			}
+			if !reflect.DeepEqual(inds, tt.expected) {
+				t.Errorf("\nIndexes: %v\nExpected: %v", inds, tt.expected)
+			}
+		})
+	}
+
+	if _, err := (GecOptions{Dictionary: "missing"}).Resolve(); !errors.Is(err, ErrDictionaryNotFound) {
+		t.Errorf("Resolve() should fail for an unknown dictionary, got %v", err)
+	}
+}
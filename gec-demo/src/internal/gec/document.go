@@ -0,0 +1,244 @@
+// src/internal/gec/document.go
+package gec
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"gec-demo/src/internal/print"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Formats a request's text can be in. Only the prose of structured formats is checked
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+var documentFormats = []string{FormatText, FormatMarkdown, FormatHTML}
+
+// Separator between blocks of prose, so sentences never run from one block into the next
+const blockSeparator = "\n\n"
+
+// The prose extracted from a structured document, with a map from every prose rune back to the raw text.
+// Each rune covers a range of raw bytes, so an escape like `\*` or `&amp;` is a single prose rune
+type proseDocument struct {
+	raw      string
+	prose    []rune
+	rawStart []int // Byte offsets into the raw text of each prose rune. -1 for separators & spaces added by the parser
+	rawEnd   []int
+
+	escape func(string) string // Escapes text inserted into the raw document
+}
+
+// Extract the prose from a document in one of the structured formats
+func parseDocument(text, format string) *proseDocument {
+	switch format {
+	case FormatMarkdown:
+		return parseMarkdown(text)
+	case FormatHTML:
+		return parseHTML(text)
+	}
+	return nil
+}
+
+// Split a request's text into the prose to check and the options to check it with.
+// Plain text is returned as is with a nil document
+func prepareDocument(text string, opts CheckOptions) (string, *proseDocument, CheckOptions) {
+	doc := parseDocument(text, opts.Format)
+	if doc == nil {
+		return text, nil, opts
+	}
+
+	// Markups are mapped back onto the document in runes, then converted to the requested unit
+	opts.Format = FormatText
+	opts.OffsetEncoding = OffsetRunes
+
+	// Documents without any prose, like a lone code block, have nothing for the model to correct
+	prose := string(doc.prose)
+	if strings.TrimSpace(prose) == "" {
+		opts.Grammar = false
+	}
+	return prose, doc, opts
+}
+
+// Add one prose rune covering raw bytes [start, end)
+func (d *proseDocument) addRune(r rune, start, end int) {
+	d.prose = append(d.prose, r)
+	d.rawStart = append(d.rawStart, start)
+	d.rawEnd = append(d.rawEnd, end)
+}
+
+// Add raw bytes [start, end) to the prose as they are
+func (d *proseDocument) addRaw(start, end int) {
+	for i, r := range d.raw[start:end] {
+		d.addRune(r, start+i, start+i+utf8.RuneLen(r))
+	}
+}
+
+// Add a space standing in for a line break inside a block. Like block separators it can't be edited,
+// so corrections never join or split the document's lines
+func (d *proseDocument) addSpace() {
+	if len(d.prose) == 0 || d.endsBlock() || d.prose[len(d.prose)-1] == ' ' {
+		return
+	}
+	d.addRune(' ', -1, -1)
+}
+
+// End the current block of prose
+func (d *proseDocument) endBlock() {
+	if len(d.prose) == 0 || d.endsBlock() {
+		return
+	}
+	// Trailing spaces are never part of the prose
+	for len(d.prose) > 0 && d.prose[len(d.prose)-1] == ' ' {
+		d.truncate(len(d.prose) - 1)
+	}
+	for _, r := range blockSeparator {
+		d.addRune(r, -1, -1)
+	}
+}
+
+// Check if the prose ends with a block separator
+func (d *proseDocument) endsBlock() bool {
+	n := len(d.prose)
+	return n > 0 && d.prose[n-1] == '\n' && d.rawStart[n-1] == -1
+}
+
+func (d *proseDocument) truncate(n int) {
+	d.prose, d.rawStart, d.rawEnd = d.prose[:n], d.rawStart[:n], d.rawEnd[:n]
+}
+
+// Drop the separator after the last block
+func (d *proseDocument) finish() *proseDocument {
+	d.endBlock()
+	n := len(d.prose)
+	for n > 0 && d.rawStart[n-1] == -1 {
+		n--
+	}
+	d.truncate(n)
+	if d.escape == nil {
+		d.escape = func(s string) string { return s }
+	}
+	return d
+}
+
+// Raw byte range covered by prose runes [start, end). Fails if the range crosses anything that isn't prose
+func (d *proseDocument) rawRange(start, end int) (int, int, bool) {
+	if start < 0 || end > len(d.prose) || start >= end {
+		return 0, 0, false
+	}
+	for i := start; i < end; i++ {
+		if d.rawStart[i] == -1 || (i > start && d.rawStart[i] != d.rawEnd[i-1]) {
+			return 0, 0, false
+		}
+	}
+	return d.rawStart[start], d.rawEnd[end-1], true
+}
+
+// Raw byte offset to insert text at, in front of prose rune `pos`.
+// Text is added to the end of the prose before it where possible, so it stays in the same block
+func (d *proseDocument) rawInsert(pos int) (int, bool) {
+	if pos > 0 && pos <= len(d.prose) && d.rawStart[pos-1] != -1 {
+		return d.rawEnd[pos-1], true
+	}
+	if pos < len(d.prose) && d.rawStart[pos] != -1 {
+		return d.rawStart[pos], true
+	}
+	return 0, false
+}
+
+// Rewrite the corrected prose into the raw document. Edits the model made across anything that
+// isn't prose are dropped, so the document's structure is kept byte for byte
+func (d *proseDocument) restore(corrected string) string {
+	dmp := diffmatchpatch.New()
+	diffs := dmp.DiffMainRunes(d.prose, []rune(corrected), false)
+
+	var out strings.Builder
+	rawPos := 0 // End of the raw text written so far
+	pos := 0    // Prose rune the diff has reached
+	for i := 0; i < len(diffs); i++ {
+		if diffs[i].Type == diffmatchpatch.DiffEqual {
+			pos += utf8.RuneCountInString(diffs[i].Text)
+			continue
+		}
+
+		// Merge a deletion and the insertion replacing it into one edit
+		start := pos
+		var insert string
+		for ; i < len(diffs) && diffs[i].Type != diffmatchpatch.DiffEqual; i++ {
+			if diffs[i].Type == diffmatchpatch.DiffDelete {
+				pos += utf8.RuneCountInString(diffs[i].Text)
+			} else {
+				insert += diffs[i].Text
+			}
+		}
+		i--
+
+		var rawStart, rawEnd int
+		ok := false
+		if pos > start {
+			rawStart, rawEnd, ok = d.rawRange(start, pos)
+		} else {
+			rawStart, ok = d.rawInsert(start)
+			rawEnd = rawStart
+		}
+		if !ok || rawStart < rawPos {
+			print.Debug("Dropping correction %q of prose %q, it crosses the document's markup", insert, string(d.prose[start:pos]))
+			continue
+		}
+		out.WriteString(d.raw[rawPos:rawStart])
+		out.WriteString(d.escape(insert))
+		rawPos = rawEnd
+	}
+	out.WriteString(d.raw[rawPos:])
+	return out.String()
+}
+
+// Move markups from prose rune offsets onto raw rune offsets. Markups crossing anything that isn't prose are dropped
+func (d *proseDocument) mapMarkups(markups []Markup) []Markup {
+	runeIndex := func(b int) int { return utf8.RuneCountInString(d.raw[:b]) }
+
+	var mapped []Markup
+	for _, m := range markups {
+		for k := range m.Replacements {
+			m.Replacements[k] = d.escape(m.Replacements[k])
+		}
+
+		start, end, ok := d.rawRange(m.Index, m.Index+m.Length)
+		if !ok && m.Index == len(d.prose) && len(d.prose) > 0 {
+			// Markups past the end of the prose cover its last rune instead, keeping it in the replacements
+			start, end, ok = d.rawRange(m.Index-1, m.Index)
+			last := d.raw[start:end]
+			for k := range m.Replacements {
+				m.Replacements[k] = last + m.Replacements[k]
+			}
+		}
+		if !ok {
+			print.Debug("Dropping markup %+v, it crosses the document's markup", m)
+			continue
+		}
+		m.Index = runeIndex(start)
+		m.Length = runeIndex(end) - m.Index
+		mapped = append(mapped, m)
+	}
+	return mapped
+}
+
+// Map a response for the prose back onto the raw document
+func (d *proseDocument) finishResponse(text string, gec_result *GecResponse, opts CheckOptions) {
+	if d == nil {
+		return
+	}
+	gec_result.CorrectedText = d.restore(gec_result.CorrectedText)
+	gec_result.TextMarkups = assignMarkupIDs(text, d.mapMarkups(gec_result.TextMarkups))
+	gec_result.TextMarkups = encodeMarkupOffsets(text, gec_result.TextMarkups, opts.OffsetEncoding)
+	gec_result.CharacterCount = len(text)
+	gec_result.ContainsProfanity = false
+	for _, m := range gec_result.TextMarkups {
+		gec_result.ContainsProfanity = gec_result.ContainsProfanity || m.Category == CategoryProfanity
+	}
+	gec_result.Profanity = profanityReport(gec_result.TextMarkups)
+}
@@ -0,0 +1,580 @@
+// src/internal/gec/docx.go
+package gec
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	wordNamespace   = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	mcNamespace     = "http://schemas.openxmlformats.org/markup-compatibility/2006"
+	commentsType    = "application/vnd.openxmlformats-officedocument.wordprocessingml.comments+xml"
+	commentsRelType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments"
+)
+
+var DocxAuthor = "GEC" // Author of the tracked changes & comments in corrected documents
+
+// The main part of a .docx file, word/document.xml, and the paragraphs read from it
+type docxDocument struct {
+	raw        []byte
+	prefix     string // Prefix of the WordprocessingML namespace, used for the elements written into the document
+	paragraphs []*officeParagraph
+	maxID      int    // Highest annotation id in use, new comments & changes are numbered after it
+	comments   []byte // word/comments.xml, nil if the document has no comments
+}
+
+// A paragraph being parsed, and the run being read in it
+type docxFrame struct {
+	paragraph *officeParagraph
+	depth     int
+	run       *officeRun
+	runDepth  int
+}
+
+// Read the paragraphs of a .docx file
+func (f *officeFile) docx() (*docxDocument, error) {
+	raw, err := f.read("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseDocx(raw)
+	if err != nil {
+		return nil, err
+	}
+	if f.has("word/comments.xml") {
+		if doc.comments, err = f.read("word/comments.xml"); err != nil {
+			return nil, err
+		}
+		ids, err := parseDocx(doc.comments)
+		if err != nil {
+			return nil, err
+		}
+		doc.maxID = max(doc.maxID, ids.maxID)
+	}
+	return doc, nil
+}
+
+// Read the paragraphs of word/document.xml. Each w:r element is a run. Runs holding anything other than text,
+// tabs & breaks, or already inside a tracked change, keep their text but can't be edited
+func parseDocx(raw []byte) (*docxDocument, error) {
+	d := &docxDocument{raw: raw}
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+
+	var frames []*docxFrame // Open paragraphs. Text boxes hold paragraphs inside the runs of another
+	depth := 0
+	skipDepth := 0     // Depth of the element being skipped, 0 if none
+	revisionDepth := 0 // Depth of the tracked change holding the current element, 0 if none
+	textDepth := 0     // Depth of the w:t being read, 0 if none
+	propsStart := -1
+	count := 0
+	for {
+		pos := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+		}
+
+		var frame *docxFrame
+		if len(frames) > 0 {
+			frame = frames[len(frames)-1]
+		}
+		inRun := frame != nil && frame.run != nil
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if skipDepth != 0 {
+				continue
+			}
+			if depth == 1 {
+				d.prefix = namespacePrefix(t, wordNamespace)
+			}
+			d.maxID = max(d.maxID, wordID(t))
+
+			// Fallbacks repeat the content of the choice before them for older readers
+			if t.Name.Space == mcNamespace && t.Name.Local == "Fallback" {
+				skipDepth = depth
+				continue
+			}
+
+			if inRun && depth == frame.runDepth+1 {
+				word := t.Name.Space == wordNamespace
+				switch {
+				case word && t.Name.Local == "rPr":
+					propsStart = pos
+				case word && t.Name.Local == "t":
+					textDepth = depth
+				case word && t.Name.Local == "tab":
+					frame.paragraph.addText(frame.run, "\t")
+				case word && (t.Name.Local == "br" || t.Name.Local == "cr"):
+					if typ := attrValue(t, "type"); typ != "" && typ != "textWrapping" {
+						frame.run.editable = false // Page & column breaks
+					} else {
+						frame.paragraph.addText(frame.run, "\n")
+					}
+				case word && t.Name.Local == "lastRenderedPageBreak":
+					// Only a hint for the layout, dropped from split runs
+				default:
+					frame.run.editable = false
+				}
+				continue
+			}
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+
+			switch t.Name.Local {
+			case "p":
+				frames = append(frames, &docxFrame{paragraph: &officeParagraph{index: count}, depth: depth})
+				count++
+			case "r":
+				if frame != nil && !inRun {
+					frame.run = frame.paragraph.newRun()
+					frame.run.rawStart = pos
+					frame.run.editable = revisionDepth == 0
+					frame.runDepth = depth
+				}
+			case "ins", "del", "moveFrom", "moveTo":
+				if revisionDepth == 0 {
+					revisionDepth = depth
+				}
+			}
+
+		case xml.EndElement:
+			end := int(dec.InputOffset())
+			switch {
+			case skipDepth != 0:
+				if depth == skipDepth {
+					skipDepth = 0
+				}
+			case inRun && depth == frame.runDepth:
+				frame.run.rawEnd = end
+				frame.run = nil
+			case inRun && depth == frame.runDepth+1:
+				if propsStart != -1 && t.Name.Space == wordNamespace && t.Name.Local == "rPr" {
+					frame.run.props = string(raw[propsStart:end])
+					propsStart = -1
+				}
+				textDepth = 0
+			case frame != nil && depth == frame.depth:
+				d.paragraphs = append(d.paragraphs, frame.paragraph)
+				frames = frames[:len(frames)-1]
+			}
+			if depth == revisionDepth {
+				revisionDepth = 0
+			}
+			depth--
+
+		case xml.CharData:
+			if textDepth != 0 && skipDepth == 0 && inRun {
+				frame.paragraph.addText(frame.run, string(t))
+			}
+		}
+	}
+
+	// Text boxes finish before the paragraph holding them
+	sortParagraphs(d.paragraphs)
+	return d, nil
+}
+
+// Prefix a root element declares for a namespace, empty if it isn't declared
+func namespacePrefix(root xml.StartElement, namespace string) string {
+	for _, attr := range root.Attr {
+		if attr.Name.Space == "xmlns" && attr.Value == namespace {
+			return attr.Name.Local
+		}
+	}
+	return ""
+}
+
+// Numeric w:id of an element, 0 if it has none
+func wordID(t xml.StartElement) int {
+	for _, attr := range t.Attr {
+		if attr.Name.Space == wordNamespace && attr.Name.Local == "id" {
+			id, _ := strconv.Atoi(attr.Value)
+			return id
+		}
+	}
+	return 0
+}
+
+func attrValue(t xml.StartElement, name string) string {
+	for _, attr := range t.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// Events written into a run at a rune offset of the paragraph
+const (
+	eventCommentStart = iota
+	eventInsert
+	eventCommentEnd
+)
+
+type docxEvent struct {
+	run    int // Run the event is written in
+	pos    int
+	markup int // Markup the event comes from. Events of the same markup keep the order of their kinds
+	kind   int
+	id     int    // Comment id
+	text   []rune // Inserted text
+}
+
+// A comment added to the document
+type docxComment struct {
+	id   int
+	text string
+}
+
+// Replacement of raw bytes [start, end) of document.xml
+type docxSplice struct {
+	start, end int
+	text       string
+}
+
+// Write the markups of each paragraph into the document. The first replacement of a markup becomes a tracked change,
+// unless it overlaps an earlier one or touches a run that can't be edited. Every markup gets a comment with its message.
+// Returns the files of the .docx that changed
+func (d *docxDocument) annotate(f *officeFile, checks []officeCheck, author, date string) (map[string][]byte, error) {
+	w := &docxWriter{doc: d, author: xmlEscape(author), date: date, nextID: d.maxID + 1}
+
+	var splices []docxSplice
+	for _, check := range checks {
+		if check.result.GecResponse == nil || len(check.result.TextMarkups) == 0 {
+			continue
+		}
+		if d.prefix == "" {
+			return nil, fmt.Errorf("%w: word/document.xml has no prefix for the WordprocessingML namespace", ErrUnsupportedDocument)
+		}
+		splices = append(splices, w.paragraph(check.paragraph, check.result.TextMarkups)...)
+	}
+	if len(w.comments) == 0 {
+		return map[string][]byte{}, nil
+	}
+
+	// Runs are never nested, but a text box's runs are inside the run holding it, which only ever gets text added around it
+	sort.SliceStable(splices, func(i, j int) bool {
+		return splices[i].start < splices[j].start
+	})
+	var out bytes.Buffer
+	rawPos := 0
+	for _, s := range splices {
+		out.Write(d.raw[rawPos:s.start])
+		out.WriteString(s.text)
+		rawPos = max(rawPos, s.end)
+	}
+	out.Write(d.raw[rawPos:])
+	changed := map[string][]byte{"word/document.xml": out.Bytes()}
+
+	if err := w.writeComments(f, changed); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// Writes tracked changes & comments, numbering them after the ids already in the document
+type docxWriter struct {
+	doc      *docxDocument
+	author   string // Escaped for attributes
+	date     string
+	nextID   int
+	comments []docxComment
+}
+
+func (w *docxWriter) id() int {
+	w.nextID++
+	return w.nextID - 1
+}
+
+// Splices writing a paragraph's markups into its runs
+func (w *docxWriter) paragraph(p *officeParagraph, markups []Markup) []docxSplice {
+	markups = append([]Markup(nil), markups...)
+	sort.SliceStable(markups, func(i, j int) bool {
+		return markups[i].Index < markups[j].Index
+	})
+
+	var events []docxEvent
+	deleted := make(map[int]bool)
+	editedEnd := 0 // End of the last tracked change
+	for i, m := range markups {
+		start := min(m.Index, len(p.text))
+		end := markupEnd(m, len(p.text))
+		before := func(pos int) int { return p.runAt(max(pos-1, 0)) }
+
+		id := w.id()
+		w.comments = append(w.comments, docxComment{id: id, text: m.Message})
+		events = append(events,
+			docxEvent{run: p.runAt(start), pos: start, markup: i, kind: eventCommentStart, id: id},
+			docxEvent{run: before(end), pos: end, markup: i, kind: eventCommentEnd, id: id})
+
+		if len(m.Replacements) == 0 || start < editedEnd {
+			continue
+		}
+
+		// Only the part of the text that changes is tracked
+		orig, repl := p.text[start:end], []rune(m.Replacements[0])
+		prefix := 0
+		for prefix < len(orig) && prefix < len(repl) && orig[prefix] == repl[prefix] {
+			prefix++
+		}
+		suffix := 0
+		for suffix < len(orig)-prefix && suffix < len(repl)-prefix && orig[len(orig)-1-suffix] == repl[len(repl)-1-suffix] {
+			suffix++
+		}
+		delStart, delEnd := start+prefix, end-suffix
+		insert := repl[prefix : len(repl)-suffix]
+		if delStart == delEnd && len(insert) == 0 {
+			continue
+		}
+
+		editable := len(insert) == 0 || p.runs[before(delEnd)].editable
+		for pos := delStart; pos < delEnd; pos++ {
+			editable = editable && p.runs[p.runAt(pos)].editable
+		}
+		if !editable {
+			continue
+		}
+		for pos := delStart; pos < delEnd; pos++ {
+			deleted[pos] = true
+		}
+		if len(insert) > 0 {
+			events = append(events, docxEvent{run: before(delEnd), pos: delEnd, markup: i, kind: eventInsert, text: insert})
+		}
+		editedEnd = end
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.run != b.run {
+			return a.run < b.run
+		}
+		if a.pos != b.pos {
+			return a.pos < b.pos
+		}
+		if a.markup != b.markup {
+			return a.markup < b.markup
+		}
+		return a.kind < b.kind
+	})
+
+	var splices []docxSplice
+	for r, run := range p.runs {
+		var runEvents []docxEvent
+		for _, e := range events {
+			if e.run == r {
+				runEvents = append(runEvents, e)
+			}
+		}
+		changed := len(runEvents) > 0
+		for pos := run.start; pos < run.end && !changed; pos++ {
+			changed = deleted[pos]
+		}
+		if !changed {
+			continue
+		}
+
+		if !run.editable {
+			// Comments start before the run and end after it
+			var pre, post strings.Builder
+			for _, e := range runEvents {
+				if e.kind == eventCommentStart {
+					w.event(&pre, e, run.props)
+				} else {
+					w.event(&post, e, run.props)
+				}
+			}
+			splices = append(splices,
+				docxSplice{start: run.rawStart, end: run.rawStart, text: pre.String()},
+				docxSplice{start: run.rawEnd, end: run.rawEnd, text: post.String()})
+			continue
+		}
+
+		// Split the run where the events & deletions are
+		var b strings.Builder
+		var segment []rune
+		segmentDeleted := false
+		flush := func() {
+			if len(segment) == 0 {
+				return
+			}
+			if segmentDeleted {
+				fmt.Fprintf(&b, `<%s:del %s>`, w.doc.prefix, w.revisionAttrs())
+				w.run(&b, run.props, segment, true)
+				fmt.Fprintf(&b, `</%s:del>`, w.doc.prefix)
+			} else {
+				w.run(&b, run.props, segment, false)
+			}
+			segment = nil
+		}
+		k := 0
+		for pos := run.start; pos <= run.end; pos++ {
+			for ; k < len(runEvents) && runEvents[k].pos == pos; k++ {
+				flush()
+				w.event(&b, runEvents[k], run.props)
+			}
+			if pos == run.end {
+				break
+			}
+			if deleted[pos] != segmentDeleted {
+				flush()
+				segmentDeleted = deleted[pos]
+			}
+			segment = append(segment, p.text[pos])
+		}
+		flush()
+		splices = append(splices, docxSplice{start: run.rawStart, end: run.rawEnd, text: b.String()})
+	}
+	return splices
+}
+
+// Attributes of a new tracked change
+func (w *docxWriter) revisionAttrs() string {
+	prefix := w.doc.prefix
+	return fmt.Sprintf(`%s:id="%d" %s:author="%s" %s:date="%s"`, prefix, w.id(), prefix, w.author, prefix, w.date)
+}
+
+func (w *docxWriter) event(b *strings.Builder, e docxEvent, props string) {
+	prefix := w.doc.prefix
+	switch e.kind {
+	case eventCommentStart:
+		fmt.Fprintf(b, `<%s:commentRangeStart %s:id="%d"/>`, prefix, prefix, e.id)
+	case eventCommentEnd:
+		fmt.Fprintf(b, `<%s:commentRangeEnd %s:id="%d"/><%s:r><%s:commentReference %s:id="%d"/></%s:r>`, prefix, prefix, e.id, prefix, prefix, prefix, e.id, prefix)
+	case eventInsert:
+		fmt.Fprintf(b, `<%s:ins %s>`, prefix, w.revisionAttrs())
+		w.run(b, props, e.text, false)
+		fmt.Fprintf(b, `</%s:ins>`, prefix)
+	}
+}
+
+// Write a run with the given properties, holding text or deleted text
+func (w *docxWriter) run(b *strings.Builder, props string, text []rune, deleted bool) {
+	prefix := w.doc.prefix
+	tag := "t"
+	if deleted {
+		tag = "delText"
+	}
+
+	fmt.Fprintf(b, "<%s:r>%s", prefix, props)
+	var chunk []rune
+	flush := func() {
+		if len(chunk) > 0 {
+			fmt.Fprintf(b, `<%s:%s xml:space="preserve">%s</%s:%s>`, prefix, tag, xmlEscape(string(chunk)), prefix, tag)
+			chunk = nil
+		}
+	}
+	for _, r := range text {
+		switch r {
+		case '\t':
+			flush()
+			fmt.Fprintf(b, "<%s:tab/>", prefix)
+		case '\n':
+			flush()
+			fmt.Fprintf(b, "<%s:br/>", prefix)
+		default:
+			chunk = append(chunk, r)
+		}
+	}
+	flush()
+	fmt.Fprintf(b, "</%s:r>", prefix)
+}
+
+// Add the comments to word/comments.xml, creating it and registering it with the package if needed
+func (w *docxWriter) writeComments(f *officeFile, changed map[string][]byte) error {
+	comments := func(prefix string) string {
+		var b strings.Builder
+		for _, c := range w.comments {
+			fmt.Fprintf(&b, `<%s:comment %s:id="%d" %s:author="%s" %s:date="%s"><%s:p><%s:r><%s:t xml:space="preserve">%s</%s:t></%s:r></%s:p></%s:comment>`,
+				prefix, prefix, c.id, prefix, w.author, prefix, w.date, prefix, prefix, prefix, xmlEscape(c.text), prefix, prefix, prefix, prefix)
+		}
+		return b.String()
+	}
+
+	if w.doc.comments != nil {
+		updated, err := insertBeforeRootEnd(w.doc.comments, comments)
+		if err != nil {
+			return err
+		}
+		changed["word/comments.xml"] = updated
+		return nil
+	}
+
+	changed["word/comments.xml"] = []byte(xml.Header + `<w:comments xmlns:w="` + wordNamespace + `">` + comments("w") + `</w:comments>`)
+
+	types, err := f.read("[Content_Types].xml")
+	if err != nil {
+		return err
+	}
+	if !bytes.Contains(types, []byte(`PartName="/word/comments.xml"`)) {
+		override := `<Override PartName="/word/comments.xml" ContentType="` + commentsType + `"/>`
+		if types, err = insertBeforeRootEnd(types, func(string) string { return override }); err != nil {
+			return err
+		}
+		changed["[Content_Types].xml"] = types
+	}
+
+	rels, err := f.read("word/_rels/document.xml.rels")
+	if err != nil {
+		return err
+	}
+	id := 1
+	for bytes.Contains(rels, []byte(fmt.Sprintf(`Id="rIdGec%d"`, id))) {
+		id++
+	}
+	rel := fmt.Sprintf(`<Relationship Id="rIdGec%d" Type="%s" Target="comments.xml"/>`, id, commentsRelType)
+	if changed["word/_rels/document.xml.rels"], err = insertBeforeRootEnd(rels, func(string) string { return rel }); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Insert XML before the closing tag of a document's root element. `content` is given the root's prefix
+func insertBeforeRootEnd(raw []byte, content func(prefix string) string) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	depth := 0
+	for {
+		pos := int(dec.InputOffset())
+		tok, err := dec.RawToken()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth != 0 {
+				continue
+			}
+			name := t.Name.Local
+			if t.Name.Space != "" {
+				name = t.Name.Space + ":" + name
+			}
+			end := int(dec.InputOffset())
+			insert := content(t.Name.Space)
+			if pos == end {
+				// Self-closing root
+				return []byte(string(raw[:end-2]) + ">" + insert + "</" + name + ">" + string(raw[end:])), nil
+			}
+			return []byte(string(raw[:pos]) + insert + string(raw[pos:])), nil
+		}
+	}
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
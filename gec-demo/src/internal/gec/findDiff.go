@@ -3,10 +3,9 @@ package gec
 
 import (
 	"fmt"
-	"bytes"
-	"regexp"
 	"sort"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 
 	"gec-demo/src/internal/print"
@@ -14,23 +13,33 @@ import (
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
 
-var (
-	//reCase1     = regexp.MustCompile(`\x1b\[31m(\s*)\x1b\[0m(\s*\n+)`)
-	reCase2     = regexp.MustCompile(`([.?!][ ]?)\x1b\[31m([ ]*)\x1b\[0m`)
-	reCase3     = regexp.MustCompile(`[[:alnum:],]*((\x1b\[3[12]m.*?\x1b\[0m)(\x1b\[3[12]m.*?\x1b\[0m|\S)*)`)
-	reCaseQuote = regexp.MustCompile(`[[:alnum:],.?!]*((\x1b\[3[12]m"*?\x1b\[0m)(\x1b\[3[12]m"*?\x1b\[0m|\S)*)`)
-	subMatch1   = regexp.MustCompile(`\x1b\[31m\s`)
-	subMatch2   = regexp.MustCompile(`\x1b\[31m.*?\s\x1b\[0m`)
-	anyChangeRe = regexp.MustCompile(`\x1b\[(3[12])m(.*?)\x1b\[0m`) // Find any changes in the text
-	goodEscapes = regexp.MustCompile(`\x1b\[32m(.*?)\x1b\[0m`)      // Matches good escape sequences and its contents
-	badEscapes  = regexp.MustCompile(`\x1b\[31m(.*?)\x1b\[0m`)      // Matches bad escape sequences and its contents
-	allEscapes  = regexp.MustCompile(`\x1b\[[0-9;]*[mK]`)           // Matches all escape sequences but NOT their contents
-	swapRe      = regexp.MustCompile(`(\x1b\[31m)(\s*)`)            // Matches bad escape sequence followed by whitespace
-)
-
 func FindDifference(t1, t2 string, Misspells []Misspell) ([]Markup, error) {
 	var Differences []Markup // Tracks differences in text
-	myStr := ""              // Running string to keep track of the buffer string
+	if err := findDifference(t1, t2, 0, Misspells, &Differences, nil); err != nil {
+		return nil, err
+	}
+
+	// Sort the diffs slice based on the Index field
+	sort.Slice(Differences, func(i, j int) bool {
+		return Differences[i].Index < Differences[j].Index
+	})
+
+	return Differences, nil
+}
+
+// Where the markups of a line go, and how they are scored
+type lineDiff struct {
+	start     int // Rune offset of the line in the text being marked up
+	corrStart int // Rune offset of the line in the corrected text
+	misspells []Misspell
+	diffs     *[]Markup
+	score     func(start, end int) *float64 // Confidence in a change to runes [start, end) of the corrected text, nil if unknown
+}
+
+// Diff the original & corrected text line by line, adding the markups to `Differences`.
+// `start` is the rune offset of the original text in the text being marked up. `score` may be nil
+func findDifference(t1, t2 string, start int, Misspells []Misspell, Differences *[]Markup, score func(start, end int) *float64) error {
+	line := lineDiff{start: start, misspells: Misspells, diffs: Differences, score: score}
 
 	// Split texts into lines
 	linesOne := strings.Split(t1, "\n")
@@ -40,162 +49,295 @@ func FindDifference(t1, t2 string, Misspells []Misspell) ([]Markup, error) {
 	}
 
 	for i, l1 := range linesOne {
-		var l2 string
 		if i+1 > len(linesTwo) {
-			l2 = linesOne[i] // Make it the same as original text
-			return nil, fmt.Errorf("FindDiff() Error: Original and Connected text split into lines of uneven in length (%v vs %v)", len(linesOne), len(linesTwo))
-		} else {
-			l2 = linesTwo[i]
+			return fmt.Errorf("FindDiff() Error: Original and Connected text split into lines of uneven in length (%v vs %v)", len(linesOne), len(linesTwo))
 		}
 
-		if l1 != l2 {
-			// Run Diff Finder on the string with the original line and the corrected line
-			DiffFinder((myStr + l1), (myStr + l2), Misspells, &Differences)
+		if l1 != linesTwo[i] {
+			// Align the original line with the corrected line and mark up the differences
+			diffLine(alignTokens(l1, linesTwo[i]), line)
 		}
-		// Append a newline and the original text
-		myStr += l1 + "\n"
+		line.start += utf8.RuneCountInString(l1) + 1
+		line.corrStart += utf8.RuneCountInString(linesTwo[i]) + 1
 	}
-
-	// Sort the diffs slice based on the Index field
-	sort.Slice(Differences, func(i, j int) bool {
-		return Differences[i].Index < Differences[j].Index
-	})
-
-	return Differences, nil
+	return nil
 }
 
-// Find and mark up any Differences between the strings
-func DiffFinder(t1, t2 string, Misspells []Misspell, Differences *[]Markup) {
-	// Setting Differences back to an empty array
-	var matches []string
-	var allMatches [][]string
+// A piece of an aligned line: one unchanged character, or text deleted from or inserted into the original
+type diffUnit struct {
+	op   diffmatchpatch.Operation
+	text string
+	corr int // Rune offset of a change in the corrected line
+}
 
-	// Get the buffer string
-	buffStr := getBuffer(t1, t2)
+func (u diffUnit) changed() bool {
+	return u.op != diffmatchpatch.DiffEqual
+}
 
-	// CASE #2: Ignore removed space before a new sentence
-	for reCase2.MatchString(buffStr) {
-		matches = reCase2.FindStringSubmatch(buffStr)
-		buffStr = strings.Replace(buffStr, matches[0], (matches[1] + matches[2]), -1)
+// Check if the unit is an unchanged character matching `match`
+func (u diffUnit) plain(match func(rune) bool) bool {
+	if u.changed() {
+		return false
 	}
+	r, _ := utf8.DecodeRuneInString(u.text)
+	return match(r)
+}
 
-	// CASE QUOTE: Like case 3 but just for quotes
-	skipCases := []string{}
-	for reCaseQuote.MatchString(buffStr) {
-		allMatches = reCaseQuote.FindAllStringSubmatch(buffStr, -1)
-
-		// If total matches equals the number of skip cases, then break
-		if len(allMatches) == len(skipCases) {
-			break
+// Split a line into words, and single whitespace, punctuation & symbol characters
+func tokenizeLine(line string) []string {
+	var tokens []string
+	wordStart := -1
+	for i, r := range line {
+		if isAlnum(r) || unicode.IsMark(r) {
+			if wordStart == -1 {
+				wordStart = i
+			}
+			continue
+		}
+		if wordStart != -1 {
+			tokens = append(tokens, line[wordStart:i])
+			wordStart = -1
 		}
+		tokens = append(tokens, string(r))
+	}
+	if wordStart != -1 {
+		tokens = append(tokens, line[wordStart:])
+	}
+	return tokens
+}
 
-		for _, matches := range allMatches {
-			// Skip case if its in the skipCases array
-			if contains(skipCases, matches[0]) {
-				continue
+// Align the tokens of the original & corrected line. Unchanged text comes back one character per unit,
+// each deletion or insertion the aligner found as a unit of its own
+func alignTokens(l1, l2 string) []diffUnit {
+	// Diff the lines as sequences of tokens, with a rune standing in for each distinct token
+	ids := make(map[string]rune)
+	var tokens []string
+	encode := func(line string) []rune {
+		var runes []rune
+		for _, tok := range tokenizeLine(line) {
+			id, ok := ids[tok]
+			if !ok {
+				id = rune(0x10000 + len(tokens)) // Outside the surrogate range, so the ids survive the diff's strings
+				ids[tok] = id
+				tokens = append(tokens, tok)
 			}
+			runes = append(runes, id)
+		}
+		return runes
+	}
+	r1, r2 := encode(l1), encode(l2)
 
-			// A markup is cut off in our match
-			if strings.LastIndex(matches[0], "\x1b[3") > strings.LastIndex(matches[0], "\x1b[0") {
-				skipCases = append(skipCases, matches[0])
-				continue
+	dmp := diffmatchpatch.New()
+	var units []diffUnit
+	corr := 0
+	for _, d := range dmp.DiffMainRunes(r1, r2, false) {
+		var text strings.Builder
+		for _, id := range d.Text {
+			text.WriteString(tokens[id-0x10000])
+		}
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			units = append(units, diffUnit{op: d.Type, text: text.String(), corr: corr})
+		case diffmatchpatch.DiffInsert:
+			units = append(units, diffUnit{op: d.Type, text: text.String(), corr: corr})
+			corr += utf8.RuneCountInString(text.String())
+		default:
+			for _, r := range text.String() {
+				units = append(units, diffUnit{op: diffmatchpatch.DiffEqual, text: string(r)})
+				corr++
 			}
+		}
+	}
+	return units
+}
 
-			matches[0] = strings.TrimPrefix(matches[0], "0m")
-			runCase(Differences, &buffStr, matches[0], Misspells)
+// Find and mark up the differences in an aligned line.
+// A markup covers the whole word around a change, and runs on to the next unchanged whitespace
+func diffLine(units []diffUnit, line lineDiff) {
+	isSpace := func(r rune) bool { return unicode.IsSpace(r) }
+	isWordOrComma := func(r rune) bool { return r < utf8.RuneSelf && (isAlnum(r) || r == ',') }
+	isWordOrPunct := func(r rune) bool { return r < utf8.RuneSelf && (isAlnum(r) || strings.ContainsRune(",.?!", r)) }
+
+	// Removed spaces before a new sentence are ignored
+	for i := 1; i < len(units); i++ {
+		if units[i].op != diffmatchpatch.DiffDelete || strings.Trim(units[i].text, " ") != "" {
+			continue
+		}
+		prev := i - 1
+		if units[prev].plain(func(r rune) bool { return r == ' ' }) && prev > 0 {
+			prev--
+		}
+		if units[prev].plain(func(r rune) bool { return strings.ContainsRune(".?!", r) }) {
+			units = replaceUnits(units, i, i+1, units[i].text)
 		}
 	}
 
-	// CASE #3: All encompassing case. Matches all text that include a modifications
-	// Start with Letters, Numbers, or Commas
-	for reCase3.MatchString(buffStr) {
-		// Loop through until all matches are resolved
-		allMatches = reCase3.FindAllStringSubmatch(buffStr, -1)
-
-		for _, matches := range allMatches {
-			switch {
-			case matches[0] == "\x1b[32m \x1b[0m\"":
-				// Ignore added spaces before ending quotes
-				// Do this by replacing the 1st instance of Matches[0] with just the quote `"`
-				buffStr = strings.Replace(buffStr, matches[0], matches[3], 1)
-
-			case subMatch1.MatchString(matches[1]) && matches[1] == matches[2]:
-				// First modification is removal with whitespace
-				// Put the white space before escape literal and then run the case
-				swapped := swapSpace(matches[1])
-				buffStr = strings.Replace(buffStr, matches[1], swapped, 1)
-				runCase(Differences, &buffStr, strings.TrimSpace(swapped), Misspells)
-
-			case matches[1] == matches[0] && subMatch2.MatchString(matches[2]):
-				// Removal before normal unrelated text
-				runCase(Differences, &buffStr, matches[2], Misspells)
-
-			default:
-				runCase(Differences, &buffStr, matches[0], Misspells)
+	// Markups run from the unchanged `prefix` characters before a change to the next unchanged whitespace.
+	// Also returns false if a later change in the markup holds whitespace
+	span := func(i int, prefix func(rune) bool) (int, int, bool) {
+		start := i
+		for start > 0 && units[start-1].plain(prefix) {
+			start--
+		}
+		end, ok := i+1, true
+		for ; end < len(units) && !units[end].plain(isSpace); end++ {
+			if units[end].changed() && strings.IndexFunc(units[end].text, unicode.IsSpace) != -1 {
+				ok = false
 			}
 		}
+		return start, end, ok
+	}
+
+	// Changes that only add or remove quotes also take the punctuation before them
+	for i := 0; i < len(units); i++ {
+		if !units[i].changed() || strings.Trim(units[i].text, `"`) != "" {
+			continue
+		}
+		// Quotes whose markup would run into a change with whitespace are left to the next pass
+		start, end, ok := span(i, isWordOrPunct)
+		if ok {
+			units = markupUnits(units, start, end, line)
+			i = start
+		}
+	}
+
+	for {
+		i := 0
+		for i < len(units) && !units[i].changed() {
+			i++
+		}
+		if i == len(units) {
+			break
+		}
+		start, end, _ := span(i, isWordOrComma)
+
+		first := units[i]
+		switch {
+		case start == i && end == i+2 && first.op == diffmatchpatch.DiffInsert && first.text == " " && units[i+1].text == `"`:
+			// Ignore added spaces before ending quotes
+			units = append(units[:i], units[i+1:]...)
+
+		case first.op == diffmatchpatch.DiffDelete && end == i+1 && strings.TrimLeftFunc(first.text, unicode.IsSpace) != "" && first.text[0] != strings.TrimLeftFunc(first.text, unicode.IsSpace)[0]:
+			// Removal starting with whitespace. The whitespace is left out of the markup
+			rest := strings.TrimLeftFunc(first.text, unicode.IsSpace)
+			units = replaceUnits(units, i, i+1, strings.TrimSuffix(first.text, rest))
+			lead := utf8.RuneCountInString(first.text) - utf8.RuneCountInString(rest)
+			units = append(units[:i+lead], append([]diffUnit{{op: diffmatchpatch.DiffDelete, text: rest, corr: first.corr}}, units[i+lead:]...)...)
+			units = markupUnits(units, i+lead, i+lead+1, line)
+
+		case start == i && first.op == diffmatchpatch.DiffDelete && strings.TrimRightFunc(first.text, unicode.IsSpace) != first.text:
+			// Removal ending with whitespace, before unrelated text
+			units = markupUnits(units, i, i+1, line)
+
+		default:
+			units = markupUnits(units, start, end, line)
+		}
 	}
 }
 
-// Returns value to add to []Markup slice
-func runCase(diffs *[]Markup, buffStr *string, match string, Misspells []Misspell) {
-	var replMsg, msgType string
-	ind := getWordsIndex(*buffStr, match)
+// Replace units [start, end) with unchanged text
+func replaceUnits(units []diffUnit, start, end int, text string) []diffUnit {
+	var plain []diffUnit
+	for _, r := range text {
+		plain = append(plain, diffUnit{op: diffmatchpatch.DiffEqual, text: string(r)})
+	}
+	return append(units[:start], append(plain, units[end:]...)...)
+}
 
-	// Remove unmodified punctuation marks from the end of the match
-	newMatch := strings.TrimRight(match, ".,?!:;")
+// Mark up the changes in units [start, end), then put the original text back in their place
+func markupUnits(units []diffUnit, start, end int, line lineDiff) []diffUnit {
+	// Remove unmodified punctuation marks from the end of the markup
+	for end > start+1 && units[end-1].plain(func(r rune) bool { return strings.ContainsRune(".,?!:;", r) }) {
+		end--
+	}
 
-	origWord, replWord := getWords(newMatch)
+	index := line.start
+	for _, u := range units[:start] {
+		if u.op != diffmatchpatch.DiffInsert {
+			index += utf8.RuneCountInString(u.text)
+		}
+	}
 
-	// Get length of word in runes
-	wordLen := utf8.RuneCountInString(origWord)
+	var origWord, replWord, addChanges, delChanges strings.Builder
+	corrStart, corrEnd := -1, -1 // Runes of the corrected line the changes cover
+	for k := start; k < end; k++ {
+		if !units[k].changed() {
+			origWord.WriteString(units[k].text)
+			replWord.WriteString(units[k].text)
+			continue
+		}
 
-	// Modify buffStr by removing this occurence with the original word
-	*buffStr = strings.Replace(*buffStr, newMatch, origWord, 1)
+		// The characters each run of changes adds & deletes
+		var deleted, inserted string
+		for ; k < end && units[k].changed(); k++ {
+			if units[k].op == diffmatchpatch.DiffDelete {
+				deleted += units[k].text
+			} else {
+				inserted += units[k].text
+			}
+			if corrStart == -1 {
+				corrStart = units[k].corr
+			}
+			changeEnd := units[k].corr
+			if units[k].op == diffmatchpatch.DiffInsert {
+				changeEnd += utf8.RuneCountInString(units[k].text)
+			}
+			corrEnd = max(corrEnd, changeEnd)
+		}
+		k--
+		origWord.WriteString(deleted)
+		replWord.WriteString(inserted)
+		added, removed := charChanges(deleted, inserted)
+		addChanges.WriteString(added)
+		delChanges.WriteString(removed)
+	}
+	orig, repl := origWord.String(), replWord.String()
+	units = replaceUnits(units, start, end, orig)
 
 	// Ignore replacements over 40 times the length of the original word
-	if len(replWord) > len(origWord)*40 && len(origWord) > 1 {
-		return
+	if len(repl) > len(orig)*40 && len(orig) > 1 {
+		return units
 	}
 
 	// Get replacement message and add to diffs
-	msgType, replMsg = getMsg(newMatch, replWord, origWord)
-	addToDiffs(diffs, ind, wordLen, replMsg, msgType, Misspells)
+	msgType, rule, replMsg := getMsg(addChanges.String(), delChanges.String(), repl, orig)
+	added := len(*line.diffs)
+	addToDiffs(line.diffs, index, utf8.RuneCountInString(orig), repl, replMsg, msgType, rule, line.misspells)
+	if len(*line.diffs) > added && line.score != nil {
+		(*line.diffs)[added].Confidence = line.score(line.corrStart+corrStart, line.corrStart+corrEnd)
+	}
+	return units
 }
 
-// Return added & removed changes in the word
-func getChanges(word string) (string, string) {
-	var addChange []string
-	var delChange []string
-
-	matches := anyChangeRe.FindAllStringSubmatch(word, -1)
-	for _, match := range matches {
-		switch match[1] {
-		case "32":
-			addChange = append(addChange, match[2])
-		case "31":
-			delChange = append(delChange, match[2])
+// Characters added to & removed from `orig` to make `repl`
+func charChanges(orig, repl string) (string, string) {
+	var added, removed strings.Builder
+	dmp := diffmatchpatch.New()
+	for _, d := range dmp.DiffMain(orig, repl, false) {
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			added.WriteString(d.Text)
+		case diffmatchpatch.DiffDelete:
+			removed.WriteString(d.Text)
 		}
 	}
-
-	return strings.Join(addChange, ""), strings.Join(delChange, "")
+	return added.String(), removed.String()
 }
 
-// Get replacement message for diff
-func getMsg(word, repl, orig string) (string, string) {
-	addChanges, delChanges := getChanges(word)
+// Get replacement message and rule code for diff from the characters it adds & deletes
+func getMsg(addChanges, delChanges, repl, orig string) (string, string, string) {
 	changes := addChanges + delChanges
 
 	// Changes only involve whitespace
 	if strings.TrimSpace(changes) == "" {
 		switch {
 		case addChanges == "":
-			return "Grammar", "Remove spacing \u201c" + repl + "\u201d"
+			return "Grammar", RuleSpacing, "Remove spacing \u201c" + repl + "\u201d"
 		case delChanges == "":
-			return "Grammar", "Add spacing \u201c" + repl + "\u201d"
+			return "Grammar", RuleSpacing, "Add spacing \u201c" + repl + "\u201d"
 		default:
 			// Both added and removed whitespace
-			return "Grammar", "Change spacing \u201c" + repl + "\u201d"
+			return "Grammar", RuleSpacing, "Change spacing \u201c" + repl + "\u201d"
 		}
 	}
 
@@ -203,23 +345,23 @@ func getMsg(word, repl, orig string) (string, string) {
 	if strings.Trim(changes, ".,?!") == "" {
 		switch {
 		case addChanges != "" && delChanges != "":
-			return "Grammar", "Replace \u201c" + delChanges + "\u201d with \u201c" + addChanges + "\u201d"
+			return "Grammar", RuleReplacePunctuation, "Replace \u201c" + delChanges + "\u201d with \u201c" + addChanges + "\u201d"
 		case strings.Contains(delChanges, ","):
-			return "Grammar", "Remove comma \u201c" + repl + "\u201d"
+			return "Grammar", RuleRemoveComma, "Remove comma \u201c" + repl + "\u201d"
 		case strings.Contains(addChanges, ","):
-			return "Grammar", "Add comma \u201c" + repl + "\u201d"
+			return "Grammar", RuleAddComma, "Add comma \u201c" + repl + "\u201d"
 		case strings.Contains(delChanges, "."):
-			return "Grammar", "Remove period \u201c" + repl + "\u201d"
+			return "Grammar", RuleRemovePeriod, "Remove period \u201c" + repl + "\u201d"
 		case strings.Contains(addChanges, "."):
-			return "Grammar", "Add period \u201c" + repl + "\u201d"
+			return "Grammar", RuleAddPeriod, "Add period \u201c" + repl + "\u201d"
 		case strings.Contains(delChanges, "?"):
-			return "Grammar", "Remove question mark \u201c" + repl + "\u201d"
+			return "Grammar", RuleRemoveQuestionMark, "Remove question mark \u201c" + repl + "\u201d"
 		case strings.Contains(addChanges, "?"):
-			return "Grammar", "Add question mark \u201c" + repl + "\u201d"
+			return "Grammar", RuleAddQuestionMark, "Add question mark \u201c" + repl + "\u201d"
 		case strings.Contains(delChanges, "!"):
-			return "Grammar", "Remove exclamation mark \u201c" + repl + "\u201d"
+			return "Grammar", RuleRemoveExclamation, "Remove exclamation mark \u201c" + repl + "\u201d"
 		case strings.Contains(addChanges, "!"):
-			return "Grammar", "Add exclamation mark \u201c" + repl + "\u201d"
+			return "Grammar", RuleAddExclamation, "Add exclamation mark \u201c" + repl + "\u201d"
 		}
 	}
 
@@ -227,78 +369,53 @@ func getMsg(word, repl, orig string) (string, string) {
 	if strings.Trim(changes, ".,?!:;\"") == "" {
 		switch {
 		case addChanges == "" && delChanges != "" && repl == "":
-			return "Grammar", "Remove unnecessary punctuation."
+			return "Grammar", RuleRemovePunctuation, "Remove unnecessary punctuation."
 		default:
-			return "Grammar", "Punctuation Suggestion \u201c" + repl + "\u201d"
+			return "Grammar", RulePunctuation, "Punctuation Suggestion \u201c" + repl + "\u201d"
 		}
 	}
 
 	switch {
 	// Deleted text
 	case repl == "":
-		return "Grammar", "This text is unnecessary."
+		return "Grammar", RuleUnnecessaryText, "This text is unnecessary."
 
 	// Case insensitive match
 	case strings.EqualFold(repl, orig):
-		return "Grammar", "Change the capitalization \u201c" + repl + "\u201d"
+		return "Grammar", RuleCapitalization, "Change the capitalization \u201c" + repl + "\u201d"
 
 	// Default Case
 	default:
-		return "Grammar", "Did you mean \u201c" + repl + "\u201d?"
+		return "Grammar", RuleWordReplacement, "Did you mean \u201c" + repl + "\u201d?"
 	}
 }
 
-// Returns the buffer string
-func getBuffer(t1, t2 string) string {
-	// Get the buffer string
-	dmp := diffmatchpatch.New()
-	diffs := dmp.DiffMain(t1, t2, false)
-
-	// Mark up the text with insertions & deletions
-	prettyTxt := dmp.DiffPrettyText(diffs)
-	buffer := bytes.NewBufferString(prettyTxt) // TYPE: bytes.buffer
-	return buffer.String()
-}
-
-// Moves whitespace before the escape literal (\x1b[31m) for indexing
-func swapSpace(text string) string {
-	// Replace occurrences using a function
-	replacedText := swapRe.ReplaceAllStringFunc(text, func(match string) string {
-		matches := swapRe.FindStringSubmatch(match)
-		if len(matches) >= 3 {
-			// Reorder the matches to put whitespace before the escape sequence
-			return matches[2] + matches[1]
-		}
-		return match
-	})
-
-	return replacedText
-}
-
 // Add a response to the diffs slice
-func addToDiffs(diffs *[]Markup, index, length int, replacement, diffType string, Misspells []Misspell) {
+func addToDiffs(diffs *[]Markup, index, length int, replWord, replacement, diffType, rule string, Misspells []Misspell) {
 	// For adding words
-	if length == 0 {
+	insert := length == 0
+	if insert {
 		length = 1
 	}
 
 	newMarkup := Markup{
-		Index:    index,
-		Length:   length,
-		Message:  replacement,
-		Category: strings.ToUpper(diffType + "_Suggestion"),
+		Index:        index,
+		Length:       length,
+		Message:      replacement,
+		Category:     strings.ToUpper(diffType + "_Suggestion"),
+		Rule:         rule,
+		Replacements: []string{replWord},
+		insert:       insert,
 	}
 
 	diffStart := index
 	diffEnd := index + length
 
-	// Remove value if intersecting with a misspelling (and we do NOT ignore collisions)
-	if !IgnoreCollisions {
-		for _, miss := range Misspells {
-			// If ranges intersect, Return from this function, do NOT add to diffs
-			if miss.Index < diffEnd && diffStart < (miss.Index+miss.Length) {
-				return
-			}
+	// Remove value if intersecting with a misspelling (Misspells is empty when we ignore collisions)
+	for _, miss := range Misspells {
+		// If ranges intersect, Return from this function, do NOT add to diffs
+		if miss.Index < diffEnd && diffStart < (miss.Index+miss.Length) {
+			return
 		}
 	}
 
@@ -311,29 +428,3 @@ func addToDiffs(diffs *[]Markup, index, length int, replacement, diffType string
 
 	*diffs = append(*diffs, newMarkup)
 }
-
-// Get index of string inside buffer string
-func getWordsIndex(inpString, pattern string) int {
-	parts := strings.Split(inpString, pattern)[0]
-
-	// Removes good escape sequences and their contents
-	parts = goodEscapes.ReplaceAllString(parts, "")
-
-	// Removes all escape sequences but NOT their contents
-	parts = allEscapes.ReplaceAllString(parts, "")
-
-	return utf8.RuneCountInString(parts)
-}
-
-// Return the original and replacement value for a word (Words before and after modifications)
-func getWords(word string) (string, string) {
-	// Get Original & Replacement words
-	origWord := goodEscapes.ReplaceAllString(word, "")
-	replWord := badEscapes.ReplaceAllString(word, "")
-
-	// Remove the rest of the escapes
-	origWord = allEscapes.ReplaceAllString(origWord, "")
-	replWord = allEscapes.ReplaceAllString(replWord, "")
-
-	return origWord, replWord
-}
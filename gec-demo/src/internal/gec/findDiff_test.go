@@ -0,0 +1,227 @@
+package gec
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "Rewrite the golden files with the current results")
+
+// A sentence pair from testdata/diff_pairs.jsonl and the markups FindDifference() finds for it
+type diffGolden struct {
+	Original   string   `json:"original"`
+	Corrected  string   `json:"corrected"`
+	Note       string   `json:"note,omitempty"` // Why the result differs from the old ANSI diff parser's, if it does
+	Markups    []Markup `json:"markups"`
+	OldMarkups []Markup `json:"old_markups,omitempty"` // The old ANSI diff parser's markups, when they differ
+}
+
+func TestFindDifferenceGolden(t *testing.T) {
+	f, err := os.Open("testdata/diff_pairs.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var results []diffGolden
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var pair diffGolden
+		if err := json.Unmarshal(scanner.Bytes(), &pair); err != nil {
+			t.Fatalf("Invalid sentence pair %q: %v", scanner.Text(), err)
+		}
+		pair.Markups, err = FindDifference(pair.Original, pair.Corrected, nil)
+		if err != nil {
+			t.Fatalf("FindDifference(%q, %q) returned an error: %v", pair.Original, pair.Corrected, err)
+		}
+		if pair.Markups == nil {
+			pair.Markups = []Markup{}
+		}
+
+		// Every difference from the old implementation must be explained by a note
+		old, err := legacyFindDifference(pair.Original, pair.Corrected, nil)
+		if err != nil {
+			t.Fatalf("legacyFindDifference(%q, %q) returned an error: %v", pair.Original, pair.Corrected, err)
+		}
+		if old == nil {
+			old = []Markup{}
+		}
+		newJSON, _ := json.Marshal(pair.Markups)
+		oldJSON, _ := json.Marshal(old)
+		switch {
+		case string(newJSON) != string(oldJSON):
+			pair.OldMarkups = old
+			if pair.Note == "" {
+				t.Errorf("%q -> %q: differs from the old diff parser without a note\nResult: %s\nOld: %s", pair.Original, pair.Corrected, newJSON, oldJSON)
+			}
+		case pair.Note != "":
+			t.Errorf("%q -> %q: has a note but matches the old diff parser", pair.Original, pair.Corrected)
+		}
+		results = append(results, pair)
+	}
+
+	// Drop the fields JSON doesn't keep so the results compare equal to the golden file
+	data, _ := json.Marshal(results)
+	results = nil
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatal(err)
+	}
+
+	const golden = "testdata/diff_pairs.golden.json"
+	if *updateGolden {
+		data, _ := json.MarshalIndent(results, "", "  ")
+		if err := os.WriteFile(golden, append(data, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	data, err = os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected []diffGolden
+	if err := json.Unmarshal(data, &expected); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("%d sentence pairs, %d in %s. Run the tests with -update to rewrite it", len(results), len(expected), golden)
+	}
+	for i := range results {
+		if !reflect.DeepEqual(results[i].Markups, expected[i].Markups) {
+			t.Errorf("%q -> %q:\nResult: %+v\nExpected: %+v", results[i].Original, results[i].Corrected, results[i].Markups, expected[i].Markups)
+		}
+		if !reflect.DeepEqual(results[i].OldMarkups, expected[i].OldMarkups) {
+			t.Errorf("%q -> %q: old diff parser\nResult: %+v\nExpected: %+v", results[i].Original, results[i].Corrected, results[i].OldMarkups, expected[i].OldMarkups)
+		}
+	}
+}
+
+func TestFindSentenceDifferences(t *testing.T) {
+	if defaultLanguage() == nil {
+		t.Skip("languages not loaded")
+	}
+
+	tests := []struct {
+		original  string
+		corrected string
+		sentences []SentenceResult
+		expected  []string // Marked up original text and its replacement
+	}{
+		// Same lines
+		{"i went home.\nIt was late", "I went home.\nIt was late.", nil, []string{"i -> I", "late -> late."}},
+		// Lines merged by the model
+		{"First line.\nsecond line", "First line. Second line.", nil, []string{"second -> Second", "line -> line."}},
+		// Line split by the model
+		{"One. Two.\nthree", "One.\nTwo.\nThree", nil, []string{"three -> Three"}},
+		// Sentences merged on one line
+		{"He left. and then he came back", "He left and then he came back.", nil, []string{"left. -> left", "back -> back."}},
+		{"No changes here.", "No changes here.", nil, nil},
+		// The model's correction of each sentence
+		{
+			"First line.\nsecond line", "First line. Second line.",
+			[]SentenceResult{{Text: "First line."}, {Text: "\n", Group: -1}, {Text: "Second line."}},
+			[]string{"second -> Second", "line -> line."},
+		},
+		{
+			"He left.  and then he came back", "He left and then he came back.",
+			[]SentenceResult{{Text: "He left and then he came back."}, {Merged: true}},
+			[]string{"left.  -> left", "back -> back."}, // "left. " loses its period & a space
+		},
+		// Only changed sentences are diffed, so the corrected text isn't looked at
+		{
+			"One. Two. three", "",
+			[]SentenceResult{{Text: "One."}, {Text: "Two."}, {Text: "Three"}},
+			[]string{"three -> Three"},
+		},
+	}
+
+	for _, tt := range tests {
+		var result []string
+		for _, m := range FindSentenceDifferences(tt.original, tt.corrected, tt.sentences, DefaultOptions(), nil, nil) {
+			result = append(result, runeSubstring(tt.original, m.Index, m.Length)+" -> "+m.Replacements[0])
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%q -> %q:\nResult: %q\nExpected: %q", tt.original, tt.corrected, result, tt.expected)
+		}
+	}
+
+	// FindDifference() still refuses texts whose lines don't match
+	if _, err := FindDifference("First line.\nsecond line", "First line. Second line.", nil); err == nil {
+		t.Errorf("FindDifference() returned no error for uneven lines")
+	}
+}
+
+func TestAlternativeReplacements(t *testing.T) {
+	if defaultLanguage() == nil {
+		t.Skip("languages not loaded")
+	}
+
+	// Other corrections add replacements to the changes of the best one, but never changes of their own
+	sentences := []SentenceResult{{Text: "He goes home.", Alternatives: []string{"He went home.", "He goes home!", "He goes home."}}}
+	markups := FindSentenceDifferences("He go home.", "", sentences, DefaultOptions(), nil, nil)
+	if len(markups) != 1 {
+		t.Fatalf("\nResult: %+v\nExpected one markup", markups)
+	}
+	if expected := []string{"goes", "went"}; !reflect.DeepEqual(markups[0].Replacements, expected) {
+		t.Errorf("\nResult: %q\nExpected: %q", markups[0].Replacements, expected)
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	if defaultLanguage() == nil {
+		t.Skip("languages not loaded")
+	}
+
+	// "He goes home. I have left now." with the model's confidence in each token
+	sentences := []SentenceResult{
+		{Text: "He goes home.", Tokens: []TokenScore{
+			{Start: 0, End: 2, LogProb: 0},
+			{Start: 2, End: 7, LogProb: math.Log(0.4)},
+			{Start: 7, End: 12, LogProb: 0},
+			{Start: 12, End: 13, LogProb: 0},
+		}},
+		{Text: "I left now.", Tokens: []TokenScore{
+			{Start: 0, End: 1, LogProb: math.Log(0.9)},
+			{Start: 1, End: 6, LogProb: math.Log(0.9)},
+			{Start: 6, End: 10, LogProb: 0},
+			{Start: 10, End: 11, LogProb: 0},
+		}},
+	}
+	markups := FindSentenceDifferences("He go home. I have left now.", "", sentences, DefaultOptions(), nil, nil)
+
+	// A replacement is scored by the tokens it covers, a removal by the tokens around it
+	var result []string
+	var confidences []float64
+	for _, m := range markups {
+		result = append(result, runeSubstring("He go home. I have left now.", m.Index, m.Length))
+		if m.Confidence == nil {
+			t.Fatalf("%q has no confidence", result[len(result)-1])
+		}
+		confidences = append(confidences, *m.Confidence)
+	}
+	if expected := []string{"go", "have "}; !reflect.DeepEqual(result, expected) {
+		t.Fatalf("\nResult: %q\nExpected: %q", result, expected)
+	}
+	if expected := []float64{0.4, 0.9}; !reflect.DeepEqual(confidences, expected) {
+		t.Errorf("\nResult: %v\nExpected: %v", confidences, expected)
+	}
+
+	// Low confidence suggestions are hidden or marked as hints. Unscored ones are always kept
+	markups = append(markups, Markup{Index: 20})
+	opts := DefaultOptions()
+	opts.MinConfidence = 0.5
+	if kept := opts.filterConfidence(append([]Markup(nil), markups...)); len(kept) != 2 || kept[0].Confidence == nil || *kept[0].Confidence != 0.9 {
+		t.Errorf("\nResult: %v\nExpected the 0.4 suggestion hidden", kept)
+	}
+	opts.LowConfidence = LowConfidenceHint
+	kept := opts.filterConfidence(append([]Markup(nil), markups...))
+	if len(kept) != 3 || !kept[0].Hint || kept[1].Hint || kept[2].Hint {
+		t.Errorf("\nResult: %v\nExpected only the 0.4 suggestion as a hint", kept)
+	}
+}
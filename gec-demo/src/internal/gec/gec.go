@@ -1,22 +1,6 @@
 // src/internal/gec/gec.go
 package gec
 
-/*
-#cgo CFLAGS: -I${SRCDIR}/../../native/gec_runtime/include
-#cgo CFLAGS: -I${SRCDIR}/../../native/gec_runtime/third_party
-#cgo CFLAGS: -I${SRCDIR}/../../native/gec_runtime/third_party/onnxruntime/include
-#cgo CFLAGS: -I${SRCDIR}/../../native/gec_runtime/third_party/sentencepiece/include
-
-#cgo LDFLAGS: ${SRCDIR}/../../native/gec_runtime/build/libgec.a
-#cgo LDFLAGS: -L${SRCDIR}/../../native/gec_runtime/third_party/onnxruntime/lib
-#cgo LDFLAGS: -L${SRCDIR}/../../native/gec_runtime/third_party/sentencepiece/lib
-#cgo LDFLAGS: -L${SRCDIR}/../../native/gec_runtime/third_party/icu/lib
-#cgo LDFLAGS: -lonnxruntime -lsentencepiece -lstdc++ -lm -ldl -licuuc -licudata
-
-#include "inference.h"
-#include <stdbool.h>
-*/
-import "C"
 import (
 	"fmt"
 	"math/rand"
@@ -24,9 +8,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
-	"unsafe"
 
+	"gec-demo/src/internal/langdetect"
 	"gec-demo/src/internal/print"
 	"gec-demo/src/internal/speechtagger"
 )
@@ -37,6 +20,7 @@ var (
 
 	LogLevel int
 	UseGpu   = false
+	Backend  string // Name of the Corrector backend used by the GEC channels
 
 	CountLT        = 0
 	ChanCapacity   = 250
@@ -46,6 +30,8 @@ var (
 
 	IgnoreCollisions = false
 	DoMisspellings   = true
+	MaxReplacements  = 5 // Maximum spelling suggestions returned per markup
+	DetectLanguage   = true
 )
 
 func init() {
@@ -53,22 +39,37 @@ func init() {
 	print.SetLevel(LogLevel)
 	print.Info("LOG LEVEL: %d", print.GetLevel())
 
-	GecoChannels = make([]chan WorkItem, NumChannels)
-	print.Debug("Total GEC Channels: %d", NumChannels)
-
-	for i := range NumChannels {
-		gpuId := i % DeviceCount
-
-		// Buffered channel with a capacity of `ChanCapacity`
-		GecoChannels[i] = make(chan WorkItem, ChanCapacity)
-		go ClaimGpu(gpuId, GecoChannels[i])
-	}
+	Backend = GetBackendName()
+	print.Info("GEC BACKEND: %s", Backend)
 
 	// Initialize the parts-of-speech tagging model
+	// Sentences can still be split without the tagger's weights
 	err := speechtagger.InitTaggingModel()
 	if err != nil {
 		fmt.Printf("ERROR: Failed to initialize TaggerModel: %v\n", err)
-		return
+		if speechtagger.SentTokenizer == nil {
+			return
+		}
+	}
+
+	// Start the GEC channels for each supported language
+	err = LoadLanguages()
+	if err != nil {
+		print.Error("failed to load languages: %v\n", err)
+	}
+	err = langdetect.InitProfiles()
+	if err != nil {
+		print.Error("failed to load language detection profiles: %v\n", err)
+	}
+
+	// Load the custom per-tenant dictionaries & profanity lists
+	err = LoadDictionaries(GetDictionaryDir())
+	if err != nil {
+		print.Error("failed to load custom dictionaries: %v\n", err)
+	}
+	err = LoadProfanityLists(GetProfanityDir())
+	if err != nil {
+		print.Error("failed to load profanity lists: %v\n", err)
 	}
 
 	if DoMisspellings {
@@ -80,29 +81,30 @@ func init() {
 	}
 }
 
-func ClaimGpu(gpuId int, ch chan WorkItem) {
-	var geco unsafe.Pointer
-
-	// Allocate a Geco object for the channel
-	geco = C.NewGeco(C.int(LogLevel), C.bool(UseGpu), C.int(gpuId))
-	if geco == nil {
-		print.Error("Failed initalizing GECO for gpu:%d", gpuId)
+func ClaimGpu(backend string, gpuId int, ch chan WorkItem) {
+	// Allocate a Corrector for the channel
+	corrector, err := NewCorrector(backend, gpuId)
+	if err != nil {
+		print.Error("Failed initalizing %s backend for gpu:%d, %v", backend, gpuId, err)
 		return
 	}
-	defer C.FreeGeco(geco)
+	defer corrector.Close()
 
 	for item := range ch {
-		res := CorrectGrammar(&geco, gpuId, item.Text, item.AllTexts)
+		res := corrector.Correct(item.AllTexts, item.Decoding)
 		item.Ch <- res
 	}
 }
 
 // Get random index for a channel to use in GEC Channels
-func PickGecChannel() int {
-	maxInd := len(GecoChannels)
+func PickGecChannel(channels []chan WorkItem) int {
+	maxInd := len(channels)
+	if maxInd == 0 {
+		return -1
+	}
 	for range NumChannels {
 		choice := rand.Intn(maxInd)
-		if len(GecoChannels[choice]) < cap(GecoChannels[choice]) {
+		if len(channels[choice]) < cap(channels[choice]) {
 			return choice
 		}
 	}
@@ -129,33 +131,74 @@ func GetLogLevel() int {
 	return n
 }
 
-// Run G.E.C. requests and return results
-func MarkupGrammar(text string) (gec_result *GecResponse, err error) {
+// Run G.E.C. requests and return results mapped back onto the original text, with offsets in the requested unit
+// Markdown & HTML are checked without their markup, then mapped back onto the document
+func MarkupGrammar(text string, opts CheckOptions) (*GecResponse, error) {
+	prose, doc, proseOpts := prepareDocument(text, opts)
+	clean, norm := normalizeText(prose)
+	proseOpts, detected := proseOpts.detectLanguage(clean)
+	gec_result, err := markupGrammar(clean, proseOpts)
+	if err != nil {
+		return nil, err
+	}
+	norm.finishResponse(prose, gec_result, proseOpts)
+	detected.apply(gec_result)
+	doc.finishResponse(text, gec_result, opts)
+	return gec_result, nil
+}
+
+// Run G.E.C. on cleaned text. Markup offsets are in runes
+func markupGrammar(text string, opts CheckOptions) (gec_result *GecResponse, err error) {
 	var misspells []Misspell
-	var differences []Markup
-	var gram_result *GrammarResult
 
-	gec_result = &GecResponse{}
+	// Every step leaves the same URLs, code & other protected text alone
+	protected := findProtectedSpans(text, opts)
 
-	text = CleanText(text)
+	// Find the spelling errors
+	misspells, err = FindMisspells(text, opts, protected)
+	if err != nil {
+		return nil, err
+	}
 
-	if DoMisspellings {
-		// Find the spelling errors
-		misspells, err = DirtySpellChecker(text)
-		if err != nil {
-			return nil, err
-		}
-		misspells = SpellChecker(misspells, text)
-		ViewMisspells(misspells)
+	if !opts.Grammar {
+		// Skip the model and only return the spelling errors
+		return BuildResponse(text, text, nil, misspells, 0, opts, protected)
 	}
 
 	// Run the model to get the grammatically corrected version of the text
-	gram_result, err = ProcessGrammar(text)
-	if gram_result.Err != nil {
-		return nil, fmt.Errorf("error running GEC, %v. Input Text: %q", gram_result.Err, text)
+	gram_result, err := ProcessGrammar(text, opts, protected)
+	if err != nil {
+		return nil, fmt.Errorf("error running GEC, %v. Input Text: %q", err, text)
 	}
 
-	corrected_text := gram_result.CorrectText
+	gec_result, err = BuildResponse(text, gram_result.CorrectText, gram_result.Sentences, misspells, gram_result.ServiceTime, opts, protected)
+	if err != nil {
+		return nil, err
+	}
+	gec_result.Decoding = narrowedDecoding(opts.Decoding, gram_result)
+	return gec_result, nil
+}
+
+// Find the profanity, emoji & spelling errors in the cleaned text. `protected` holds its protected spans
+func FindMisspells(text string, opts CheckOptions, protected []protectedSpan) (misspells []Misspell, err error) {
+	if opts.Profanity {
+		misspells = DirtySpellChecker(text, opts.ProfanityLists, opts.ProfanityAllow, opts.IgnoreCollisions)
+	}
+	if opts.Emojis {
+		misspells = MarkEmojis(misspells, text, opts.IgnoreCollisions)
+	}
+	if opts.Spelling {
+		misspells = SpellChecker(misspells, text, opts.IgnoreCollisions, opts.Dictionary, opts.Language)
+		misspells = dropProtectedSpelling(misspells, protected)
+	}
+	ViewMisspells(misspells)
+	return misspells, nil
+}
+
+// Diff the cleaned text against the model's corrected text and format the markups into a response.
+// `sentences` holds the model's correction of each sentence of the text if it is known, nil otherwise
+func BuildResponse(text, corrected_text string, sentences []SentenceResult, misspells []Misspell, serviceTime float64, opts CheckOptions, protected []protectedSpan) (gec_result *GecResponse, err error) {
+	gec_result = &GecResponse{}
 	if corrected_text == "" {
 		corrected_text = text
 	}
@@ -164,18 +207,9 @@ func MarkupGrammar(text string) (gec_result *GecResponse, err error) {
 	begSpace, endSpace := getSpaceAround(text)
 	corrected_text = begSpace + strings.TrimSpace(corrected_text) + endSpace
 
-	// Find the spelling errors and text differences between the original and corrected text
-	print.Debug("FIND_DIFF - Original Text: %q\nCorrected Text: %q", text, corrected_text)
-	differences, err = FindDifference(text, corrected_text, misspells)
+	text_markups, err_chars, profanity_words, err := markupText(text, corrected_text, sentences, misspells, opts, protected)
 	if err != nil {
-		return nil, fmt.Errorf("error in findDiff.go, %w", err)
-	}
-	print.Debug("FindDiff differences found: %v", len(differences))
-
-	// Format data to JSON
-	text_markups, err_chars, profanity_words, err := FormatToJson(text, differences, misspells)
-	if err != nil {
-		return nil, fmt.Errorf("error in FormatToJson(), %w", err)
+		return nil, err
 	}
 
 	gec_result.CorrectedText = corrected_text
@@ -183,14 +217,56 @@ func MarkupGrammar(text string) (gec_result *GecResponse, err error) {
 	gec_result.CharacterCount = len(text)
 	gec_result.ErrorCharacterCount = err_chars
 	gec_result.ContainsProfanity = len(profanity_words) > 0
-	gec_result.ServiceTime = gram_result.ServiceTime
+	gec_result.Profanity = profanityReport(text_markups)
+	gec_result.Language = opts.Language.code()
+	gec_result.ServiceTime = serviceTime
 	return gec_result, err
 }
 
-func ProcessGrammar(text string) (*GrammarResult, error) {
-	var result GrammarResult
+// Find the text differences between the original and corrected text, and combine them with the misspellings
+func markupText(text, corrected_text string, sentences []SentenceResult, misspells []Misspell, opts CheckOptions, protected []protectedSpan) (text_markups []Markup, err_chars int, profanity_words []string, err error) {
+	// Drop misspellings in categories the request filtered out
+	var kept []Misspell
+	for _, miss := range misspells {
+		if opts.keepCategory(miss.Category) {
+			kept = append(kept, miss)
+		}
+	}
 
-	all_texts := PreprocessText(text)
+	// Grammar markups are only dropped for colliding with misspellings when collisions are not ignored
+	collisions := kept
+	if opts.IgnoreCollisions {
+		collisions = nil
+	}
+
+	// Find the text differences between the original and corrected text
+	var differences []Markup
+	if text != corrected_text && opts.keepCategory(CategoryGrammar) {
+		print.Debug("FIND_DIFF - Original Text: %q\nCorrected Text: %q", text, corrected_text)
+		differences = FindSentenceDifferences(text, corrected_text, sentences, opts, protected, collisions)
+		print.Debug("FindDiff differences found: %v", len(differences))
+
+		// Leave URLs, code & other protected text alone, even if the model changed it
+		unprotected := differences[:0]
+		for _, diff := range differences {
+			if !overlapsProtected(protected, diff.Index, diff.Length, diff.insert) {
+				unprotected = append(unprotected, diff)
+			}
+		}
+		differences = unprotected
+		differences = opts.filterConfidence(differences)
+	}
+
+	// Format data to JSON
+	text_markups, err_chars, profanity_words, err = FormatToJson(text, differences, kept)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("error in FormatToJson(), %w", err)
+	}
+	return text_markups, err_chars, profanity_words, nil
+}
+
+func ProcessGrammar(text string, opts CheckOptions, protected []protectedSpan) (*GrammarResult, error) {
+	all_texts := PreprocessText(text, opts, protected)
 	if len(all_texts) <= 0 {
 		return nil, fmt.Errorf("PreprocessText() returns an empty list")
 	}
@@ -199,84 +275,46 @@ func ProcessGrammar(text string) (*GrammarResult, error) {
 	}
 
 	// Send the text to the GEC channel & wait for the result
+	work_item, err := SendWorkItem(text, all_texts, sentenceSpans(text, all_texts, protected), opts.Language, opts.Decoding)
+	if err != nil {
+		return nil, err
+	}
+	return WaitWorkItem(work_item)
+}
+
+// Send the texts to an available GEC channel of the language's backend without waiting for the result.
+// Protected text is swapped for placeholders so the model can't change it. `spans` holds the protected spans of each text
+func SendWorkItem(text string, all_texts []string, spans [][]protectedSpan, lang *Language, decoding DecodeOptions) (WorkItem, error) {
+	masked, mask := maskTexts(all_texts, spans)
 	work_item := WorkItem{
 		Text:     text,
-		AllTexts: all_texts,
+		AllTexts: masked,
+		Decoding: decoding,
 		Ch:       make(chan GrammarResult, 1), // Channel for receiving the result
+		mask:     mask,
 	}
 
-	// Send an item to the 1st channel in the GecoChannels
-	chan_index := PickGecChannel()
+	channels := GecoChannels
+	if lang != nil {
+		channels = lang.channels
+	}
+	chan_index := PickGecChannel(channels)
 	if chan_index == -1 {
-		return nil, fmt.Errorf("No available GPU to run the GEC server")
+		return work_item, fmt.Errorf("No available GPU to run the GEC server")
 	}
 	print.Debug("Sending work item to Chan[%d]", chan_index)
-	GecoChannels[chan_index] <- work_item
+	channels[chan_index] <- work_item
+	return work_item, nil
+}
 
-	// Wait for the result from the channel
-	result = <-work_item.Ch
+// Wait for the result of a work item sent with SendWorkItem()
+func WaitWorkItem(work_item WorkItem) (*GrammarResult, error) {
+	result := <-work_item.Ch
 	close(work_item.Ch)
 	if result.Err != nil {
 		return nil, result.Err
 	}
+	result.CorrectText = work_item.mask.restoreCorrected(result.CorrectText)
+	result.Sentences = work_item.mask.restoreSentences(result.Sentences)
 	return &result, nil
 }
-
-func CorrectGrammar(geco *unsafe.Pointer, gpuId int, text string, all_texts []string) GrammarResult {
-	chanTime := time.Now()
-	gram_result := GrammarResult{
-		CorrectText: "",
-		GpuId:       gpuId,
-		Err:         nil,
-		ServiceTime: 0.0,
-	}
-
-	// Check if a pointer is nil
-	if geco == nil {
-		gram_result.Err = fmt.Errorf("Geco pointer is nil")
-		return gram_result
-	}
-
-	// Convert Go strings to C strings
-	cTexts, ctext_cleanup := goStringsToC(all_texts)
-	defer ctext_cleanup()
-
-	// Run grammar correction
-	var c_output *C.char
-	C.GecoRun(*geco, &cTexts[0], C.int(len(all_texts)), &c_output)
-	defer cFree(c_output)
-	if c_output == nil {
-		gram_result.Err = fmt.Errorf("failed running 'C.GecoRun()' and returned a null pointer")
-		return gram_result
-	}
-	gram_result.CorrectText = C.GoString(c_output) // Convert the C char* to a Go string
-	print.Info("GEC Result: %q", gram_result.CorrectText)
-
-	duration := time.Since(chanTime).Seconds()
-	gram_result.ServiceTime = duration
-	return gram_result
-}
-
-// Converts go strings to C strings and returns a cleanup function
-func goStringsToC(strings []string) ([]*C.char, func()) {
-	cstrs := make([]*C.char, len(strings))
-	for i, s := range strings {
-		cstrs[i] = C.CString(s)
-	}
-
-	// Return a cleanup function
-	cleanup := func() {
-		print.Debug("Cleaning C strings")
-		for _, cstr := range cstrs {
-			cFree(cstr)
-		}
-	}
-	return cstrs, cleanup
-}
-
-// Frees memory that allocated by C.CString / C.malloc
-func cFree(p *C.char) {
-	if p != nil {
-		C.free(unsafe.Pointer(p))
-	}
-}
@@ -0,0 +1,703 @@
+package gec
+
+import (
+	"math"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestJoinTexts(t *testing.T) {
+	tests := []struct {
+		name     string
+		texts    []string
+		expected string
+	}{
+		{
+			name:     "Single sentence",
+			texts:    []string{"Hello world."},
+			expected: "Hello world.",
+		},
+		{
+			name:     "Multiple sentences",
+			texts:    []string{"Hello world.", "How are you?"},
+			expected: "Hello world. How are you?",
+		},
+		{
+			name:     "Newline literals",
+			texts:    []string{"Hello world.", "\n\n", "How are you?", "I am good.", "\n"},
+			expected: "Hello world.\n\nHow are you? I am good.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := joinTexts(tt.texts)
+			if result != tt.expected {
+				t.Errorf("\nResult: %q\nExpected: %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRuleCorrector(t *testing.T) {
+	tests := []struct {
+		name     string
+		backend  string
+		texts    []string
+		expected string
+	}{
+		{
+			name:     "Echo",
+			backend:  "echo",
+			texts:    []string{"we  should go.", "\n", "i think so."},
+			expected: "we  should go.\ni think so.",
+		},
+		{
+			name:     "Capitalize sentences",
+			backend:  "rules",
+			texts:    []string{"we should go.", "\n", "hello world."},
+			expected: "We should go.\nHello world.",
+		},
+		{
+			name:     "Pronoun I",
+			backend:  "rules",
+			texts:    []string{"I know i'm late and i said so, but i."},
+			expected: "I know I'm late and I said so, but I.",
+		},
+		{
+			name:     "Repeated pronoun I",
+			backend:  "rules",
+			texts:    []string{"and i i i think so, i i."},
+			expected: "And I I I think so, I I.",
+		},
+		{
+			name:     "Collapse spaces",
+			backend:  "rules",
+			texts:    []string{"We   should  go."},
+			expected: "We should go.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrector, err := NewCorrector(tt.backend, 0)
+			if err != nil {
+				t.Fatalf("NewCorrector(%q) returned an error: %v", tt.backend, err)
+			}
+			defer corrector.Close()
+
+			result := corrector.Correct(tt.texts, DefaultDecodeOptions())
+			if result.Err != nil {
+				t.Fatalf("Correct() returned an error: %v", result.Err)
+			}
+			if result.CorrectText != tt.expected {
+				t.Errorf("\nResult: %q\nExpected: %q", result.CorrectText, tt.expected)
+			}
+			if result.Backend != tt.backend {
+				t.Errorf("Backend = %q, expected %q", result.Backend, tt.backend)
+			}
+			if len(result.Sentences) != len(tt.texts) || joinSentences(result.Sentences) != tt.expected {
+				t.Errorf("Sentences = %+v, expected one per text joining into %q", result.Sentences, tt.expected)
+			}
+		})
+	}
+
+	if _, err := NewCorrector("unknown", 0); err == nil {
+		t.Errorf("NewCorrector() should fail for an unknown backend")
+	}
+}
+
+func TestMarkupGrammar(t *testing.T) {
+	if Backend != "rules" {
+		t.Skipf("Requires the 'rules' backend (GEC_BACKEND=%q)", Backend)
+	}
+
+	result, err := MarkupGrammar("we should go home. i think so.", DefaultOptions())
+	if err != nil {
+		t.Fatalf("MarkupGrammar() returned an error: %v", err)
+	}
+
+	expText := "We should go home. I think so."
+	if result.CorrectedText != expText {
+		t.Errorf("\nCorrected Text: %q\nExpected: %q", result.CorrectedText, expText)
+	}
+
+	expected := []Markup{
+		{ID: "e6e9db5b473390cf", Index: 0, Length: 2, Message: "Change the capitalization “We”", Category: "GRAMMAR_SUGGESTION", Rule: RuleCapitalization, Replacements: []string{"We"}},
+		{ID: "bc93cdfd4a50605c", Index: 19, Length: 1, Message: "Change the capitalization “I”", Category: "GRAMMAR_SUGGESTION", Rule: RuleCapitalization, Replacements: []string{"I"}},
+	}
+	if !reflect.DeepEqual(result.TextMarkups, expected) {
+		t.Errorf("\nResult: %+v\nExpected: %+v", result.TextMarkups, expected)
+	}
+}
+
+func TestMarkupGrammarBatch(t *testing.T) {
+	if Backend != "rules" {
+		t.Skipf("Requires the 'rules' backend (GEC_BACKEND=%q)", Backend)
+	}
+
+	docs := []GecBatchDocument{
+		{ID: "a", Text: "we should go.\n\ni think so.\n"},
+		{ID: "b", Text: "  "},
+		{ID: "c", Text: "\nhello world"},
+		{ID: "d", Text: "It is fine."},
+	}
+	expected := []struct {
+		corrected string
+		hasErr    bool
+	}{
+		{corrected: "We should go.\n\nI think so.\n"},
+		{hasErr: true},
+		{corrected: "\nHello world"},
+		{corrected: "It is fine."},
+	}
+
+	result := MarkupGrammarBatch(docs, DefaultOptions())
+	if len(result.Results) != len(docs) {
+		t.Fatalf("Got %d results, expected %d", len(result.Results), len(docs))
+	}
+	for i, res := range result.Results {
+		if res.ID != docs[i].ID {
+			t.Errorf("Result[%d] ID = %q, expected %q", i, res.ID, docs[i].ID)
+		}
+		if expected[i].hasErr {
+			if res.Error == "" || res.GecResponse != nil {
+				t.Errorf("Result[%d] expected an error, got %+v", i, res)
+			}
+			continue
+		}
+		if res.Error != "" {
+			t.Errorf("Result[%d] returned an error: %v", i, res.Error)
+			continue
+		}
+		if res.CorrectedText != expected[i].corrected {
+			t.Errorf("Result[%d]\nCorrected Text: %q\nExpected: %q", i, res.CorrectedText, expected[i].corrected)
+		}
+	}
+}
+
+// Rules backend that scores every word it generates, with less confidence in the pronoun "I"
+type scoredCorrector struct {
+	ruleCorrector
+}
+
+func (sc *scoredCorrector) Correct(allTexts []string, opts DecodeOptions) GrammarResult {
+	result := sc.ruleCorrector.Correct(allTexts, opts)
+	for i := range result.Sentences {
+		sent := &result.Sentences[i]
+		if sent.Group < 0 {
+			continue
+		}
+		for _, loc := range regexp.MustCompile(`\S+`).FindAllStringIndex(sent.Text, -1) {
+			logProb := math.Log(0.9)
+			if sent.Text[loc[0]:loc[1]] == "I" {
+				logProb = math.Log(0.4)
+			}
+			sent.Tokens = append(sent.Tokens, TokenScore{Start: loc[0], End: loc[1], LogProb: logProb})
+		}
+	}
+	return result
+}
+
+func TestMarkupGrammarBatchConfidence(t *testing.T) {
+	if defaultLanguage() == nil {
+		t.Skip("languages not loaded")
+	}
+
+	// English, corrected by the scored backend
+	ch := make(chan WorkItem, ChanCapacity)
+	defer close(ch)
+	go func() {
+		corrector := &scoredCorrector{ruleCorrector{rules: true}}
+		for item := range ch {
+			item.Ch <- corrector.Correct(item.AllTexts, item.Decoding)
+		}
+	}()
+	lang := *defaultLanguage()
+	lang.channels = []chan WorkItem{ch}
+
+	opts := DefaultOptions()
+	opts.Language = &lang
+	opts.DetectLanguage, opts.AutoLanguage = false, false
+	opts.MinConfidence = 0.5
+
+	// The 0.4 suggestions for "i" are hidden in the batch just as they are for each document on its own
+	docs := []GecBatchDocument{{ID: "a", Text: "we should go.\n\ni think so."}, {ID: "b", Text: "he is here. i am too."}}
+	batch := MarkupGrammarBatch(docs, opts)
+	for i, doc := range docs {
+		single, err := MarkupGrammar(doc.Text, opts)
+		if err != nil {
+			t.Fatalf("MarkupGrammar() returned an error: %v", err)
+		}
+		res := batch.Results[i]
+		if res.Error != "" {
+			t.Fatalf("Result[%d] returned an error: %v", i, res.Error)
+		}
+		if len(res.TextMarkups) != 1 || res.TextMarkups[0].Confidence == nil || *res.TextMarkups[0].Confidence != 0.9 {
+			t.Errorf("Result[%d]\nMarkups: %+v\nExpected only the 0.9 suggestion", i, res.TextMarkups)
+		}
+		if !reflect.DeepEqual(res.TextMarkups, single.TextMarkups) {
+			t.Errorf("Result[%d]\nResult: %+v\nExpected: %+v", i, res.TextMarkups, single.TextMarkups)
+		}
+	}
+}
+
+func TestWaitBatchGroup(t *testing.T) {
+	if Backend != "rules" {
+		t.Skipf("Requires the 'rules' backend (GEC_BACKEND=%q)", Backend)
+	}
+
+	var group []batchDoc
+	for d, text := range []string{"we should go.\n\ni think so.", "it is late.", "he is here. she is too."} {
+		group = append(group, batchDoc{index: d, text: text, opts: DefaultOptions(), allTexts: PreprocessText(text, DefaultOptions(), nil)})
+	}
+	allTexts, _, ranges := joinBatchTexts(group)
+	expected := []string{"We should go.\n\nI think so.", "It is late.", "He is here. She is too."}
+
+	tests := []struct {
+		name   string
+		change func(sentences []SentenceResult)
+	}{
+		{
+			name:   "Separators kept",
+			change: func(sentences []SentenceResult) {},
+		},
+		{
+			// The separator between the first two documents is a text of its own
+			name:   "Separator lost",
+			change: func(sentences []SentenceResult) { sentences[ranges[0].end].Text = "\n" },
+		},
+		{
+			// The last document has to be sent again on its own
+			name: "Sentences merged across documents",
+			change: func(sentences []SentenceResult) {
+				sentences[ranges[1].end].Text = ""
+				sentences[ranges[2].start].Text = ""
+				sentences[ranges[2].start].Merged = true
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := (&ruleCorrector{rules: true}).Correct(allTexts, DefaultDecodeOptions())
+			tt.change(result.Sentences)
+			result.CorrectText = joinSentences(result.Sentences)
+			item := WorkItem{AllTexts: allTexts, Ch: make(chan GrammarResult, 1)}
+			item.Ch <- result
+
+			corrected, sentences, results, errs := waitBatchGroup(item, nil, group, ranges)
+			for d := range group {
+				if errs[d] != nil || results[d] == nil {
+					t.Errorf("Document %d returned an error: %v", d, errs[d])
+				}
+				// Each document keeps the model's correction of its own sentences
+				if joined := joinSentences(sentences[d]); joined != expected[d] {
+					t.Errorf("Document %d sentences\nResult: %q\nExpected: %q", d, joined, expected[d])
+				}
+			}
+			if !reflect.DeepEqual(corrected, expected) {
+				t.Errorf("\nResult: %q\nExpected: %q", corrected, expected)
+			}
+		})
+	}
+}
+
+func TestMarkupGrammarStream(t *testing.T) {
+	if Backend != "rules" {
+		t.Skipf("Requires the 'rules' backend (GEC_BACKEND=%q)", Backend)
+	}
+
+	text := "we should go home.  i think so.\n\nIt is late."
+	var sentences []GecStreamSentence
+	var summary *GecStreamSummary
+	err := MarkupGrammarStream(text, DefaultOptions(), func(event any) error {
+		switch ev := event.(type) {
+		case GecStreamSentence:
+			sentences = append(sentences, ev)
+		case GecStreamSummary:
+			summary = &ev
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("MarkupGrammarStream() returned an error: %v", err)
+	}
+	if len(sentences) != 3 {
+		t.Fatalf("Got %d sentences, expected 3", len(sentences))
+	}
+	if summary == nil {
+		t.Fatalf("Stream is missing the summary record")
+	}
+
+	// Markups from every sentence should match the non-streamed response
+	var markups []Markup
+	for _, sent := range sentences {
+		markups = append(markups, sent.TextMarkups...)
+	}
+	expected := []Markup{
+		{ID: "e6e9db5b473390cf", Index: 0, Length: 2, Message: "Change the capitalization “We”", Category: "GRAMMAR_SUGGESTION", Rule: RuleCapitalization, Replacements: []string{"We"}},
+		{ID: "026f9349fbc60944", Index: 20, Length: 1, Message: "Change the capitalization “I”", Category: "GRAMMAR_SUGGESTION", Rule: RuleCapitalization, Replacements: []string{"I"}},
+	}
+	if !reflect.DeepEqual(markups, expected) {
+		t.Errorf("\nResult: %+v\nExpected: %+v", markups, expected)
+	}
+
+	expText := "We should go home.  I think so.\n\nIt is late."
+	if summary.CorrectedText != expText {
+		t.Errorf("\nCorrected Text: %q\nExpected: %q", summary.CorrectedText, expText)
+	}
+}
+
+func TestMarkupGrammarStreamWindows(t *testing.T) {
+	if Backend != "rules" {
+		t.Skipf("Requires the 'rules' backend (GEC_BACKEND=%q)", Backend)
+	}
+	defer func(window int) { StreamWindow = window }(StreamWindow)
+	StreamWindow = 2
+
+	// Sentences split across three windows should come back as if the text was checked whole
+	text := "we shood go home. i think so. It is late.\n\nteh end is near. i agree."
+	expected, err := MarkupGrammar(text, DefaultOptions())
+	if err != nil {
+		t.Fatalf("MarkupGrammar() returned an error: %v", err)
+	}
+
+	var markups []Markup
+	var summary *GecStreamSummary
+	err = MarkupGrammarStream(text, DefaultOptions(), func(event any) error {
+		switch ev := event.(type) {
+		case GecStreamSentence:
+			markups = append(markups, ev.TextMarkups...)
+		case GecStreamSummary:
+			summary = &ev
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("MarkupGrammarStream() returned an error: %v", err)
+	}
+	if summary == nil {
+		t.Fatalf("Stream is missing the summary record")
+	}
+	if !reflect.DeepEqual(markups, expected.TextMarkups) {
+		t.Errorf("\nResult: %+v\nExpected: %+v", markups, expected.TextMarkups)
+	}
+	if summary.CorrectedText != expected.CorrectedText {
+		t.Errorf("\nCorrected Text: %q\nExpected: %q", summary.CorrectedText, expected.CorrectedText)
+	}
+}
+
+func TestOwnedMisspells(t *testing.T) {
+	// "Ab cd. Ef gh.  Ij." with the sentences at runes 0-6, 7-13 & 15-18
+	spans := []textSpan{{Index: 0, Length: 6}, {Index: 7, Length: 6}, {Index: 15, Length: 3}}
+	misspells := []Misspell{
+		{Index: 3, Length: 2},  // Inside the first sentence
+		{Index: 4, Length: 5},  // Runs into the second
+		{Index: 13, Length: 1}, // Between the second & third
+		{Index: 16, Length: 2},
+	}
+
+	if crossesSentence(misspells, spans[2]) || !crossesSentence(misspells, spans[1]) {
+		t.Errorf("Only the second sentence has a misspelling running into it")
+	}
+
+	tests := []struct {
+		i, j  int
+		owned []Misspell
+		stray []Misspell
+	}{
+		{i: 0, j: 2, owned: []Misspell{{Index: 3, Length: 2}, {Index: 4, Length: 5}}, stray: []Misspell{{Index: 13, Length: 1}}},
+		{i: 2, j: 3, owned: []Misspell{{Index: 1, Length: 2}}},
+	}
+	for _, tt := range tests {
+		owned, stray := ownedMisspells(misspells, spans, tt.i, tt.j)
+		if !reflect.DeepEqual(owned, tt.owned) || !reflect.DeepEqual(stray, tt.stray) {
+			t.Errorf("Sentences %d-%d\nResult: %+v %+v\nExpected: %+v %+v", tt.i, tt.j, owned, stray, tt.owned, tt.stray)
+		}
+	}
+}
+
+func TestMarkupGrammarOptions(t *testing.T) {
+	if Backend != "rules" {
+		t.Skipf("Requires the 'rules' backend (GEC_BACKEND=%q)", Backend)
+	}
+	off := false
+	text := "we shood go home."
+
+	tests := []struct {
+		name       string
+		options    GecOptions
+		categories []string
+		expText    string
+	}{
+		{
+			name:       "Defaults",
+			options:    GecOptions{},
+			categories: []string{CategoryGrammar, CategorySpelling},
+			expText:    "We shood go home.",
+		},
+		{
+			name:       "Spelling off",
+			options:    GecOptions{Spelling: &off},
+			categories: []string{CategoryGrammar},
+			expText:    "We shood go home.",
+		},
+		{
+			name:       "Grammar off",
+			options:    GecOptions{Grammar: &off},
+			categories: []string{CategorySpelling},
+			expText:    text,
+		},
+		{
+			name:       "Filter categories",
+			options:    GecOptions{Categories: []string{"spelling_mistake"}},
+			categories: []string{CategorySpelling},
+			expText:    "We shood go home.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := tt.options.Resolve()
+			if err != nil {
+				t.Fatalf("Resolve() returned an error: %v", err)
+			}
+			result, err := MarkupGrammar(text, opts)
+			if err != nil {
+				t.Fatalf("MarkupGrammar() returned an error: %v", err)
+			}
+			if result.CorrectedText != tt.expText {
+				t.Errorf("\nCorrected Text: %q\nExpected: %q", result.CorrectedText, tt.expText)
+			}
+
+			var categories []string
+			for _, m := range result.TextMarkups {
+				categories = append(categories, m.Category)
+			}
+			if !reflect.DeepEqual(categories, tt.categories) {
+				t.Errorf("\nCategories: %v\nExpected: %v", categories, tt.categories)
+			}
+		})
+	}
+
+	if _, err := (GecOptions{Categories: []string{"TYPO"}}).Resolve(); err == nil {
+		t.Errorf("Resolve() should fail for an unknown category")
+	}
+	tooHigh := 1.5
+	if _, err := (GecOptions{MinConfidence: &tooHigh}).Resolve(); err == nil {
+		t.Errorf("Resolve() should fail for a min_confidence above 1")
+	}
+	if _, err := (GecOptions{LowConfidence: "blur"}).Resolve(); err == nil {
+		t.Errorf("Resolve() should fail for an unknown low_confidence mode")
+	}
+}
+
+func TestResolveDecoding(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	floatPtr := func(f float64) *float64 { return &f }
+	tests := []struct {
+		name     string
+		options  GecOptions
+		expected DecodeOptions
+		fails    bool
+	}{
+		// No n-gram size by default, so only the runtime's ABABAB guard stops loops
+		{"Defaults", GecOptions{}, DecodeOptions{BeamWidth: 1, NumBest: 1, LengthPenalty: 1.0, NoRepeatNgram: 0}, false},
+		{"N-gram size", GecOptions{NoRepeatNgram: intPtr(4)}, DecodeOptions{BeamWidth: 1, NumBest: 1, LengthPenalty: 1.0, NoRepeatNgram: 4}, false},
+		{"Beam search", GecOptions{BeamWidth: intPtr(4), NumBest: intPtr(2), LengthPenalty: floatPtr(0.5), NoRepeatNgram: intPtr(0)}, DecodeOptions{BeamWidth: 4, NumBest: 2, LengthPenalty: 0.5, NoRepeatNgram: 0}, false},
+		{"Beam too wide", GecOptions{BeamWidth: intPtr(MaxBeamWidth + 1)}, DecodeOptions{}, true},
+		{"More best than beams", GecOptions{BeamWidth: intPtr(2), NumBest: intPtr(3)}, DecodeOptions{}, true},
+		{"N-best without beams", GecOptions{NumBest: intPtr(2)}, DecodeOptions{}, true},
+		{"Length penalty", GecOptions{LengthPenalty: floatPtr(5)}, DecodeOptions{}, true},
+		{"Negative n-gram", GecOptions{NoRepeatNgram: intPtr(-1)}, DecodeOptions{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := tt.options.Resolve()
+			if tt.fails {
+				if err == nil {
+					t.Errorf("Resolve() should fail for %+v", tt.options)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() returned an error: %v", err)
+			}
+			if opts.Decoding != tt.expected {
+				t.Errorf("\nResult: %+v\nExpected: %+v", opts.Decoding, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNarrowedDecoding(t *testing.T) {
+	requested := DecodeOptions{BeamWidth: 8, NumBest: 4, LengthPenalty: 1.0}
+	tests := []struct {
+		name     string
+		used     DecodeOptions
+		expected *DecodingReport
+	}{
+		{"As requested", requested, nil},
+		{"Not reported", DecodeOptions{}, nil},
+		{"Narrowed beams", DecodeOptions{BeamWidth: 3, NumBest: 3, LengthPenalty: 1.0}, &DecodingReport{BeamWidth: 3, NumBest: 3}},
+		{"Narrowed to greedy", DecodeOptions{BeamWidth: 1, NumBest: 1, LengthPenalty: 1.0}, &DecodingReport{BeamWidth: 1, NumBest: 1}},
+	}
+
+	for _, tt := range tests {
+		result := narrowedDecoding(requested, &GrammarResult{Decoding: tt.used})
+		if (result == nil) != (tt.expected == nil) || (result != nil && *result != *tt.expected) {
+			t.Errorf("%s:\nResult: %+v\nExpected: %+v", tt.name, result, tt.expected)
+		}
+	}
+}
+
+func TestMarkupReplacements(t *testing.T) {
+	if Backend != "rules" {
+		t.Skipf("Requires the 'rules' backend (GEC_BACKEND=%q)", Backend)
+	}
+
+	result, err := MarkupGrammar("we shood go home.", DefaultOptions())
+	if err != nil {
+		t.Fatalf("MarkupGrammar() returned an error: %v", err)
+	}
+	for _, m := range result.TextMarkups {
+		if m.Replacements == nil {
+			t.Errorf("Markup %+v has nil replacements", m)
+		}
+		if len(m.Replacements) > MaxReplacements {
+			t.Errorf("Markup %+v has more than %d replacements", m, MaxReplacements)
+		}
+		if m.Category == CategorySpelling && len(m.Replacements) == 0 {
+			t.Errorf("Spelling markup %+v is missing suggestions", m)
+		}
+	}
+}
+
+func TestAddToDiffsInsert(t *testing.T) {
+	var diffs []Markup
+	addToDiffs(&diffs, 5, 0, ",", "Add comma “,”", "Grammar", RuleAddComma, nil)
+	markups, _, _, err := FormatToJson("Hello world", diffs, nil)
+	if err != nil {
+		t.Fatalf("FormatToJson() returned an error: %v", err)
+	}
+	if len(markups) != 1 || !reflect.DeepEqual(markups[0].Replacements, []string{", "}) {
+		t.Errorf("Insertion should keep the marked character, got %+v", markups)
+	}
+}
+
+func TestOffsetEncoding(t *testing.T) {
+	off := false
+	text := "Ünïcode 😀 we shood go."
+
+	tests := []struct {
+		encoding string
+		expected [][2]int // Index & length of the emoji then the typo
+	}{
+		{encoding: "", expected: [][2]int{{8, 1}, {13, 5}}},
+		{encoding: "runes", expected: [][2]int{{8, 1}, {13, 5}}},
+		{encoding: "bytes", expected: [][2]int{{10, 4}, {18, 5}}},
+		{encoding: "UTF16", expected: [][2]int{{8, 2}, {14, 5}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			opts, err := (GecOptions{Grammar: &off, OffsetEncoding: tt.encoding}).Resolve()
+			if err != nil {
+				t.Fatalf("Resolve() returned an error: %v", err)
+			}
+			result, err := MarkupGrammar(text, opts)
+			if err != nil {
+				t.Fatalf("MarkupGrammar() returned an error: %v", err)
+			}
+
+			var offsets [][2]int
+			for _, m := range result.TextMarkups {
+				offsets = append(offsets, [2]int{m.Index, m.Length})
+			}
+			if !reflect.DeepEqual(offsets, tt.expected) {
+				t.Errorf("\nOffsets: %v\nExpected: %v", offsets, tt.expected)
+			}
+
+			// Offsets should round trip back to runes
+			decoded, err := decodeMarkupOffsets(text, result.TextMarkups, opts.OffsetEncoding)
+			if err != nil {
+				t.Fatalf("decodeMarkupOffsets() returned an error: %v", err)
+			}
+			if decoded[1].Index != 13 || decoded[1].Length != 5 {
+				t.Errorf("Decoded typo at %d:%d, expected 13:5", decoded[1].Index, decoded[1].Length)
+			}
+		})
+	}
+
+	if _, err := (GecOptions{OffsetEncoding: "utf32"}).Resolve(); err == nil {
+		t.Errorf("Resolve() should fail for an unknown offset encoding")
+	}
+	if _, err := decodeMarkupOffsets(text, []Markup{{Index: 9, Length: 1}}, OffsetUTF16); err == nil {
+		t.Errorf("decodeMarkupOffsets() should fail for an offset inside a surrogate pair")
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	text := "“we\x07 said” it’s fine"
+	clean, norm := normalizeText(text)
+	if clean != "\"we said\" it's fine" {
+		t.Errorf("\nClean Text: %q", clean)
+	}
+
+	tests := []struct {
+		name      string
+		start     int
+		end       int
+		corrected string
+		expected  string
+	}{
+		{name: "Unchanged", start: 0, end: 18, corrected: clean, expected: text},
+		{name: "Edited", start: 0, end: 18, corrected: "\"We said\" it's fine.", expected: "“We\x07 said” it’s fine."},
+		{name: "Span", start: 10, end: 18, corrected: "it's fine", expected: "it’s fine"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := norm.restore(tt.start, tt.end, tt.corrected)
+			if result != tt.expected {
+				t.Errorf("\nResult: %q\nExpected: %q", result, tt.expected)
+			}
+		})
+	}
+
+	// "said" is after the dropped control character
+	if ind, ln := norm.mapSpan(4, 4); ind != 5 || ln != 4 {
+		t.Errorf("mapSpan(4, 4) = %d, %d, expected 5, 4", ind, ln)
+	}
+}
+
+func TestMarkupGrammarTypography(t *testing.T) {
+	if Backend != "rules" {
+		t.Skipf("Requires the 'rules' backend (GEC_BACKEND=%q)", Backend)
+	}
+
+	text := "“we shood go,” she said i think."
+	result, err := MarkupGrammar(text, DefaultOptions())
+	if err != nil {
+		t.Fatalf("MarkupGrammar() returned an error: %v", err)
+	}
+
+	expText := "“we shood go,” she said I think."
+	if result.CorrectedText != expText {
+		t.Errorf("\nCorrected Text: %q\nExpected: %q", result.CorrectedText, expText)
+	}
+
+	// Markups point at the caller's characters and can be applied to the original text
+	var ids []string
+	for _, m := range result.TextMarkups {
+		ids = append(ids, m.ID)
+	}
+	applied, _, err := ApplyMarkups(text, result.TextMarkups, ids)
+	if err != nil {
+		t.Fatalf("ApplyMarkups() returned an error: %v", err)
+	}
+	if applied != "“we should go,” she said I think." {
+		t.Errorf("\nApplied: %q\nMarkups: %+v", applied, result.TextMarkups)
+	}
+}
@@ -0,0 +1,143 @@
+//go:build !nogeco
+
+// src/internal/gec/geco.go
+package gec
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/../../native/gec_runtime/include
+#cgo CFLAGS: -I${SRCDIR}/../../native/gec_runtime/third_party
+#cgo CFLAGS: -I${SRCDIR}/../../native/gec_runtime/third_party/onnxruntime/include
+#cgo CFLAGS: -I${SRCDIR}/../../native/gec_runtime/third_party/sentencepiece/include
+
+#cgo LDFLAGS: ${SRCDIR}/../../native/gec_runtime/build/libgec.a
+#cgo LDFLAGS: -L${SRCDIR}/../../native/gec_runtime/third_party/onnxruntime/lib
+#cgo LDFLAGS: -L${SRCDIR}/../../native/gec_runtime/third_party/sentencepiece/lib
+#cgo LDFLAGS: -L${SRCDIR}/../../native/gec_runtime/third_party/icu/lib
+#cgo LDFLAGS: -lonnxruntime -lsentencepiece -lstdc++ -lm -ldl -licuuc -licudata
+
+#include "inference.h"
+#include <stdbool.h>
+*/
+import "C"
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"gec-demo/src/internal/print"
+)
+
+var _ = RegisterCorrector("geco", newGecoCorrector)
+
+// Native ONNX Runtime backend (libgec.a)
+type gecoCorrector struct {
+	geco  unsafe.Pointer
+	gpuId int
+}
+
+func newGecoCorrector(gpuId int) (Corrector, error) {
+	// Allocate a Geco object for the channel
+	geco := C.NewGeco(C.int(LogLevel), C.bool(UseGpu), C.int(gpuId))
+	if geco == nil {
+		return nil, fmt.Errorf("failed initalizing GECO for gpu:%d", gpuId)
+	}
+	return &gecoCorrector{geco: geco, gpuId: gpuId}, nil
+}
+
+func (g *gecoCorrector) Correct(allTexts []string, opts DecodeOptions) GrammarResult {
+	return CorrectGrammar(&g.geco, g.gpuId, allTexts, opts)
+}
+
+func (g *gecoCorrector) Close() {
+	C.FreeGeco(g.geco)
+	g.geco = nil
+}
+
+func CorrectGrammar(geco *unsafe.Pointer, gpuId int, all_texts []string, opts DecodeOptions) GrammarResult {
+	chanTime := time.Now()
+	gram_result := GrammarResult{
+		CorrectText: "",
+		GpuId:       gpuId,
+		Backend:     "geco",
+		Err:         nil,
+		ServiceTime: 0.0,
+	}
+
+	// Check if a pointer is nil
+	if geco == nil || *geco == nil {
+		gram_result.Err = fmt.Errorf("Geco pointer is nil")
+		return gram_result
+	}
+
+	// Convert Go strings to C strings
+	cTexts, ctext_cleanup := goStringsToC(all_texts)
+	defer ctext_cleanup()
+
+	// Run grammar correction
+	c_opts := C.GecoDecodeOptions{
+		beam_width:      C.int(opts.BeamWidth),
+		num_best:        C.int(opts.NumBest),
+		length_penalty:  C.float(opts.LengthPenalty),
+		no_repeat_ngram: C.int(opts.NoRepeatNgram),
+	}
+	var c_result C.GecoResult
+	C.GecoRun(*geco, &cTexts[0], C.int(len(all_texts)), c_opts, &c_result)
+	defer C.FreeGecoResult(&c_result)
+	gram_result.Decoding = opts
+	gram_result.Decoding.BeamWidth = int(c_result.beam_width)
+	gram_result.Decoding.NumBest = int(c_result.num_best)
+	if c_result.sentences == nil {
+		gram_result.Err = fmt.Errorf("failed running 'C.GecoRun()' and returned no sentences")
+		return gram_result
+	}
+
+	// Convert each C sentence to Go
+	for _, sent := range unsafe.Slice(c_result.sentences, int(c_result.num_sentences)) {
+		result := SentenceResult{
+			Merged:       sent.text == nil,
+			Group:        int(sent.group),
+			InputTokens:  int(sent.input_tokens),
+			OutputTokens: int(sent.output_tokens),
+		}
+		if sent.text != nil {
+			result.Text = C.GoString(sent.text)
+		}
+		for _, tok := range unsafe.Slice(sent.tokens, int(sent.num_tokens)) {
+			result.Tokens = append(result.Tokens, TokenScore{Start: int(tok.begin), End: int(tok.end), LogProb: float64(tok.logprob)})
+		}
+		for _, alt := range unsafe.Slice(sent.alternatives, int(sent.num_alternatives)) {
+			result.Alternatives = append(result.Alternatives, C.GoString(alt))
+		}
+		gram_result.Sentences = append(gram_result.Sentences, result)
+	}
+	gram_result.CorrectText = joinSentences(gram_result.Sentences)
+	print.Info("GEC Result: %q", gram_result.CorrectText)
+
+	duration := time.Since(chanTime).Seconds()
+	gram_result.ServiceTime = duration
+	return gram_result
+}
+
+// Converts go strings to C strings and returns a cleanup function
+func goStringsToC(strings []string) ([]*C.char, func()) {
+	cstrs := make([]*C.char, len(strings))
+	for i, s := range strings {
+		cstrs[i] = C.CString(s)
+	}
+
+	// Return a cleanup function
+	cleanup := func() {
+		print.Debug("Cleaning C strings")
+		for _, cstr := range cstrs {
+			cFree(cstr)
+		}
+	}
+	return cstrs, cleanup
+}
+
+// Frees memory that allocated by C.CString / C.malloc
+func cFree(p *C.char) {
+	if p != nil {
+		C.free(unsafe.Pointer(p))
+	}
+}
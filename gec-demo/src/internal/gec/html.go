@@ -0,0 +1,169 @@
+// src/internal/gec/html.go
+package gec
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// Elements that start a new block of prose, so sentences never run across them
+	htmlBlockElements = map[string]bool{
+		"address": true, "article": true, "aside": true, "blockquote": true, "body": true, "br": true, "caption": true,
+		"center": true, "dd": true, "details": true, "dialog": true, "div": true, "dl": true, "dt": true, "fieldset": true,
+		"figcaption": true, "figure": true, "footer": true, "form": true, "h1": true, "h2": true, "h3": true, "h4": true,
+		"h5": true, "h6": true, "head": true, "header": true, "hr": true, "html": true, "legend": true, "li": true,
+		"main": true, "nav": true, "ol": true, "option": true, "p": true, "pre": true, "section": true, "summary": true, "table": true,
+		"tbody": true, "td": true, "tfoot": true, "th": true, "thead": true, "title": true, "tr": true, "ul": true,
+	}
+
+	// Elements whose content is never prose. Skipped up to their closing tag
+	htmlSkipElements = map[string]bool{
+		"code": true, "iframe": true, "kbd": true, "math": true, "noscript": true, "object": true, "pre": true,
+		"samp": true, "script": true, "style": true, "svg": true, "template": true, "textarea": true, "var": true,
+	}
+
+	// Skipped inline elements that stand for a word of the sentence. The model sees their text as a `code` span
+	htmlInlineCode = map[string]bool{"code": true, "kbd": true, "samp": true, "var": true}
+
+	htmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	reHTMLTag       = regexp.MustCompile(`<[^>]*>`)
+	reHTMLSpace     = regexp.MustCompile(`\s+`)
+)
+
+// Extract the prose of an HTML document from its text nodes. Tags, comments and the content of
+// code, script & style elements are left out, and runs of whitespace read as a single space
+func parseHTML(text string) *proseDocument {
+	d := &proseDocument{raw: text, escape: htmlTextEscaper.Replace}
+
+	i := 0
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == '<':
+			end, name, closing, ok := scanHTMLTag(text, i)
+			if !ok {
+				break // A lone `<` is text
+			}
+			if htmlBlockElements[name] {
+				d.endBlock()
+			}
+			if htmlSkipElements[name] && !closing && !strings.HasSuffix(text[i:end], "/>") {
+				contentStart := end
+				end = skipHTMLElement(text, end, name)
+				if htmlInlineCode[name] {
+					d.addCode(text[contentStart:end])
+				}
+			}
+			i = end
+			continue
+
+		case c == '&':
+			if m := reEntity.FindString(text[i:]); m != "" {
+				if r := []rune(html.UnescapeString(m)); len(r) == 1 {
+					d.addRune(r[0], i, i+len(m))
+					i += len(m)
+					continue
+				}
+			}
+
+		case isHTMLSpace(c):
+			end := i
+			for end < len(text) && isHTMLSpace(text[end]) {
+				end++
+			}
+			if len(d.prose) > 0 && !d.endsBlock() && d.prose[len(d.prose)-1] != ' ' {
+				d.addRune(' ', i, end)
+			}
+			i = end
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(text[i:])
+		d.addRaw(i, i+size)
+		i += size
+	}
+	return d.finish()
+}
+
+// Add the text of an inline code element as a `code` span. It can't be edited, as it isn't in the raw text
+func (d *proseDocument) addCode(element string) {
+	code := html.UnescapeString(reHTMLTag.ReplaceAllString(element, ""))
+	code = strings.TrimSpace(reHTMLSpace.ReplaceAllString(code, " "))
+	if code == "" || strings.Contains(code, "`") {
+		return
+	}
+	for _, r := range "`" + code + "`" {
+		d.addRune(r, -1, -1)
+	}
+}
+
+// Find the end of the tag, comment or declaration starting at `i`, and the lowercased element name of a tag.
+// Quoted attribute values may hold `>`. Fails if `i` doesn't start a tag
+func scanHTMLTag(text string, i int) (end int, name string, closing bool, ok bool) {
+	rest := text[i:]
+	switch {
+	case strings.HasPrefix(rest, "<!--"):
+		if k := strings.Index(rest[4:], "-->"); k != -1 {
+			return i + 4 + k + 3, "", false, true
+		}
+		return len(text), "", false, true
+	case strings.HasPrefix(rest, "<!"), strings.HasPrefix(rest, "<?"):
+		if k := strings.IndexByte(rest, '>'); k != -1 {
+			return i + k + 1, "", false, true
+		}
+		return 0, "", false, false
+	}
+
+	k := 1
+	if k < len(rest) && rest[k] == '/' {
+		closing = true
+		k++
+	}
+	nameStart := k
+	for k < len(rest) && (rest[k] < utf8.RuneSelf && (unicode.IsLetter(rune(rest[k])) || unicode.IsDigit(rune(rest[k])) || rest[k] == '-')) {
+		k++
+	}
+	if k == nameStart || !unicode.IsLetter(rune(rest[nameStart])) {
+		return 0, "", false, false
+	}
+	name = strings.ToLower(rest[nameStart:k])
+
+	var quote byte
+	for ; k < len(rest); k++ {
+		switch {
+		case quote != 0:
+			if rest[k] == quote {
+				quote = 0
+			}
+		case rest[k] == '"' || rest[k] == '\'':
+			quote = rest[k]
+		case rest[k] == '>':
+			return i + k + 1, name, closing, true
+		}
+	}
+	return 0, "", false, false
+}
+
+// Offset after the closing tag of element `name`, or the end of the text if it is never closed
+func skipHTMLElement(text string, start int, name string) int {
+	lower := strings.ToLower(text[start:])
+	for k := 0; ; {
+		found := strings.Index(lower[k:], "</"+name)
+		if found == -1 {
+			return len(text)
+		}
+		k += found
+		if end, tag, closing, ok := scanHTMLTag(text, start+k); ok && closing && tag == name {
+			return end
+		}
+		k += 2
+	}
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
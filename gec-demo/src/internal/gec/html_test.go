@@ -0,0 +1,113 @@
+package gec
+
+import (
+	"testing"
+)
+
+func TestParseHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{name: "Inline elements", text: "<p>some <b>bold</b> and <a href=\"/x?a=1&amp;b=2\">linked</a> text</p>", expected: "some bold and linked text"},
+		{name: "Block elements", text: "<h1>Title</h1><p>one</p><ul><li>two</li><li>three</li></ul>", expected: "Title\n\none\n\ntwo\n\nthree"},
+		{name: "Line breaks", text: "line one<br>line two<br/>", expected: "line one\n\nline two"},
+		{name: "Whitespace", text: "<div>\n  lots   of\n\tspace  \n</div>", expected: "lots of space"},
+		{name: "Entities", text: "fish &amp; chips &lt;3 &nbsp;caf&eacute; &#8212; &bogus;", expected: "fish & chips <3 \u00a0caf\u00e9 \u2014 &bogus;"},
+		{name: "Skipped elements", text: "run <code>go test</code> or<pre>\n<b>code</b>\n</pre><script>if (a < b) {}</script><style>p{}</style>done", expected: "run `go test` or\n\ndone"},
+		{name: "Comments & declarations", text: "<!DOCTYPE html><!-- a <p> note -->text<?xml x?>", expected: "text"},
+		{name: "Quoted attributes", text: "<img alt=\"a > b\" src='c'>pic", expected: "pic"},
+		{name: "Lone brackets", text: "a < b and c<3", expected: "a < b and c<3"},
+	}
+
+	for _, tt := range tests {
+		doc := parseHTML(tt.text)
+		if string(doc.prose) != tt.expected {
+			t.Errorf("%s:\nResult: %q\nExpected: %q", tt.name, string(doc.prose), tt.expected)
+		}
+	}
+}
+
+func TestMarkupGrammarHTML(t *testing.T) {
+	if defaultLanguage() == nil {
+		t.Skip("languages not loaded")
+	}
+
+	text := "<p>i like <b>fish</b> &amp; chips</p><p class=\"x\">we shood go. <code>i teh</code></p>"
+	opts, err := GecOptions{Format: "html"}.Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() returned an error: %v", err)
+	}
+	result, err := MarkupGrammar(text, opts)
+	if err != nil {
+		t.Fatalf("MarkupGrammar() returned an error: %v", err)
+	}
+
+	expected := "<p>I like <b>fish</b> &amp; chips</p><p class=\"x\">We shood go. <code>i teh</code></p>"
+	if result.CorrectedText != expected {
+		t.Errorf("\nResult: %q\nExpected: %q", result.CorrectedText, expected)
+	}
+	expMarkups := []string{"i", "we", "shood"}
+	if len(result.TextMarkups) != len(expMarkups) {
+		t.Fatalf("\nResult: %+v\nExpected markups on %q", result.TextMarkups, expMarkups)
+	}
+	for i, m := range result.TextMarkups {
+		if covered := runeSubstring(text, m.Index, m.Length); covered != expMarkups[i] {
+			t.Errorf("Markup %d covers %q, expected %q", i, covered, expMarkups[i])
+		}
+	}
+
+	// Entities standing alone as a word are never spell checked
+	text = "<p>a &lt; b and i think</p>"
+	result, err = MarkupGrammar(text, opts)
+	if err != nil {
+		t.Fatalf("MarkupGrammar() returned an error: %v", err)
+	}
+	expMarkups = []string{"a", "i"}
+	if len(result.TextMarkups) != len(expMarkups) {
+		t.Fatalf("\nResult: %+v\nExpected markups on %q", result.TextMarkups, expMarkups)
+	}
+	for i, m := range result.TextMarkups {
+		if covered := runeSubstring(text, m.Index, m.Length); covered != expMarkups[i] {
+			t.Errorf("Markup %d covers %q, expected %q", i, covered, expMarkups[i])
+		}
+	}
+
+	// Documents without prose come back unchanged
+	codeOnly := "<pre>code</pre>"
+	result, err = MarkupGrammar(codeOnly, opts)
+	if err != nil {
+		t.Fatalf("MarkupGrammar() returned an error for a document without prose: %v", err)
+	}
+	if result.CorrectedText != codeOnly || len(result.TextMarkups) != 0 {
+		t.Errorf("No prose:\nResult: %+v\nExpected: %q", result, codeOnly)
+	}
+	batch := MarkupGrammarBatch([]GecBatchDocument{{ID: "a", Text: codeOnly}}, opts)
+	if batch.Results[0].Error != "" || batch.Results[0].CorrectedText != codeOnly {
+		t.Errorf("Batch without prose:\nResult: %+v\nExpected: %q", batch.Results[0], codeOnly)
+	}
+}
+
+func TestProseDocumentRestoreHTML(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		corrected string // Corrected prose
+		expected  string
+	}{
+		{name: "Escape inserted text", text: "<p>salt pepper</p>", corrected: "salt & pepper <3", expected: "<p>salt &amp; pepper &lt;3</p>"},
+		{name: "Replace an entity", text: "a &amp; b", corrected: "a and b", expected: "a and b"},
+		{name: "Keep entities", text: "caf&eacute; is ok", corrected: "caf\u00e9 is OK", expected: "caf&eacute; is OK"},
+		{name: "Across a tag", text: "<i>a</i> b", corrected: "c", expected: "<i>a</i> b"},
+		{name: "Collapsed whitespace", text: "a \n  b", corrected: "a-b", expected: "a-b"},
+		{name: "Inline code", text: "use <code>x</code> now", corrected: "Use `y` now", expected: "Use <code>x</code> now"},
+	}
+
+	for _, tt := range tests {
+		result := parseHTML(tt.text).restore(tt.corrected)
+		if result != tt.expected {
+			t.Errorf("%s:\nResult: %q\nExpected: %q", tt.name, result, tt.expected)
+		}
+	}
+}
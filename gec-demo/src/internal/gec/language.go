@@ -0,0 +1,222 @@
+// src/internal/gec/language.go
+package gec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gec-demo/src/internal/langdetect"
+	"gec-demo/src/internal/print"
+	"gec-demo/src/internal/speechtagger"
+)
+
+// English has the embedded dictionary, tagger & model. Other languages are enabled with GEC_LANGUAGES
+const DefaultLanguage = "en"
+
+var (
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+
+	MinLanguageConfidence = 0.8 // Detected languages less likely than this never change how the text is checked
+
+	languageCodeRe  = dictNameRe             // Language codes double as directory names in GEC_DATA_DIR
+	languages       = map[string]*Language{} // Filled by LoadLanguages() at startup, read only afterwards
+	backendChannels = map[string][]chan WorkItem{}
+)
+
+// Everything the pipeline needs to check text in one language
+type Language struct {
+	Code      string
+	Backend   string                 // Corrector backend run on its sentences
+	Sentences *speechtagger.Language // Sentence tokenizer
+	channels  []chan WorkItem        // GEC channels of the backend
+}
+
+// A language listed in GEC_LANGUAGES
+type languageConfig struct {
+	code    string
+	backend string
+}
+
+// Reads GEC_LANGUAGES from env: comma separated language codes to support on top of English,
+// each optionally followed by `=backend`. Languages without a backend use "echo", as the model is English only
+func getLanguageConfig() ([]languageConfig, error) {
+	var configs []languageConfig
+	for _, entry := range strings.Split(os.Getenv("GEC_LANGUAGES"), ",") {
+		code, backend, _ := strings.Cut(entry, "=")
+		code = strings.ToLower(strings.TrimSpace(code))
+		backend = strings.ToLower(strings.TrimSpace(backend))
+		if code == "" || code == DefaultLanguage {
+			continue
+		}
+		if !languageCodeRe.MatchString(code) {
+			return nil, fmt.Errorf("invalid language code %q in GEC_LANGUAGES", code)
+		}
+		if backend == "" {
+			backend = "echo"
+		}
+		configs = append(configs, languageConfig{code: code, backend: backend})
+	}
+	return configs, nil
+}
+
+// Register English and the languages in GEC_LANGUAGES, starting the GEC channels for each backend.
+// Must be called after speechtagger.InitTaggingModel(), which registers the English tokenizer
+func LoadLanguages() error {
+	english, ok := speechtagger.GetLanguage(DefaultLanguage)
+	if !ok {
+		return fmt.Errorf("english sentence tokenizer not loaded")
+	}
+	if err := addLanguage(DefaultLanguage, Backend, english); err != nil {
+		return err
+	}
+	GecoChannels = backendChannels[Backend]
+
+	configs, err := getLanguageConfig()
+	if err != nil {
+		return err
+	}
+	for _, cfg := range configs {
+		// Punkt training data for the sentence tokenizer is optional, see neurosnap/sentences for the JSON format
+		training, err := readLanguageFile(cfg.code, "sentences.json")
+		if err != nil {
+			return err
+		}
+		sents, err := speechtagger.RegisterLanguage(cfg.code, training, nil)
+		if err != nil {
+			return err
+		}
+		if err := addLanguage(cfg.code, cfg.backend, sents); err != nil {
+			return err
+		}
+	}
+	print.Info("LANGUAGES: %s", strings.Join(LanguageCodes(), ", "))
+	return nil
+}
+
+// Read a file from the language's directory in GEC_DATA_DIR. Missing files return nil
+func readLanguageFile(code, name string) ([]byte, error) {
+	dir := GetDataDir()
+	if dir == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(filepath.Join(dir, code, name))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed reading %q data file %s: %w", code, name, err)
+	}
+	return b, nil
+}
+
+// Add a language to the registry, starting its backend's GEC channels if no other language uses them yet
+func addLanguage(code, backend string, sents *speechtagger.Language) error {
+	if _, ok := correctorBackends[backend]; !ok {
+		return fmt.Errorf("unknown GEC backend %q for language %q. Available backends: %s", backend, code, strings.Join(CorrectorNames(), ", "))
+	}
+	channels, ok := backendChannels[backend]
+	if !ok {
+		channels = startChannels(backend)
+		backendChannels[backend] = channels
+	}
+	languages[code] = &Language{Code: code, Backend: backend, Sentences: sents, channels: channels}
+	return nil
+}
+
+// Start `NumChannels` GEC channels, each with a Corrector from the backend
+func startChannels(backend string) []chan WorkItem {
+	channels := make([]chan WorkItem, NumChannels)
+	print.Debug("Total %s GEC Channels: %d", backend, NumChannels)
+
+	for i := range NumChannels {
+		gpuId := i % DeviceCount
+
+		// Buffered channel with a capacity of `ChanCapacity`
+		channels[i] = make(chan WorkItem, ChanCapacity)
+		go ClaimGpu(backend, gpuId, channels[i])
+	}
+	return channels
+}
+
+// Get a supported language by its code
+func GetLanguage(code string) (*Language, error) {
+	lang, ok := languages[code]
+	if !ok {
+		return nil, fmt.Errorf("%w %q. Supported languages: %s", ErrUnsupportedLanguage, code, strings.Join(LanguageCodes(), ", "))
+	}
+	return lang, nil
+}
+
+// Codes of all supported languages
+func LanguageCodes() []string {
+	codes := make([]string, 0, len(languages))
+	for code := range languages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// The language used when a request doesn't set one
+func defaultLanguage() *Language {
+	return languages[DefaultLanguage]
+}
+
+// Code of the language, or the default language if it is nil
+func (l *Language) code() string {
+	if l == nil {
+		return DefaultLanguage
+	}
+	return l.Code
+}
+
+// Split text into sentences. Falls back to the English tokenizer if languages were not loaded
+func (l *Language) splitBySentences(text string) []string {
+	if l == nil || l.Sentences == nil {
+		return speechtagger.SplitBySentences(text)
+	}
+	return l.Sentences.SplitBySentences(text)
+}
+
+// Language detected in a text, and the checks skipped because of it
+type languageDetection struct {
+	code       string
+	confidence float64
+	skipped    []string
+}
+
+// Detect the language of the text. When the request didn't choose a language, check the text in the detected
+// language, or skip the spelling & grammar checks if it confidently isn't one that is supported
+func (opts CheckOptions) detectLanguage(text string) (CheckOptions, languageDetection) {
+	var detected languageDetection
+	if !opts.DetectLanguage {
+		return opts, detected
+	}
+	detected.code, detected.confidence = langdetect.Detect(text)
+	if !opts.AutoLanguage || detected.code == "" || detected.confidence < MinLanguageConfidence {
+		return opts, detected
+	}
+
+	if lang, ok := languages[detected.code]; ok {
+		opts.Language = lang
+		return opts, detected
+	}
+	print.Debug("Text detected as unsupported language %q (%.2f)", detected.code, detected.confidence)
+	if opts.Spelling {
+		opts.Spelling = false
+		detected.skipped = append(detected.skipped, CategorySpelling)
+	}
+	if opts.Grammar {
+		opts.Grammar = false
+		detected.skipped = append(detected.skipped, CategoryGrammar)
+	}
+	return opts, detected
+}
+
+// Report the detected language in a response
+func (d languageDetection) apply(gec_result *GecResponse) {
+	gec_result.DetectedLanguage = d.code
+	gec_result.LanguageConfidence = d.confidence
+	gec_result.SkippedChecks = d.skipped
+}
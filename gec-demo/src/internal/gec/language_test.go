@@ -0,0 +1,119 @@
+package gec
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gec-demo/src/internal/speechtagger"
+)
+
+func TestGetLanguageConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		expected []languageConfig
+		expErr   bool
+	}{
+		{name: "Unset", env: "", expected: nil},
+		{name: "Default backend", env: "de, FR", expected: []languageConfig{{code: "de", backend: "echo"}, {code: "fr", backend: "echo"}}},
+		{name: "Backend", env: "de=rules", expected: []languageConfig{{code: "de", backend: "rules"}}},
+		{name: "English is built in", env: "en=echo,es", expected: []languageConfig{{code: "es", backend: "echo"}}},
+		{name: "Invalid code", env: "../de", expErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("GEC_LANGUAGES", tt.env)
+		result, err := getLanguageConfig()
+		if (err != nil) != tt.expErr {
+			t.Errorf("%s: getLanguageConfig() error = %v, expected error: %v", tt.name, err, tt.expErr)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%s:\nResult: %+v\nExpected: %+v", tt.name, result, tt.expected)
+		}
+	}
+}
+
+func TestMarkupGrammarLanguage(t *testing.T) {
+	if defaultLanguage() == nil {
+		t.Skip("languages not loaded")
+	}
+
+	// A language with no training data, dictionary or model
+	sents, err := speechtagger.RegisterLanguage("xx", nil, nil)
+	if err != nil {
+		t.Fatalf("RegisterLanguage() returned an error: %v", err)
+	}
+	if err := addLanguage("xx", "echo", sents); err != nil {
+		t.Fatalf("addLanguage() returned an error: %v", err)
+	}
+	defer delete(languages, "xx")
+
+	split := sents.SplitBySentences("Hallo Welt. Wie geht es dir?")
+	if len(split) != 2 {
+		t.Errorf("\nSentences: %q\nExpected 2 sentences", split)
+	}
+
+	if _, err := (GecOptions{Language: "tlh"}).Resolve(); !errors.Is(err, ErrUnsupportedLanguage) {
+		t.Errorf("Resolve() error = %v, expected %v", err, ErrUnsupportedLanguage)
+	}
+	opts, err := GecOptions{Language: " XX "}.Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() returned an error: %v", err)
+	}
+
+	// The echo backend leaves the text alone and there is no dictionary to check spelling with
+	text := "we shood go home. i think so."
+	result, err := MarkupGrammar(text, opts)
+	if err != nil {
+		t.Fatalf("MarkupGrammar() returned an error: %v", err)
+	}
+	if result.Language != "xx" || result.CorrectedText != text || len(result.TextMarkups) != 0 {
+		t.Errorf("\nResult: %+v\nExpected no changes for language %q", result, "xx")
+	}
+}
+
+func TestMarkupGrammarDetectLanguage(t *testing.T) {
+	if defaultLanguage() == nil {
+		t.Skip("languages not loaded")
+	}
+	if _, ok := languages["es"]; ok {
+		t.Skip("Spanish is configured in GEC_LANGUAGES")
+	}
+
+	spanish := "hola, me llamo carlos y vivo en madrid con mi familia desde hace diez años."
+	tests := []struct {
+		name        string
+		text        string
+		options     GecOptions
+		expDetected string
+		expSkipped  []string
+	}{
+		{name: "Unsupported language", text: spanish, expDetected: "es", expSkipped: []string{CategorySpelling, CategoryGrammar}},
+		{name: "Chosen language", text: spanish, options: GecOptions{Language: "en"}, expDetected: "es"},
+		{name: "Detection off", text: spanish, options: GecOptions{DetectLanguage: new(bool)}},
+		{name: "Supported language", text: "we shood buy an car.", expDetected: "en"},
+		{name: "Too short", text: "hi there"},
+	}
+
+	for _, tt := range tests {
+		opts, err := tt.options.Resolve()
+		if err != nil {
+			t.Fatalf("%s: Resolve() returned an error: %v", tt.name, err)
+		}
+		result, err := MarkupGrammar(tt.text, opts)
+		if err != nil {
+			t.Fatalf("%s: MarkupGrammar() returned an error: %v", tt.name, err)
+		}
+		if result.DetectedLanguage != tt.expDetected {
+			t.Errorf("%s:\nResult: %q\nExpected: %q", tt.name, result.DetectedLanguage, tt.expDetected)
+		}
+		if !reflect.DeepEqual(result.SkippedChecks, tt.expSkipped) {
+			t.Errorf("%s: SkippedChecks\nResult: %q\nExpected: %q", tt.name, result.SkippedChecks, tt.expSkipped)
+		}
+		if tt.expSkipped != nil && len(result.TextMarkups) != 0 {
+			t.Errorf("%s: expected no markups when checks are skipped, got %+v", tt.name, result.TextMarkups)
+		}
+	}
+}
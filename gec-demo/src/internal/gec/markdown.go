@@ -0,0 +1,336 @@
+// src/internal/gec/markdown.go
+package gec
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Markdown syntax at the start of a line. Only the prose after these is checked
+var (
+	reMdFence      = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	reMdHeading    = regexp.MustCompile(`^ {0,3}#{1,6}(?:[ \t]+|$)`)
+	reMdHeadingEnd = regexp.MustCompile(`[ \t]+#+[ \t]*$`)
+	reMdQuote      = regexp.MustCompile(`^ {0,3}> ?`)
+	reMdListItem   = regexp.MustCompile(`^[ \t]*(?:[-*+]|\d{1,9}[.)])(?:[ \t]+(?:\[[ xX]\][ \t]+)?|$)`)
+	reMdBreak      = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,}|=+[ \t]*)$`)
+	reMdRefDef     = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:[ \t]*\S`)
+	reMdTableDelim = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	reMdHtmlBlock  = regexp.MustCompile(`(?i)^ {0,3}<(?:!--|/?(?:address|article|aside|blockquote|center|details|dialog|div|dl|dt|dd|fieldset|figcaption|figure|footer|form|h[1-6]|header|hr|iframe|li|main|nav|ol|p|picture|pre|script|section|style|summary|table|tbody|td|tfoot|th|thead|tr|ul|video)(?:[\s/>]|$))`)
+	reMdHtmlTag    = regexp.MustCompile(`^<(?:/?[A-Za-z][A-Za-z0-9-]*(?:\s[^<>]*)?/?|!--[\s\S]*?--)>`)
+	reMdAutolink   = regexp.MustCompile(`^<((?:https?|ftp)://[^\s<>]+|[\w.+-]+@[\w-]+(?:\.[\w-]+)+)>`)
+	reEntity       = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+)
+
+// Backslash escapes for characters that would read as Markdown syntax in text inserted by a correction
+var mdTextEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "[", `\[`, "#", `\#`, "`", "\\`")
+
+// Extract the prose of a Markdown document: headings, paragraphs, list items, quotes & table cells.
+// Code blocks, HTML, link URLs and the markers around the prose are left out
+func parseMarkdown(text string) *proseDocument {
+	d := &proseDocument{raw: text, escape: mdTextEscaper.Replace}
+
+	// Line start & end byte offsets, without the newline
+	var lines [][2]int
+	start := 0
+	for i := 0; i <= len(text); i++ {
+		if i == len(text) || text[i] == '\n' {
+			end := i
+			if end > start && text[end-1] == '\r' {
+				end--
+			}
+			lines = append(lines, [2]int{start, end})
+			start = i + 1
+		}
+	}
+	line := func(i int) string { return text[lines[i][0]:lines[i][1]] }
+
+	fence := ""        // Marker of the open code fence
+	skipBlock := false // Inside an HTML block, skipped until the next blank line
+	inTable := false
+	lastBlank := true
+	inList := false
+	for i := range lines {
+		lineStart, lineEnd := lines[i][0], lines[i][1]
+		content := line(i)
+
+		// Code fences close on a fence of the same character at least as long
+		if fence != "" {
+			if m := reMdFence.FindStringSubmatch(content); m != nil && m[1][0] == fence[0] && len(m[1]) >= len(fence) && strings.TrimSpace(content[len(m[0]):]) == "" {
+				fence = ""
+			}
+			continue
+		}
+
+		// Strip the block quote markers, the rest of the line is parsed as usual
+		for {
+			m := reMdQuote.FindStringIndex(content)
+			if m == nil {
+				break
+			}
+			lineStart += m[1]
+			content = content[m[1]:]
+		}
+
+		blank := strings.TrimSpace(content) == ""
+		switch {
+		case blank:
+			d.endBlock()
+			skipBlock = false
+			inTable = false
+		case skipBlock:
+		case reMdFence.MatchString(content):
+			d.endBlock()
+			fence = reMdFence.FindStringSubmatch(content)[1]
+		case lastBlank && !inList && isIndentedCode(content):
+			d.endBlock()
+		case reMdHtmlBlock.MatchString(content):
+			d.endBlock()
+			skipBlock = true
+		case reMdBreak.MatchString(content), reMdRefDef.MatchString(content), reMdTableDelim.MatchString(content) && strings.Contains(content, "|"):
+			d.endBlock()
+		case strings.Contains(content, "|") && (inTable || i+1 < len(lines) && reMdTableDelim.MatchString(line(i+1))):
+			// Each table cell is a block of its own
+			inTable = true
+			d.endBlock()
+			cellStart := lineStart
+			for k := lineStart; k <= lineEnd; k++ {
+				if k == lineEnd || (text[k] == '|' && (k == lineStart || text[k-1] != '\\')) {
+					d.addInline(cellStart, k)
+					d.endBlock()
+					cellStart = k + 1
+				}
+			}
+		case reMdHeading.MatchString(content):
+			d.endBlock()
+			contentStart := lineStart + len(reMdHeading.FindString(content))
+			contentEnd := lineEnd
+			if m := reMdHeadingEnd.FindStringIndex(text[contentStart:lineEnd]); m != nil {
+				contentEnd = contentStart + m[0]
+			}
+			d.addInline(contentStart, contentEnd)
+			d.endBlock()
+		case reMdListItem.MatchString(content):
+			d.endBlock()
+			inList = true
+			d.addLine(lineStart+len(reMdListItem.FindString(content)), lineEnd)
+		default:
+			// Paragraph text, or the continuation of a list item
+			if !lastBlank {
+				d.addSpace()
+			} else if !isIndented(content) {
+				inList = false
+			}
+			d.addLine(lineStart, lineEnd)
+		}
+		lastBlank = blank
+	}
+	return d.finish()
+}
+
+// Check if a line is indented far enough to be a code block
+func isIndentedCode(line string) bool {
+	return strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")
+}
+
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
+
+// Add a line of a paragraph, without its indent or the spaces or backslash marking a hard line break
+func (d *proseDocument) addLine(start, end int) {
+	for start < end && (d.raw[start] == ' ' || d.raw[start] == '\t') {
+		start++
+	}
+	for end > start && (d.raw[end-1] == ' ' || d.raw[end-1] == '\t') {
+		end--
+	}
+	if end > start && d.raw[end-1] == '\\' && (end-1 == start || d.raw[end-2] != '\\') {
+		end--
+	}
+	d.addInline(start, end)
+}
+
+// Add the prose in raw bytes [start, end) of a Markdown line, leaving out inline syntax
+func (d *proseDocument) addInline(start, end int) {
+	text := d.raw
+	for start < end && (text[start] == ' ' || text[start] == '\t') {
+		start++
+	}
+
+	i := start
+	for i < end {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < end && isAsciiPunct(text[i+1]):
+			// Escaped punctuation is prose
+			d.addRune(rune(text[i+1]), i, i+2)
+			i += 2
+			continue
+
+		case c == '`':
+			// Code spans are kept with their backticks so they are protected from the checks
+			n := runLength(text, i, end, '`')
+			if close := strings.Index(text[i+n:end], strings.Repeat("`", n)); close != -1 {
+				d.addRaw(i, i+n+close+n)
+				i += n + close + n
+				continue
+			}
+			d.addRaw(i, i+n)
+			i += n
+			continue
+
+		case c == '<':
+			if m := reMdAutolink.FindStringSubmatchIndex(text[i:end]); m != nil {
+				d.addRaw(i+m[2], i+m[3])
+				i += m[1]
+				continue
+			}
+			if m := reMdHtmlTag.FindStringIndex(text[i:end]); m != nil {
+				i += m[1]
+				continue
+			}
+
+		case c == '&':
+			if m := reEntity.FindString(text[i:end]); m != "" {
+				if r := []rune(html.UnescapeString(m)); len(r) == 1 {
+					d.addRune(r[0], i, i+len(m))
+					i += len(m)
+					continue
+				}
+			}
+
+		case c == '!' && i+1 < end && text[i+1] == '[':
+			// Images keep their alt text as prose, like links
+			if next, ok := d.addLink(i+1, end); ok {
+				i = next
+				continue
+			}
+
+		case c == '[':
+			if i+1 < end && text[i+1] == '^' {
+				// Footnote references
+				if close := strings.IndexByte(text[i:end], ']'); close != -1 {
+					i += close + 1
+					continue
+				}
+			}
+			if next, ok := d.addLink(i, end); ok {
+				i = next
+				continue
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			n := runLength(text, i, end, c)
+			if isEmphasis(text, start, end, i, n) {
+				i += n
+				continue
+			}
+			d.addRaw(i, i+n)
+			i += n
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(text[i:end])
+		d.addRaw(i, i+size)
+		i += size
+	}
+}
+
+// Add the text of a link starting at the `[` at `i`, leaving out its destination.
+// Returns the offset after the link, or false if there is no link there
+func (d *proseDocument) addLink(i, end int) (int, bool) {
+	text := d.raw
+
+	// Find the closing bracket, allowing nested brackets
+	depth := 0
+	close := -1
+	for k := i; k < end && close == -1; k++ {
+		switch text[k] {
+		case '\\':
+			k++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				close = k
+			}
+		}
+	}
+	if close == -1 || close+1 >= end {
+		return 0, false
+	}
+
+	next := -1
+	switch text[close+1] {
+	case '(':
+		// Inline destination, which may hold balanced parentheses
+		depth = 0
+		for k := close + 1; k < end; k++ {
+			if text[k] == '(' {
+				depth++
+			} else if text[k] == ')' {
+				depth--
+				if depth == 0 {
+					next = k + 1
+					break
+				}
+			}
+		}
+	case '[':
+		// Reference link
+		if ref := strings.IndexByte(text[close+1:end], ']'); ref != -1 {
+			next = close + 1 + ref + 1
+		}
+	}
+	if next == -1 {
+		return 0, false
+	}
+
+	d.addInline(i+1, close)
+	return next, true
+}
+
+// Check if a run of `*`, `_` or `~` at `i` is emphasis or strikethrough rather than a literal character
+func isEmphasis(text string, start, end, i, n int) bool {
+	c := text[i]
+	if c == '~' && n < 2 {
+		return false
+	}
+	before, after := ' ', ' '
+	if i > start {
+		before, _ = utf8.DecodeLastRuneInString(text[start:i])
+	}
+	if i+n < end {
+		after, _ = utf8.DecodeRuneInString(text[i+n : end])
+	}
+
+	// A run opens emphasis before text or closes it after text. Surrounded by spaces it's a literal, like `2 * 3`
+	leftFlanking := !unicode.IsSpace(after)
+	rightFlanking := !unicode.IsSpace(before)
+	if c == '_' && isAlnum(before) && isAlnum(after) {
+		return false // Inside a word like snake_case
+	}
+	return leftFlanking || rightFlanking
+}
+
+func isAlnum(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Number of times `c` repeats from `i`
+func runLength(text string, i, end int, c byte) int {
+	n := 0
+	for i+n < end && text[i+n] == c {
+		n++
+	}
+	return n
+}
+
+func isAsciiPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) != -1
+}
@@ -0,0 +1,112 @@
+package gec
+
+import (
+	"testing"
+)
+
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{name: "Heading", text: "## A title ##", expected: "A title"},
+		{name: "Emphasis", text: "some **bold**, _italic_ and ~~gone~~ text", expected: "some bold, italic and gone text"},
+		{name: "Literal markers", text: "snake_case and 2 * 3", expected: "snake_case and 2 * 3"},
+		{name: "Link", text: "see [the docs](http://example.com/a_(b)) or [this][ref]", expected: "see the docs or this"},
+		{name: "Image & footnote", text: "![a cat](cat.png) sat[^1]", expected: "a cat sat"},
+		{name: "Code span", text: "run `go test` now", expected: "run `go test` now"},
+		{name: "Autolink & HTML", text: "mail <bob@example.com> or <b>shout</b>", expected: "mail bob@example.com or shout"},
+		{name: "Escapes & entities", text: `\*not bold\* &amp; &copy;`, expected: "*not bold* & \u00a9"},
+		{name: "Soft & hard breaks", text: "one line\ntwo line  \nthree\\\nfour", expected: "one line two line three four"},
+		{name: "Lists", text: "- first\n- [x] second\n  more\n1. third", expected: "first\n\nsecond more\n\nthird"},
+		{name: "Quote", text: "> quoted\n> > nested", expected: "quoted nested"},
+		{name: "Code blocks", text: "before\n\n```go\nfunc main() {}\n```\n\n    indented code\n\nafter", expected: "before\n\nafter"},
+		{name: "Table", text: "| a | b |\n|---|:-:|\n| c | d \\| e |", expected: "a\n\nb\n\nc\n\nd | e"},
+		{name: "Definitions & breaks", text: "text\n\n[ref]: http://example.com\n\n---\n\n<div>\nhtml\n</div>", expected: "text"},
+		{name: "Windows newlines", text: "# Title\r\n\r\nsome text\r\nmore", expected: "Title\n\nsome text more"},
+	}
+
+	for _, tt := range tests {
+		doc := parseMarkdown(tt.text)
+		if string(doc.prose) != tt.expected {
+			t.Errorf("%s:\nResult: %q\nExpected: %q", tt.name, string(doc.prose), tt.expected)
+		}
+	}
+}
+
+func TestProseDocumentRestore(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		corrected string // Corrected prose
+		expected  string
+	}{
+		{name: "Inside prose", text: "i like **teh** cake", corrected: "I like the cake", expected: "I like **the** cake"},
+		{name: "Escapes", text: `a \*b\* c`, corrected: "a *b* c.", expected: `a \*b\* c.`},
+		{name: "Insert at a block end", text: "# title\n\ntext", corrected: "title.\n\ntext", expected: "# title.\n\ntext"},
+		{name: "Across emphasis", text: "a *b* c", corrected: "a c", expected: "a *b* c"},
+		{name: "Across a line break", text: "one\n> two", corrected: "one, two", expected: "one,\n> two"},
+		{name: "Join blocks", text: "- one\n- two", corrected: "one two", expected: "- one\n- two"},
+		{name: "Escape inserted syntax", text: "use a star or a hash", corrected: "use a * or a #_[`", expected: "use a \\* or a \\#\\_\\[\\`"},
+	}
+
+	for _, tt := range tests {
+		result := parseMarkdown(tt.text).restore(tt.corrected)
+		if result != tt.expected {
+			t.Errorf("%s:\nResult: %q\nExpected: %q", tt.name, result, tt.expected)
+		}
+	}
+}
+
+func TestMarkupGrammarMarkdown(t *testing.T) {
+	if defaultLanguage() == nil {
+		t.Skip("languages not loaded")
+	}
+
+	text := "# i shood know\n\nwe went *home* and i slept. See [teh docs](http://teh.example.com).\n\n```\ni teh\n```\n"
+	opts, err := GecOptions{Format: "Markdown", OffsetEncoding: OffsetUTF16}.Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() returned an error: %v", err)
+	}
+	result, err := MarkupGrammar(text, opts)
+	if err != nil {
+		t.Fatalf("MarkupGrammar() returned an error: %v", err)
+	}
+
+	expected := "# I shood know\n\nWe went *home* and I slept. See [teh docs](http://teh.example.com).\n\n```\ni teh\n```\n"
+	if result.CorrectedText != expected {
+		t.Errorf("\nResult: %q\nExpected: %q", result.CorrectedText, expected)
+	}
+
+	// Every markup points at prose in the raw document
+	expMarkups := []string{"i", "shood", "we", "i", "teh"}
+	if len(result.TextMarkups) != len(expMarkups) {
+		t.Fatalf("\nResult: %+v\nExpected markups on %q", result.TextMarkups, expMarkups)
+	}
+	for i, m := range result.TextMarkups {
+		if covered := runeSubstring(text, m.Index, m.Length); covered != expMarkups[i] {
+			t.Errorf("Markup %d covers %q, expected %q", i, covered, expMarkups[i])
+		}
+	}
+
+	// Batches map their documents the same way
+	batch := MarkupGrammarBatch([]GecBatchDocument{{ID: "a", Text: text}}, opts)
+	if batch.Results[0].Error != "" || batch.Results[0].CorrectedText != expected {
+		t.Errorf("Batch:\nResult: %+v\nExpected: %q", batch.Results[0], expected)
+	}
+
+	// Documents without prose come back unchanged
+	codeOnly := "```\ncode here\n```\n"
+	result, err = MarkupGrammar(codeOnly, opts)
+	if err != nil {
+		t.Fatalf("MarkupGrammar() returned an error for a document without prose: %v", err)
+	}
+	if result.CorrectedText != codeOnly || len(result.TextMarkups) != 0 {
+		t.Errorf("No prose:\nResult: %+v\nExpected: %q", result, codeOnly)
+	}
+	batch = MarkupGrammarBatch([]GecBatchDocument{{ID: "a", Text: codeOnly}}, opts)
+	if batch.Results[0].Error != "" || batch.Results[0].CorrectedText != codeOnly {
+		t.Errorf("Batch without prose:\nResult: %+v\nExpected: %q", batch.Results[0], codeOnly)
+	}
+}
@@ -0,0 +1,121 @@
+// src/internal/gec/normalize.go
+package gec
+
+import (
+	"unicode"
+
+	"gec-demo/src/internal/print"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Characters CleanText() swaps for plain ASCII before the text is checked
+var normalizedRunes = map[rune]rune{
+	'\u00a0': ' ',  // Non-breaking space
+	'\u201c': '"',  // Left double quote
+	'\u201d': '"',  // Right double quote
+	'\u2018': '\'', // Left single quote
+	'\u2019': '\'', // Right single quote
+}
+
+// Records every substitution CleanText() makes so results can be mapped back onto the caller's text
+type textNormalization struct {
+	orig    []rune
+	norm    []rune
+	toOrig  []int // Original rune index of each normalized rune, plus one entry for the end of the text
+	changed bool
+}
+
+// Normalize the text for the pipeline. Drops unprintable control characters and swaps
+// smart quotes & non-breaking spaces, keeping a map back to the original characters
+func normalizeText(text string) (string, *textNormalization) {
+	tn := &textNormalization{orig: []rune(text)}
+	tn.norm = make([]rune, 0, len(tn.orig))
+	tn.toOrig = make([]int, 0, len(tn.orig)+1)
+
+	for i, r := range tn.orig {
+		if unicode.IsControl(r) && !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			print.Debug("Control Character being dropped: %v", r)
+			tn.changed = true
+			continue
+		}
+		if repl, ok := normalizedRunes[r]; ok {
+			r = repl
+			tn.changed = true
+		}
+		tn.norm = append(tn.norm, r)
+		tn.toOrig = append(tn.toOrig, i)
+	}
+	tn.toOrig = append(tn.toOrig, len(tn.orig))
+	return string(tn.norm), tn
+}
+
+// Original text covered by normalized runes [start, end)
+func (tn *textNormalization) origSpan(start, end int) string {
+	origStart := tn.toOrig[start]
+	if start == 0 {
+		// Keep any control characters dropped from the start of the text
+		origStart = 0
+	}
+	return string(tn.orig[origStart:tn.toOrig[end]])
+}
+
+// Move a span from normalized rune offsets onto the original text
+func (tn *textNormalization) mapSpan(index, length int) (int, int) {
+	last := len(tn.norm)
+	start := min(max(index, 0), last)
+	end := min(index+length, last)
+	if end <= start {
+		// Markups at the end of the text keep their length
+		return tn.toOrig[start], length
+	}
+	// End after the last marked character so dropped characters that follow aren't included
+	return tn.toOrig[start], tn.toOrig[end-1] + 1 - tn.toOrig[start]
+}
+
+// Move markups from normalized rune offsets onto the original text
+func (tn *textNormalization) mapMarkups(markups []Markup) []Markup {
+	if !tn.changed {
+		return markups
+	}
+	for i := range markups {
+		markups[i].Index, markups[i].Length = tn.mapSpan(markups[i].Index, markups[i].Length)
+	}
+	return markups
+}
+
+// Rewrite the corrected version of normalized runes [start, end) with the caller's original characters
+// wherever the model left the text unchanged
+func (tn *textNormalization) restore(start, end int, corrected string) string {
+	if !tn.changed {
+		return corrected
+	}
+
+	dmp := diffmatchpatch.New()
+	diffs := dmp.DiffMainRunes(tn.norm[start:end], []rune(corrected), false)
+
+	var out []rune
+	pos := start
+	for _, d := range diffs {
+		n := len([]rune(d.Text))
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			out = append(out, []rune(tn.origSpan(pos, pos+n))...)
+			pos += n
+		case diffmatchpatch.DiffDelete:
+			pos += n
+		case diffmatchpatch.DiffInsert:
+			out = append(out, []rune(d.Text)...)
+		}
+	}
+	return string(out)
+}
+
+// Map a response built from the normalized text back onto the original text,
+// then give the markups their IDs and requested offset unit
+func (tn *textNormalization) finishResponse(text string, gec_result *GecResponse, opts CheckOptions) {
+	gec_result.CorrectedText = tn.restore(0, len(tn.norm), gec_result.CorrectedText)
+	gec_result.TextMarkups = assignMarkupIDs(text, tn.mapMarkups(gec_result.TextMarkups))
+	gec_result.TextMarkups = encodeMarkupOffsets(text, gec_result.TextMarkups, opts.OffsetEncoding)
+	gec_result.CharacterCount = len(text)
+}
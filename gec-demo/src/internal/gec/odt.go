@@ -0,0 +1,133 @@
+// src/internal/gec/odt.go
+package gec
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	odfTextNamespace   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	odfOfficeNamespace = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+)
+
+// A paragraph being parsed, and the run being read in it
+type odtFrame struct {
+	paragraph *officeParagraph
+	depth     int
+	run       *officeRun
+	space     bool // The text ends with collapsed white space
+}
+
+// Read the paragraphs & headings of an OpenDocument text's content.xml.
+// Text directly in the paragraph and the text of each span or link are runs of their own
+func parseOdt(raw []byte) ([]*officeParagraph, error) {
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+
+	var frames []*odtFrame // Open paragraphs. Notes hold paragraphs inside another
+	var paragraphs []*officeParagraph
+	depth := 0
+	skipDepth := 0 // Depth of the element being skipped, 0 if none
+	count := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+		}
+
+		var frame *odtFrame
+		if len(frames) > 0 {
+			frame = frames[len(frames)-1]
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if skipDepth != 0 {
+				continue
+			}
+			text := t.Name.Space == odfTextNamespace
+			switch {
+			case text && (t.Name.Local == "tracked-changes" || t.Name.Local == "note-citation"),
+				t.Name.Space == odfOfficeNamespace && t.Name.Local == "annotation":
+				// Deleted text, note numbers & comments aren't part of the paragraph
+				skipDepth = depth
+			case text && (t.Name.Local == "p" || t.Name.Local == "h"):
+				if frame != nil {
+					frame.run = nil
+				}
+				frames = append(frames, &odtFrame{paragraph: &officeParagraph{index: count}, depth: depth, space: true})
+				count++
+			case frame == nil:
+			case text && (t.Name.Local == "span" || t.Name.Local == "a"):
+				frame.run = nil
+			case text && t.Name.Local == "s":
+				n, _ := strconv.Atoi(attrValue(t, "c"))
+				frame.add(strings.Repeat(" ", max(n, 1)), true)
+			case text && t.Name.Local == "tab":
+				frame.add("\t", true)
+			case text && t.Name.Local == "line-break":
+				frame.add("\n", true)
+			}
+
+		case xml.EndElement:
+			switch {
+			case skipDepth != 0:
+				if depth == skipDepth {
+					skipDepth = 0
+				}
+			case frame != nil && depth == frame.depth:
+				paragraphs = append(paragraphs, frame.paragraph)
+				frames = frames[:len(frames)-1]
+			case frame != nil && t.Name.Space == odfTextNamespace && (t.Name.Local == "span" || t.Name.Local == "a"):
+				frame.run = nil
+			}
+			depth--
+
+		case xml.CharData:
+			if skipDepth == 0 && frame != nil {
+				frame.add(string(t), false)
+			}
+		}
+	}
+
+	// Notes finish before the paragraph holding them
+	sortParagraphs(paragraphs)
+	return paragraphs, nil
+}
+
+// Add text to the current run. White space in the XML collapses to a single space,
+// spaces, tabs & line breaks written as elements are `literal`
+func (f *odtFrame) add(s string, literal bool) {
+	if literal {
+		f.space = false
+	} else {
+		var b strings.Builder
+		for _, r := range s {
+			if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+				if !f.space {
+					b.WriteRune(' ')
+				}
+				f.space = true
+				continue
+			}
+			f.space = false
+			b.WriteRune(r)
+		}
+		s = b.String()
+	}
+	if s == "" {
+		return
+	}
+	if f.run == nil {
+		f.run = f.paragraph.newRun()
+	}
+	f.paragraph.addText(f.run, s)
+}
@@ -0,0 +1,309 @@
+// src/internal/gec/office.go
+package gec
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Office documents that can be uploaded
+const (
+	DocumentDocx = "docx"
+	DocumentOdt  = "odt"
+)
+
+var (
+	MaxDocumentSize int64 = 20 << 20 // Maximum size of an uploaded document, and of any file unzipped from it
+
+	ErrUnsupportedDocument = errors.New("unsupported document")
+	ErrInvalidDocument     = errors.New("invalid document")
+)
+
+// A paragraph of an office document. Its text is made of runs sharing the same formatting
+type officeParagraph struct {
+	index int // Position among all the paragraphs of the document
+	text  []rune
+	runs  []*officeRun
+}
+
+type officeRun struct {
+	start, end int // Rune offsets of the run's text in the paragraph
+
+	// Only set for .docx runs
+	rawStart, rawEnd int    // Byte offsets of the run element in document.xml
+	props            string // Raw run properties, copied onto the runs split from it
+	editable         bool   // Holds nothing but text, tabs & breaks, so it can be split into tracked changes
+}
+
+// Start a new run at the end of the paragraph's text
+func (p *officeParagraph) newRun() *officeRun {
+	run := &officeRun{start: len(p.text), end: len(p.text)}
+	p.runs = append(p.runs, run)
+	return run
+}
+
+// Add text to the end of a run. Runs are only ever added to while they are the last of their paragraph
+func (p *officeParagraph) addText(run *officeRun, text string) {
+	p.text = append(p.text, []rune(text)...)
+	run.end = len(p.text)
+}
+
+// Index of the run holding rune `pos` of the text. Offsets at the end of the text belong to the last run with text
+func (p *officeParagraph) runAt(pos int) int {
+	pos = min(pos, len(p.text)-1)
+	for i, run := range p.runs {
+		if run.start <= pos && pos < run.end {
+			return i
+		}
+	}
+	return -1
+}
+
+// Put paragraphs back in document order
+func sortParagraphs(paragraphs []*officeParagraph) {
+	sort.Slice(paragraphs, func(i, j int) bool {
+		return paragraphs[i].index < paragraphs[j].index
+	})
+}
+
+// The unzipped parts of an office document
+type officeFile struct {
+	format string
+	zip    *zip.Reader
+}
+
+// Open an uploaded .docx or .odt file
+func openOfficeFile(data []byte) (*officeFile, error) {
+	if int64(len(data)) > MaxDocumentSize {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrInvalidDocument, MaxDocumentSize)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedDocument, err)
+	}
+
+	f := &officeFile{zip: zr}
+	mimetype, _ := f.read("mimetype")
+	switch {
+	case f.has("word/document.xml"):
+		f.format = DocumentDocx
+	case f.has("content.xml") && strings.HasPrefix(string(mimetype), "application/vnd.oasis.opendocument.text"):
+		f.format = DocumentOdt
+	default:
+		return nil, fmt.Errorf("%w: only .docx and .odt files are supported", ErrUnsupportedDocument)
+	}
+	return f, nil
+}
+
+func (f *officeFile) has(name string) bool {
+	for _, zf := range f.zip.File {
+		if zf.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Read a file from the document's zip. Files over MaxDocumentSize are refused, so zip bombs are never unpacked
+func (f *officeFile) read(name string) ([]byte, error) {
+	for _, zf := range f.zip.File {
+		if zf.Name != name {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidDocument, name, err)
+		}
+		defer rc.Close()
+
+		data, err := io.ReadAll(io.LimitReader(rc, MaxDocumentSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidDocument, name, err)
+		}
+		if int64(len(data)) > MaxDocumentSize {
+			return nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrInvalidDocument, name, MaxDocumentSize)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%w: missing %s", ErrInvalidDocument, name)
+}
+
+// Write the document's zip again, with the files in `changed` replaced or added
+func (f *officeFile) rewrite(changed map[string][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	write := func(name string, data []byte) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	written := make(map[string]bool)
+	for _, zf := range f.zip.File {
+		var err error
+		if data, ok := changed[zf.Name]; ok {
+			err = write(zf.Name, data)
+		} else {
+			err = zw.Copy(zf)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error writing %s: %w", zf.Name, err)
+		}
+		written[zf.Name] = true
+	}
+
+	// New files go at the end, in a stable order
+	var added []string
+	for name := range changed {
+		if !written[name] {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		if err := write(name, changed[name]); err != nil {
+			return nil, fmt.Errorf("error writing %s: %w", name, err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Extract the paragraphs of the document, with their runs
+func (f *officeFile) paragraphs() ([]*officeParagraph, error) {
+	switch f.format {
+	case DocumentDocx:
+		doc, err := f.docx()
+		if err != nil {
+			return nil, err
+		}
+		return doc.paragraphs, nil
+	case DocumentOdt:
+		raw, err := f.read("content.xml")
+		if err != nil {
+			return nil, err
+		}
+		return parseOdt(raw)
+	}
+	return nil, ErrUnsupportedDocument
+}
+
+// A paragraph and the result of checking it, with markup offsets in runes
+type officeCheck struct {
+	paragraph *officeParagraph
+	result    *GecBatchResult
+}
+
+// Check the paragraphs with text through the batch pipeline, one document per paragraph
+func checkParagraphs(paragraphs []*officeParagraph, opts CheckOptions) ([]officeCheck, float64) {
+	var checks []officeCheck
+	var docs []GecBatchDocument
+	for _, p := range paragraphs {
+		if strings.TrimSpace(string(p.text)) == "" {
+			continue
+		}
+		checks = append(checks, officeCheck{paragraph: p})
+		docs = append(docs, GecBatchDocument{ID: strconv.Itoa(p.index), Text: string(p.text)})
+	}
+
+	// Run offsets are in runes, so markups are too. They are converted to the requested unit in the report
+	opts.OffsetEncoding = OffsetRunes
+	batch := MarkupGrammarBatch(docs, opts)
+	for i := range checks {
+		checks[i].result = &batch.Results[i]
+	}
+	return checks, batch.ServiceTime
+}
+
+// Check every paragraph of a .docx or .odt file, and report the markups by paragraph & run
+func CheckOfficeDocument(data []byte, opts CheckOptions) (*OfficeDocumentResponse, error) {
+	startTime := time.Now()
+	f, err := openOfficeFile(data)
+	if err != nil {
+		return nil, err
+	}
+	paragraphs, err := f.paragraphs()
+	if err != nil {
+		return nil, err
+	}
+
+	checks, _ := checkParagraphs(paragraphs, opts)
+	response := &OfficeDocumentResponse{Format: f.format, Paragraphs: []OfficeParagraph{}}
+	for _, check := range checks {
+		report := check.report(opts.OffsetEncoding)
+		response.Paragraphs = append(response.Paragraphs, report)
+		if check.result.GecResponse != nil {
+			response.CharacterCount += check.result.CharacterCount
+			response.ErrorCharacterCount += check.result.ErrorCharacterCount
+			response.ContainsProfanity = response.ContainsProfanity || check.result.ContainsProfanity
+		}
+	}
+	response.ServiceTime = time.Since(startTime).Seconds()
+	return response, nil
+}
+
+// Report a paragraph's markups with the run each one starts in, with offsets in the requested unit
+func (c officeCheck) report(encoding string) OfficeParagraph {
+	p := c.paragraph
+	text := string(p.text)
+	table := offsetTable(text, encoding)
+	report := OfficeParagraph{Index: p.index, Text: text, Runs: []OfficeRun{}, TextMarkups: []OfficeMarkup{}, Error: c.result.Error}
+	for _, run := range p.runs {
+		index, length := convertOffset(table, run.start, run.end-run.start)
+		report.Runs = append(report.Runs, OfficeRun{Index: index, Length: length})
+	}
+	if c.result.GecResponse == nil {
+		return report
+	}
+
+	report.CorrectedText = c.result.CorrectedText
+	report.Language = c.result.Language
+	for _, m := range c.result.TextMarkups {
+		run := p.runAt(m.Index)
+		m.Index, m.Length = convertOffset(table, m.Index, m.Length)
+		report.TextMarkups = append(report.TextMarkups, OfficeMarkup{Markup: m, Run: run, RunOffset: m.Index - report.Runs[run].Index})
+	}
+	return report
+}
+
+// Check every paragraph of a .docx file and return the file with the corrections made as tracked changes.
+// Each markup gets a comment holding its message
+func CorrectDocx(data []byte, opts CheckOptions) ([]byte, error) {
+	f, err := openOfficeFile(data)
+	if err != nil {
+		return nil, err
+	}
+	if f.format != DocumentDocx {
+		return nil, fmt.Errorf("%w: corrected documents can only be written for .docx files", ErrUnsupportedDocument)
+	}
+	doc, err := f.docx()
+	if err != nil {
+		return nil, err
+	}
+
+	checks, _ := checkParagraphs(doc.paragraphs, opts)
+	for _, check := range checks {
+		if check.result.Error != "" {
+			return nil, fmt.Errorf("error checking paragraph %d: %s", check.paragraph.index, check.result.Error)
+		}
+	}
+	changed, err := doc.annotate(f, checks, DocxAuthor, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	return f.rewrite(changed)
+}
@@ -0,0 +1,263 @@
+package gec
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testDocxTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="xml" ContentType="application/xml"/><Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/></Types>`
+
+const testDocxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`
+
+// Zip files into an office document, in order
+func zipFiles(t *testing.T, files ...string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i < len(files); i += 2 {
+		w, err := zw.Create(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(files[i+1]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testDocx(t *testing.T, body string) []byte {
+	document := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006"><w:body>` +
+		body + `<w:sectPr/></w:body></w:document>`
+	return zipFiles(t, "[Content_Types].xml", testDocxTypes, "word/_rels/document.xml.rels", testDocxRels, "word/document.xml", document)
+}
+
+// Text of each run, with the runs that can't be edited in brackets
+func describeRuns(p *officeParagraph) string {
+	var runs []string
+	for _, run := range p.runs {
+		text := string(p.text[run.start:run.end])
+		if !run.editable {
+			text = "[" + text + "]"
+		}
+		runs = append(runs, text)
+	}
+	return strings.Join(runs, "|")
+}
+
+func TestParseDocx(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected []string // Runs of each paragraph
+	}{
+		{
+			name:     "Runs",
+			body:     `<w:p><w:pPr><w:jc w:val="center"/></w:pPr><w:r><w:t xml:space="preserve">plain </w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>bold</w:t><w:tab/><w:t>&amp; tab</w:t></w:r></w:p>`,
+			expected: []string{"plain |bold\t& tab"},
+		},
+		{
+			name:     "Hyperlinks & empty paragraphs",
+			body:     `<w:p/><w:p><w:hyperlink r:id="rId2" xmlns:r="r"><w:r><w:t>a link</w:t></w:r></w:hyperlink><w:r><w:br/><w:t>next</w:t></w:r></w:p>`,
+			expected: []string{"", "a link|\nnext"},
+		},
+		{
+			name:     "Tracked changes",
+			body:     `<w:p><w:r><w:t>kept </w:t></w:r><w:del w:id="7"><w:r><w:delText>gone</w:delText></w:r></w:del><w:ins w:id="8"><w:r><w:t>added</w:t></w:r></w:ins></w:p>`,
+			expected: []string{"kept |[]|[added]"},
+		},
+		{
+			name: "Text boxes",
+			body: `<w:p><w:r><w:t>outer</w:t></w:r><w:r><mc:AlternateContent><mc:Choice><w:drawing><w:txbxContent><w:p><w:r><w:t>inner</w:t></w:r></w:p></w:txbxContent></w:drawing></mc:Choice>` +
+				`<mc:Fallback><w:pict><w:txbxContent><w:p><w:r><w:t>inner</w:t></w:r></w:p></w:txbxContent></w:pict></mc:Fallback></mc:AlternateContent></w:r></w:p>`,
+			expected: []string{"outer|[]", "inner"},
+		},
+		{
+			name:     "Page breaks & fields",
+			body:     `<w:p><w:r><w:t>one</w:t><w:br w:type="page"/></w:r><w:r><w:fldChar w:fldCharType="begin"/></w:r><w:r><w:instrText>PAGE</w:instrText></w:r></w:p>`,
+			expected: []string{"[one]|[]|[]"},
+		},
+	}
+
+	for _, tt := range tests {
+		f, err := openOfficeFile(testDocx(t, tt.body))
+		if err != nil {
+			t.Fatalf("%s: openOfficeFile() returned an error: %v", tt.name, err)
+		}
+		paragraphs, err := f.paragraphs()
+		if err != nil {
+			t.Fatalf("%s: paragraphs() returned an error: %v", tt.name, err)
+		}
+		var result []string
+		for _, p := range paragraphs {
+			result = append(result, describeRuns(p))
+		}
+		if strings.Join(result, "\n\n") != strings.Join(tt.expected, "\n\n") {
+			t.Errorf("%s:\nResult: %q\nExpected: %q", tt.name, result, tt.expected)
+		}
+	}
+}
+
+func TestParseOdt(t *testing.T) {
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"><office:body><office:text>
+<text:tracked-changes><text:changed-region><text:deletion><text:p>deleted</text:p></text:deletion></text:changed-region></text:tracked-changes>
+<text:h text:outline-level="1">A   title</text:h>
+<text:p>
+  some <text:span text:style-name="T1">styled</text:span> text<text:s text:c="2"/>with<text:tab/>a<text:line-break/>note<text:note><text:note-citation>1</text:note-citation><text:note-body><text:p>in the note</text:p></text:note-body></text:note>.
+</text:p>
+<text:p><office:annotation><text:p>a comment</text:p></office:annotation>done</text:p>
+</office:text></office:body></office:document-content>`
+	data := zipFiles(t, "mimetype", "application/vnd.oasis.opendocument.text", "content.xml", content)
+
+	f, err := openOfficeFile(data)
+	if err != nil {
+		t.Fatalf("openOfficeFile() returned an error: %v", err)
+	}
+	if f.format != DocumentOdt {
+		t.Errorf("\nResult: %q\nExpected: %q", f.format, DocumentOdt)
+	}
+	paragraphs, err := f.paragraphs()
+	if err != nil {
+		t.Fatalf("paragraphs() returned an error: %v", err)
+	}
+
+	expected := []string{"A title", "some |styled| text  with\ta\nnote|. ", "in the note", "done"}
+	var result []string
+	for _, p := range paragraphs {
+		result = append(result, strings.NewReplacer("[", "", "]", "").Replace(describeRuns(p)))
+	}
+	if strings.Join(result, "\n\n") != strings.Join(expected, "\n\n") {
+		t.Errorf("\nResult: %q\nExpected: %q", result, expected)
+	}
+}
+
+func TestOpenOfficeFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "Not a zip", data: []byte("plain text"), err: ErrUnsupportedDocument},
+		{name: "Other zip", data: zipFiles(t, "a.txt", "a"), err: ErrUnsupportedDocument},
+		{name: "Spreadsheet", data: zipFiles(t, "mimetype", "application/vnd.oasis.opendocument.spreadsheet", "content.xml", "<x/>"), err: ErrUnsupportedDocument},
+		{name: "Broken XML", data: testDocx(t, "<w:p><w:r>"), err: ErrInvalidDocument},
+	}
+
+	for _, tt := range tests {
+		_, err := CheckOfficeDocument(tt.data, DefaultOptions())
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s:\nResult: %v\nExpected: %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestCorrectDocx(t *testing.T) {
+	if defaultLanguage() == nil {
+		t.Skip("languages not loaded")
+	}
+
+	body := `<w:p><w:r><w:rPr><w:i/></w:rPr><w:t xml:space="preserve">i like </w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>teh</w:t></w:r><w:r><w:t xml:space="preserve"> cake</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>Nothing to fix here.</w:t></w:r></w:p>`
+	data := testDocx(t, body)
+
+	// The report keys each markup by the run it starts in
+	report, err := CheckOfficeDocument(data, DefaultOptions())
+	if err != nil {
+		t.Fatalf("CheckOfficeDocument() returned an error: %v", err)
+	}
+	if len(report.Paragraphs) != 2 || len(report.Paragraphs[0].TextMarkups) == 0 {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+	p := report.Paragraphs[0]
+	expRuns := []OfficeRun{{Index: 0, Length: 7}, {Index: 7, Length: 3}, {Index: 10, Length: 5}}
+	for i, run := range expRuns {
+		if p.Runs[i] != run {
+			t.Errorf("Run %d:\nResult: %+v\nExpected: %+v", i, p.Runs[i], run)
+		}
+	}
+	for _, m := range p.TextMarkups {
+		if run := p.Runs[m.Run]; m.Index != run.Index+m.RunOffset || m.Index >= run.Index+run.Length {
+			t.Errorf("Markup %+v doesn't start in run %+v", m, run)
+		}
+	}
+
+	corrected, err := CorrectDocx(data, DefaultOptions())
+	if err != nil {
+		t.Fatalf("CorrectDocx() returned an error: %v", err)
+	}
+	f, err := openOfficeFile(corrected)
+	if err != nil {
+		t.Fatalf("openOfficeFile() returned an error: %v", err)
+	}
+	doc, err := f.docx()
+	if err != nil {
+		t.Fatalf("docx() returned an error: %v", err)
+	}
+
+	// Accepting every change applies every markup, and the formatting of each run is kept
+	var markups []Markup
+	var ids []string
+	for _, m := range p.TextMarkups {
+		markups = append(markups, m.Markup)
+		ids = append(ids, m.ID)
+	}
+	expected, _, err := ApplyMarkups(p.Text, markups, ids)
+	if err != nil {
+		t.Fatalf("ApplyMarkups() returned an error: %v", err)
+	}
+	if text := string(doc.paragraphs[0].text); text != expected {
+		t.Errorf("\nResult: %q\nExpected: %q", text, expected)
+	}
+	raw := string(doc.raw)
+	for _, expected := range []string{
+		`<w:del w:id="`, `<w:r><w:rPr><w:i/></w:rPr><w:delText xml:space="preserve">i</w:delText></w:r></w:del>`,
+		`<w:r><w:rPr><w:i/></w:rPr><w:t xml:space="preserve">I</w:t></w:r></w:ins>`,
+		`<w:commentRangeStart w:id="`, `<w:commentReference w:id="`,
+		`<w:p><w:r><w:t>Nothing to fix here.</w:t></w:r></w:p><w:sectPr/>`,
+	} {
+		if !strings.Contains(raw, expected) {
+			t.Errorf("Corrected document.xml is missing %q:\n%s", expected, raw)
+		}
+	}
+
+	// Every markup has a comment with its message, registered with the package
+	comments, err := f.read("word/comments.xml")
+	if err != nil {
+		t.Fatalf("read() returned an error: %v", err)
+	}
+	for _, m := range p.TextMarkups {
+		if !strings.Contains(string(comments), ">"+xmlEscape(m.Message)+"<") {
+			t.Errorf("comments.xml is missing %q:\n%s", m.Message, comments)
+		}
+	}
+	types, _ := f.read("[Content_Types].xml")
+	rels, _ := f.read("word/_rels/document.xml.rels")
+	if !strings.Contains(string(types), `PartName="/word/comments.xml"`) || !strings.Contains(string(rels), `Target="comments.xml"`) {
+		t.Errorf("comments.xml isn't registered:\n%s\n%s", types, rels)
+	}
+
+	// Checking the corrected document again adds to its comments
+	twice, err := CorrectDocx(corrected, DefaultOptions())
+	if err != nil {
+		t.Fatalf("CorrectDocx() returned an error: %v", err)
+	}
+	f, _ = openOfficeFile(twice)
+	comments2, _ := f.read("word/comments.xml")
+	if !strings.HasPrefix(string(comments2), strings.TrimSuffix(string(comments), "</w:comments>")) {
+		t.Errorf("Existing comments weren't kept:\n%s", comments2)
+	}
+}
+
+func TestCorrectDocxOdt(t *testing.T) {
+	data := zipFiles(t, "mimetype", "application/vnd.oasis.opendocument.text", "content.xml", "<x/>")
+	if _, err := CorrectDocx(data, DefaultOptions()); !errors.Is(err, ErrUnsupportedDocument) {
+		t.Errorf("\nResult: %v\nExpected: %v", err, ErrUnsupportedDocument)
+	}
+}
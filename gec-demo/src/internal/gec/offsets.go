@@ -0,0 +1,95 @@
+// src/internal/gec/offsets.go
+package gec
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Units markup offsets can be reported in. The pipeline works in runes internally
+const (
+	OffsetRunes = "runes" // Unicode code points (default)
+	OffsetBytes = "bytes" // UTF-8 bytes
+	OffsetUTF16 = "utf16" // UTF-16 code units, as used by JavaScript & Java strings
+)
+
+var offsetEncodings = []string{OffsetRunes, OffsetBytes, OffsetUTF16}
+
+// Offset of every rune boundary in the requested unit. Has one more entry than the text has runes
+func offsetTable(text, encoding string) []int {
+	table := make([]int, 0, utf8.RuneCountInString(text)+1)
+	offset := 0
+	for _, r := range text {
+		table = append(table, offset)
+		switch encoding {
+		case OffsetBytes:
+			offset += utf8.RuneLen(r)
+		case OffsetUTF16:
+			offset++
+			if r >= 0x10000 {
+				offset++ // Surrogate pair
+			}
+		default:
+			offset++
+		}
+	}
+	return append(table, offset)
+}
+
+// Convert rune offsets into the requested unit
+func convertOffset(table []int, index, length int) (int, int) {
+	last := len(table) - 1
+	start := min(max(index, 0), last)
+	end := min(max(index+length, start), last)
+	unitLen := table[end] - table[start]
+	if unitLen == 0 && length > 0 {
+		// Markups at the end of the text keep their length
+		unitLen = length
+	}
+	return table[start], unitLen
+}
+
+// Report markups in the requested offset unit. Markups come from the pipeline in runes
+func encodeMarkupOffsets(text string, markups []Markup, encoding string) []Markup {
+	if encoding == "" || encoding == OffsetRunes {
+		return markups
+	}
+	table := offsetTable(text, encoding)
+	for i := range markups {
+		markups[i].Index, markups[i].Length = convertOffset(table, markups[i].Index, markups[i].Length)
+	}
+	return markups
+}
+
+// Convert markups from the requested offset unit back into runes.
+// Fails when an offset splits a character
+func decodeMarkupOffsets(text string, markups []Markup, encoding string) ([]Markup, error) {
+	if encoding == "" || encoding == OffsetRunes {
+		return markups, nil
+	}
+	table := offsetTable(text, encoding)
+	runeAt := make(map[int]int, len(table))
+	for i, offset := range table {
+		runeAt[offset] = i
+	}
+
+	decoded := make([]Markup, len(markups))
+	for i, m := range markups {
+		start, ok := runeAt[m.Index]
+		if !ok {
+			return nil, fmt.Errorf("markup %q index %d is not on a character boundary in %s", m.ID, m.Index, encoding)
+		}
+		end, ok := runeAt[m.Index+m.Length]
+		switch {
+		case ok:
+		case start == len(table)-1:
+			// Markup at the end of the text
+			end = start + m.Length
+		default:
+			return nil, fmt.Errorf("markup %q length %d is not on a character boundary in %s", m.ID, m.Length, encoding)
+		}
+		m.Index, m.Length = start, end-start
+		decoded[i] = m
+	}
+	return decoded, nil
+}
@@ -0,0 +1,254 @@
+// src/internal/gec/options.go
+package gec
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Markup categories
+const (
+	CategoryGrammar   = "GRAMMAR_SUGGESTION"
+	CategorySpelling  = "SPELLING_MISTAKE"
+	CategoryProfanity = "PROFANITY"
+)
+
+// Markup rule codes
+const (
+	RuleSpacing            = "SPACING"
+	RuleReplacePunctuation = "REPLACE_PUNCTUATION"
+	RuleRemoveComma        = "REMOVE_COMMA"
+	RuleAddComma           = "ADD_COMMA"
+	RuleRemovePeriod       = "REMOVE_PERIOD"
+	RuleAddPeriod          = "ADD_PERIOD"
+	RuleRemoveQuestionMark = "REMOVE_QUESTION_MARK"
+	RuleAddQuestionMark    = "ADD_QUESTION_MARK"
+	RuleRemoveExclamation  = "REMOVE_EXCLAMATION_MARK"
+	RuleAddExclamation     = "ADD_EXCLAMATION_MARK"
+	RuleRemovePunctuation  = "REMOVE_PUNCTUATION"
+	RulePunctuation        = "PUNCTUATION"
+	RuleUnnecessaryText    = "UNNECESSARY_TEXT"
+	RuleCapitalization     = "CAPITALIZATION"
+	RuleWordReplacement    = "WORD_REPLACEMENT"
+	RuleSpelling           = "SPELLING"
+	RuleEmoji              = "EMOJI"
+	RuleProfanity          = "PROFANITY"
+)
+
+var markupCategories = []string{CategoryGrammar, CategorySpelling, CategoryProfanity}
+
+// Decoding limits
+const (
+	MaxBeamWidth     = 8 // Maximum beams per sequence (Matches MAX_BEAM_WIDTH in config.h)
+	MaxLengthPenalty = 2.0
+)
+
+// Options a request can set to change which checks run. Unset options use the server defaults
+type GecOptions struct {
+	Spelling         *bool    `json:"spelling,omitempty"`          // Hunspell spelling mistakes (SpellChecker)
+	Profanity        *bool    `json:"profanity,omitempty"`         // Offensive words (DirtySpellChecker)
+	Emojis           *bool    `json:"emojis,omitempty"`            // Mark emojis as mistakes (MarkEmojis)
+	Grammar          *bool    `json:"grammar,omitempty"`           // Model grammar suggestions
+	IgnoreCollisions *bool    `json:"ignore_collisions,omitempty"` // Keep markups that overlap each other
+	Categories       []string `json:"categories,omitempty"`        // Only return markups in these categories
+	OffsetEncoding   string   `json:"offset_encoding,omitempty"`   // Unit for markup offsets: runes, bytes or utf16
+	Dictionary       string   `json:"dictionary,omitempty"`        // Custom dictionary of extra words to accept
+	ProfanityLists   []string `json:"profanity_lists,omitempty"`   // Extra profanity lists to check
+	ProfanityAllow   []string `json:"profanity_allow,omitempty"`   // Words never marked as profanity
+	Language         string   `json:"language,omitempty"`          // Language code of the text, defaults to "en"
+	DetectLanguage   *bool    `json:"detect_language,omitempty"`   // Detect the language of the text
+	Format           string   `json:"format,omitempty"`            // Format of the text: text, markdown or html
+	MinConfidence    *float64 `json:"min_confidence,omitempty"`    // Grammar suggestions the model is less confident in are low confidence, 0 to 1
+	LowConfidence    string   `json:"low_confidence,omitempty"`    // What to do with low confidence suggestions: hide or hint
+	BeamWidth        *int     `json:"beam_width,omitempty"`        // Beams kept by the decoder, 1 decodes greedily
+	NumBest          *int     `json:"num_best,omitempty"`          // Corrections to decode per sentence, the others become extra replacements
+	LengthPenalty    *float64 `json:"length_penalty,omitempty"`    // Above 0 favors longer corrections in beam search, below 0 shorter ones
+	NoRepeatNgram    *int     `json:"no_repeat_ngram,omitempty"`   // Size of the n-grams the decoder can't repeat, 0 only stops ABABAB loops
+}
+
+// Resolved options for a single run of the pipeline. Never shared between requests
+type CheckOptions struct {
+	Spelling         bool
+	Profanity        bool
+	Emojis           bool
+	Grammar          bool
+	IgnoreCollisions bool
+	Categories       map[string]bool // nil keeps every category
+	OffsetEncoding   string
+	Dictionary       CustomWords // nil when no custom dictionary was selected
+	ProfanityLists   []*ProfanityList
+	ProfanityAllow   CustomWords
+	Language         *Language
+	DetectLanguage   bool
+	AutoLanguage     bool // Check the text in the detected language, as the request didn't choose one
+	Format           string
+	MinConfidence    float64 // 0 keeps every grammar suggestion
+	LowConfidence    string
+	Decoding         DecodeOptions
+}
+
+// Options used when a request doesn't set any
+func DefaultOptions() CheckOptions {
+	return CheckOptions{
+		Spelling:         DoMisspellings,
+		Profanity:        DoMisspellings,
+		Emojis:           DoMisspellings,
+		Grammar:          true,
+		IgnoreCollisions: IgnoreCollisions,
+		OffsetEncoding:   OffsetRunes,
+		ProfanityLists:   defaultProfanityLists(),
+		Language:         defaultLanguage(),
+		DetectLanguage:   DetectLanguage,
+		AutoLanguage:     DetectLanguage,
+		Format:           FormatText,
+		LowConfidence:    LowConfidenceHide,
+		Decoding:         DefaultDecodeOptions(),
+	}
+}
+
+// Report the beams a result was decoded with when they are narrower than requested.
+// Nil if the request was met or the backend doesn't report its decoding
+func narrowedDecoding(requested DecodeOptions, result *GrammarResult) *DecodingReport {
+	used := result.Decoding
+	if used.BeamWidth == 0 || (used.BeamWidth >= requested.BeamWidth && used.NumBest >= requested.NumBest) {
+		return nil
+	}
+	return &DecodingReport{BeamWidth: used.BeamWidth, NumBest: used.NumBest}
+}
+
+// Greedy decoding. Longer repeats are allowed, as real text repeats itself too, but the runtime still stops ABABAB loops
+func DefaultDecodeOptions() DecodeOptions {
+	return DecodeOptions{BeamWidth: 1, NumBest: 1, LengthPenalty: 1.0, NoRepeatNgram: 0}
+}
+
+// Apply the request's options over the defaults
+func (o GecOptions) Resolve() (CheckOptions, error) {
+	opts := DefaultOptions()
+
+	// Spell checks can only be turned on if the spell checker was initialized
+	if o.Spelling != nil {
+		opts.Spelling = *o.Spelling && DoMisspellings
+	}
+	if o.Profanity != nil {
+		opts.Profanity = *o.Profanity && DoMisspellings
+	}
+	if o.Emojis != nil {
+		opts.Emojis = *o.Emojis && DoMisspellings
+	}
+	if o.Grammar != nil {
+		opts.Grammar = *o.Grammar
+	}
+	if o.IgnoreCollisions != nil {
+		opts.IgnoreCollisions = *o.IgnoreCollisions
+	}
+
+	if o.OffsetEncoding != "" {
+		opts.OffsetEncoding = strings.ToLower(strings.TrimSpace(o.OffsetEncoding))
+		if !contains(offsetEncodings, opts.OffsetEncoding) {
+			return opts, fmt.Errorf("unknown offset_encoding %q. Valid encodings: %s", o.OffsetEncoding, strings.Join(offsetEncodings, ", "))
+		}
+	}
+
+	if o.Format != "" {
+		opts.Format = strings.ToLower(strings.TrimSpace(o.Format))
+		if !contains(documentFormats, opts.Format) {
+			return opts, fmt.Errorf("unknown format %q. Valid formats: %s", o.Format, strings.Join(documentFormats, ", "))
+		}
+	}
+
+	if o.MinConfidence != nil {
+		if *o.MinConfidence < 0 || *o.MinConfidence > 1 {
+			return opts, fmt.Errorf("min_confidence %v out of range. It must be between 0 and 1", *o.MinConfidence)
+		}
+		opts.MinConfidence = *o.MinConfidence
+	}
+	if o.LowConfidence != "" {
+		opts.LowConfidence = strings.ToLower(strings.TrimSpace(o.LowConfidence))
+		if !contains(lowConfidenceModes, opts.LowConfidence) {
+			return opts, fmt.Errorf("unknown low_confidence %q. Valid modes: %s", o.LowConfidence, strings.Join(lowConfidenceModes, ", "))
+		}
+	}
+
+	if o.BeamWidth != nil {
+		if *o.BeamWidth < 1 || *o.BeamWidth > MaxBeamWidth {
+			return opts, fmt.Errorf("beam_width %d out of range. It must be between 1 and %d", *o.BeamWidth, MaxBeamWidth)
+		}
+		opts.Decoding.BeamWidth = *o.BeamWidth
+	}
+	if o.NumBest != nil {
+		if *o.NumBest < 1 || *o.NumBest > opts.Decoding.BeamWidth {
+			return opts, fmt.Errorf("num_best %d out of range. It must be between 1 and the beam_width, %d", *o.NumBest, opts.Decoding.BeamWidth)
+		}
+		opts.Decoding.NumBest = *o.NumBest
+	}
+	if o.LengthPenalty != nil {
+		if math.IsNaN(*o.LengthPenalty) || math.Abs(*o.LengthPenalty) > MaxLengthPenalty {
+			return opts, fmt.Errorf("length_penalty %v out of range. It must be between %v and %v", *o.LengthPenalty, -MaxLengthPenalty, MaxLengthPenalty)
+		}
+		opts.Decoding.LengthPenalty = *o.LengthPenalty
+	}
+	if o.NoRepeatNgram != nil {
+		if *o.NoRepeatNgram < 0 {
+			return opts, fmt.Errorf("no_repeat_ngram %d out of range. It must be 0 or more", *o.NoRepeatNgram)
+		}
+		opts.Decoding.NoRepeatNgram = *o.NoRepeatNgram
+	}
+
+	if o.Language != "" {
+		lang, err := GetLanguage(strings.ToLower(strings.TrimSpace(o.Language)))
+		if err != nil {
+			return opts, err
+		}
+		opts.Language = lang
+		opts.AutoLanguage = false
+	}
+	if o.DetectLanguage != nil {
+		opts.DetectLanguage = *o.DetectLanguage
+		opts.AutoLanguage = opts.AutoLanguage && *o.DetectLanguage
+	}
+
+	if o.Dictionary != "" {
+		words, err := GetDictionary(o.Dictionary)
+		if err != nil {
+			return opts, err
+		}
+		opts.Dictionary = words
+	}
+
+	// Check the selected lists on top of the embedded ones. Copy so the defaults are never modified
+	if len(o.ProfanityLists) > 0 {
+		opts.ProfanityLists = append([]*ProfanityList(nil), opts.ProfanityLists...)
+	}
+	for _, name := range o.ProfanityLists {
+		list, err := GetProfanityList(name)
+		if err != nil {
+			return opts, err
+		}
+		opts.ProfanityLists = append(opts.ProfanityLists, list)
+	}
+	if len(o.ProfanityAllow) > 0 {
+		opts.ProfanityAllow = make(CustomWords, len(o.ProfanityAllow))
+		for _, word := range o.ProfanityAllow {
+			word = strings.TrimSpace(word)
+			opts.ProfanityAllow[strings.ToLower(word)] = word
+		}
+	}
+
+	if len(o.Categories) > 0 {
+		opts.Categories = make(map[string]bool)
+		for _, cat := range o.Categories {
+			cat = strings.ToUpper(strings.TrimSpace(cat))
+			if !contains(markupCategories, cat) {
+				return opts, fmt.Errorf("unknown category %q. Valid categories: %s", cat, strings.Join(markupCategories, ", "))
+			}
+			opts.Categories[cat] = true
+		}
+	}
+	return opts, nil
+}
+
+// Check if markups of a category should be returned
+func (opts CheckOptions) keepCategory(category string) bool {
+	return opts.Categories == nil || opts.Categories[category]
+}
@@ -0,0 +1,359 @@
+// src/internal/gec/profanity.go
+package gec
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Profanity severity tiers, from least to most offensive
+const (
+	SeverityMild   = "mild"
+	SeverityStrong = "strong"
+	SeveritySlur   = "slur"
+)
+
+var (
+	ErrProfanityListNotFound = errors.New("profanity list not found")
+
+	maxExpandedWords = 4096 // Regex entries matching more words than this are matched as regexps instead
+
+	severityRank = map[string]int{SeverityMild: 1, SeverityStrong: 2, SeveritySlur: 3}
+
+	profanityLists = &profanityStore{lists: map[string]*ProfanityList{}}
+)
+
+// A profanity word list, compiled once when it is loaded.
+// Each entry has a severity, and allowlisted words are never marked
+type ProfanityList struct {
+	words    *ahoCorasick       // Literal words, including every word a finite regex entry can match
+	pattern  *regexp.Regexp     // Remaining regex entries combined into one pattern, most severe first
+	patterns []profanityPattern // Each regex entry on its own, to look up the severity of a match
+	allow    CustomWords
+}
+
+type profanityPattern struct {
+	expr     string
+	re       *regexp.Regexp // Matches the whole word only
+	severity string
+}
+
+// Parse a profanity list. Lines hold a word, or a regex when `regex` is set, optionally followed
+// by a tab and a severity. Lines starting with `!` are allowlisted words and `#` starts a comment
+func parseProfanityList(r io.Reader, regex bool) (*ProfanityList, error) {
+	list := &ProfanityList{allow: CustomWords{}}
+	words := make(map[string]string)
+	var patterns []profanityPattern
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Ignore blank lines or those starting with `#`
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+		if word, ok := strings.CutPrefix(line, "!"); ok {
+			word = strings.TrimSpace(word)
+			list.allow[strings.ToLower(word)] = word
+			continue
+		}
+
+		word, severity, _ := strings.Cut(line, "\t")
+		word = strings.TrimSpace(word)
+		severity = strings.ToLower(strings.TrimSpace(severity))
+		if severity == "" {
+			severity = SeverityStrong
+		}
+		if _, ok := severityRank[severity]; !ok {
+			return nil, fmt.Errorf("unknown profanity severity %q for %q", severity, word)
+		}
+
+		expanded := []string{word}
+		if regex {
+			// Patterns like `dicks?` only match a few words, so they can go in the automaton too
+			var ok bool
+			expanded, ok = expandPattern(word, maxExpandedWords)
+			if !ok {
+				patterns = append(patterns, profanityPattern{expr: word, severity: severity})
+				continue
+			}
+		}
+
+		// Keep the most severe tier for words listed twice
+		for _, w := range expanded {
+			w = strings.ToLower(w)
+			if w != "" && severityRank[severity] > severityRank[words[w]] {
+				words[w] = severity
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed scanning profanity list: %w", err)
+	}
+
+	if len(words) > 0 {
+		list.words = newAhoCorasick(words)
+	}
+	if len(patterns) > 0 {
+		if err := list.compilePatterns(patterns); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// Combine the regex entries that could not be expanded into a single pattern.
+// Listing the most severe first lets them win ties
+func (list *ProfanityList) compilePatterns(patterns []profanityPattern) error {
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return severityRank[patterns[i].severity] > severityRank[patterns[j].severity]
+	})
+
+	exprs := make([]string, len(patterns))
+	for i := range patterns {
+		re, err := regexp.Compile(`(?i)^(?:` + patterns[i].expr + `)$`)
+		if err != nil {
+			return fmt.Errorf("failed compiling profanity pattern: %w", err)
+		}
+		patterns[i].re = re
+		exprs[i] = "(?:" + patterns[i].expr + ")"
+	}
+
+	var err error
+	list.pattern, err = regexp.Compile(`(?i)\b(?:` + strings.Join(exprs, "|") + `)\b`)
+	if err != nil {
+		return fmt.Errorf("failed compiling profanity pattern: %w", err)
+	}
+	list.patterns = patterns
+	return nil
+}
+
+// Find every match in the text as rune offsets
+func (list *ProfanityList) findAll(data string, runes []rune, found func(start, end int, severity string)) {
+	if list.words != nil {
+		list.words.findAll(runes, found)
+	}
+	if list.pattern == nil {
+		return
+	}
+
+	for _, match := range list.pattern.FindAllStringIndex(data, -1) {
+		// Only the matched word is checked against each entry, so this stays cheap
+		word := data[match[0]:match[1]]
+		severity := SeverityStrong
+		for _, p := range list.patterns {
+			if p.re.MatchString(word) {
+				severity = p.severity
+				break
+			}
+		}
+
+		// Convert the byte offsets to rune offsets
+		start := utf8.RuneCountInString(data[:match[0]])
+		found(start, start+utf8.RuneCountInString(word), severity)
+	}
+}
+
+// List every string a regex can match. Fails for patterns matching more than `limit` strings,
+// or using repetition, anchors or wide character classes
+func expandPattern(expr string, limit int) ([]string, bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, false
+	}
+	return expandRegexp(re.Simplify(), limit)
+}
+
+func expandRegexp(re *syntax.Regexp, limit int) ([]string, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		return []string{""}, true
+
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}, true
+
+	case syntax.OpCharClass:
+		var out []string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				if len(out) >= limit {
+					return nil, false
+				}
+				out = append(out, string(r))
+			}
+		}
+		return out, true
+
+	case syntax.OpCapture:
+		return expandRegexp(re.Sub[0], limit)
+
+	case syntax.OpQuest:
+		sub, ok := expandRegexp(re.Sub[0], limit-1)
+		return append(sub, ""), ok
+
+	case syntax.OpAlternate:
+		var out []string
+		for _, sub := range re.Sub {
+			words, ok := expandRegexp(sub, limit-len(out))
+			if !ok {
+				return nil, false
+			}
+			out = append(out, words...)
+		}
+		return out, true
+
+	case syntax.OpConcat:
+		out := []string{""}
+		for _, sub := range re.Sub {
+			words, ok := expandRegexp(sub, limit)
+			if !ok || len(out)*len(words) > limit {
+				return nil, false
+			}
+			next := make([]string, 0, len(out)*len(words))
+			for _, prefix := range out {
+				for _, w := range words {
+					next = append(next, prefix+w)
+				}
+			}
+			out = next
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// Open and parse a profanity list file
+func loadProfanityFile(path string, regex bool) (*ProfanityList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening profanity list %q: %w", filepath.Base(path), err)
+	}
+	defer file.Close()
+	return parseProfanityList(file, regex)
+}
+
+// Parse dirty-words.txt & profane-words.txt
+func loadDefaultProfanity(dirtyPath, profanePath string) ([]*ProfanityList, error) {
+	dirty, err := loadProfanityFile(dirtyPath, true)
+	if err != nil {
+		return nil, err
+	}
+	profane, err := loadProfanityFile(profanePath, false)
+	if err != nil {
+		return nil, err
+	}
+	return []*ProfanityList{dirty, profane}, nil
+}
+
+// Profanity lists checked on every request
+func defaultProfanityLists() []*ProfanityList {
+	if spell := spellers.Load(); spell != nil {
+		return spell.profanity
+	}
+	return nil
+}
+
+// Named profanity lists loaded from disk, selected per request with `profanity_lists`
+type profanityStore struct {
+	mu    sync.RWMutex
+	lists map[string]*ProfanityList
+}
+
+// Reads GEC_PROFANITY_DIR from env. Defaults to "profanity" in the working directory
+func GetProfanityDir() string {
+	if dir := strings.TrimSpace(os.Getenv("GEC_PROFANITY_DIR")); dir != "" {
+		return dir
+	}
+	return "profanity"
+}
+
+// Load every `<name>.txt` word list in `dir`. A missing directory loads no lists
+func LoadProfanityLists(dir string) error {
+	lists := make(map[string]*ProfanityList)
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return fmt.Errorf("failed listing profanity lists: %w", err)
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".txt")
+		if !dictNameRe.MatchString(name) {
+			continue
+		}
+		list, err := loadProfanityFile(path, false)
+		if err != nil {
+			return err
+		}
+		lists[name] = list
+	}
+
+	profanityLists.mu.Lock()
+	defer profanityLists.mu.Unlock()
+	profanityLists.lists = lists
+	return nil
+}
+
+// Names of all profanity lists loaded from disk
+func ProfanityListNames() []string {
+	profanityLists.mu.RLock()
+	defer profanityLists.mu.RUnlock()
+
+	names := make([]string, 0, len(profanityLists.lists))
+	for name := range profanityLists.lists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get a profanity list by name
+func GetProfanityList(name string) (*ProfanityList, error) {
+	profanityLists.mu.RLock()
+	defer profanityLists.mu.RUnlock()
+
+	list, ok := profanityLists.lists[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrProfanityListNotFound, name)
+	}
+	return list, nil
+}
+
+// Check if a word is allowlisted by any of the lists or the request
+func profanityAllowed(word string, lists []*ProfanityList, allow CustomWords) bool {
+	if allow.Has(word) {
+		return true
+	}
+	for _, list := range lists {
+		if list.allow.Has(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// Summarize the profanity markups in a response
+func profanityReport(markups []Markup) *ProfanityReport {
+	report := &ProfanityReport{Counts: map[string]int{}}
+	for _, m := range markups {
+		if m.Category != CategoryProfanity {
+			continue
+		}
+		report.Count++
+		report.Counts[m.Severity]++
+		if severityRank[m.Severity] > severityRank[report.MaxSeverity] {
+			report.MaxSeverity = m.Severity
+		}
+	}
+	return report
+}
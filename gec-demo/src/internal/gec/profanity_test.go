@@ -0,0 +1,247 @@
+package gec
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var profanitySample = strings.Repeat("What the hell is this shit? The damned bastards at the anus of the world said fuck it. "+
+	"Motherfucker, that is a lot of classy assessments from Scunthorpe. 😀 Nothing to see here, just ordinary words. ", 20)
+
+func TestParseProfanityList(t *testing.T) {
+	tests := []struct {
+		name     string
+		list     string
+		regex    bool
+		text     string
+		expected []string // Matched text & severity
+	}{
+		{
+			name:     "Words",
+			list:     "# Comment\nheck\tmild\ndarn\ngosh darn\tslur\n",
+			text:     "Heck, darnit darn it. Gosh darn!",
+			expected: []string{"Heck:mild", "darn:strong", "Gosh darn:slur", "darn:strong"},
+		},
+		{
+			name:     "Word boundaries",
+			list:     "ass\n",
+			text:     "class ass_ bass ass. ASS",
+			expected: []string{"ass:strong", "ASS:strong"},
+		},
+		{
+			name:     "Unicode",
+			list:     "heck\n",
+			text:     "😀 héck heck",
+			expected: []string{"heck:strong"},
+		},
+		{
+			name:     "Regex entries",
+			list:     "(dar(n|ned))\tmild\n(heck(s)?)\tslur\n",
+			regex:    true,
+			text:     "Darned hecks, heck",
+			expected: []string{"Darned:mild", "hecks:slur", "heck:slur"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := parseProfanityList(strings.NewReader(tt.list), tt.regex)
+			if err != nil {
+				t.Fatalf("parseProfanityList() returned an error: %v", err)
+			}
+
+			runes := []rune(tt.text)
+			var found []string
+			list.findAll(tt.text, runes, func(start, end int, severity string) {
+				found = append(found, string(runes[start:end])+":"+severity)
+			})
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("\nFound: %v\nExpected: %v", found, tt.expected)
+			}
+		})
+	}
+
+	list, err := parseProfanityList(strings.NewReader("heck\n!Heckington\n"), false)
+	if err != nil {
+		t.Fatalf("parseProfanityList() returned an error: %v", err)
+	}
+	if !list.allow.Has("heckington") {
+		t.Errorf("Allowlist is missing %q", "Heckington")
+	}
+	if _, err := parseProfanityList(strings.NewReader("heck\tawful\n"), false); err == nil {
+		t.Errorf("parseProfanityList() should fail for an unknown severity")
+	}
+}
+
+func TestProfanitySeverity(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "tenant.txt"), []byte("widgetville\tslur\n!hell\n"), 0o644)
+	if err != nil {
+		t.Fatalf("Failed writing profanity list: %v", err)
+	}
+	if err := LoadProfanityLists(dir); err != nil {
+		t.Fatalf("LoadProfanityLists() returned an error: %v", err)
+	}
+
+	off := false
+	text := "Oh hell, this shit is from Widgetville."
+	tests := []struct {
+		name      string
+		options   GecOptions
+		expWords  []string
+		expReport ProfanityReport
+	}{
+		{
+			name:      "Embedded lists",
+			options:   GecOptions{},
+			expWords:  []string{"hell:mild", "shit:strong"},
+			expReport: ProfanityReport{MaxSeverity: SeverityStrong, Count: 2, Counts: map[string]int{SeverityMild: 1, SeverityStrong: 1}},
+		},
+		{
+			name:      "Tenant list",
+			options:   GecOptions{ProfanityLists: []string{"tenant"}},
+			expWords:  []string{"shit:strong", "Widgetville:slur"},
+			expReport: ProfanityReport{MaxSeverity: SeveritySlur, Count: 2, Counts: map[string]int{SeverityStrong: 1, SeveritySlur: 1}},
+		},
+		{
+			name:      "Request allowlist",
+			options:   GecOptions{ProfanityAllow: []string{"SHIT"}},
+			expWords:  []string{"hell:mild"},
+			expReport: ProfanityReport{MaxSeverity: SeverityMild, Count: 1, Counts: map[string]int{SeverityMild: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.Grammar, tt.options.Spelling = &off, &off
+			opts, err := tt.options.Resolve()
+			if err != nil {
+				t.Fatalf("Resolve() returned an error: %v", err)
+			}
+			result, err := MarkupGrammar(text, opts)
+			if err != nil {
+				t.Fatalf("MarkupGrammar() returned an error: %v", err)
+			}
+
+			var words []string
+			for _, m := range result.TextMarkups {
+				words = append(words, runeSubstring(text, m.Index, m.Length)+":"+m.Severity)
+			}
+			if !reflect.DeepEqual(words, tt.expWords) {
+				t.Errorf("\nWords: %v\nExpected: %v", words, tt.expWords)
+			}
+			if !reflect.DeepEqual(*result.Profanity, tt.expReport) {
+				t.Errorf("\nReport: %+v\nExpected: %+v", *result.Profanity, tt.expReport)
+			}
+		})
+	}
+
+	if _, err := (GecOptions{ProfanityLists: []string{"missing"}}).Resolve(); !errors.Is(err, ErrProfanityListNotFound) {
+		t.Errorf("Resolve() should fail for an unknown profanity list, got %v", err)
+	}
+}
+
+// The matcher built by InitSpellChecker() should find the same words as compiling each line on its own
+func TestProfanityMatcherMatchesRegex(t *testing.T) {
+	if defaultProfanityLists() == nil {
+		t.Skip("Requires the spell checker to be initialized")
+	}
+
+	found := DirtySpellChecker(profanitySample, defaultProfanityLists(), nil, true)
+	expected := regexDirtySpellChecker(t, profanitySample)
+
+	spans := func(misspells []Misspell) []string {
+		var out []string
+		for _, m := range misspells {
+			out = append(out, fmt.Sprintf("%d:%d", m.Index, m.Length))
+		}
+		sort.Strings(out)
+		return out
+	}
+	if !reflect.DeepEqual(spans(found), spans(expected)) {
+		t.Errorf("\nMatcher: %v\nRegex: %v", spans(found), spans(expected))
+	}
+}
+
+// DirtySpellChecker() before the matcher: reopens both word lists and compiles a regex per line on every call
+func regexDirtySpellChecker(tb testing.TB, data string) []Misspell {
+	var misspells []Misspell
+	for _, path := range []string{DirtyPath, ProfanePath} {
+		file, err := os.Open(path)
+		if err != nil {
+			tb.Fatalf("Failed opening word list: %v", err)
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "#") || line == "" {
+				continue
+			}
+			word, _, _ := strings.Cut(line, "\t")
+			if path == ProfanePath {
+				word = regexp.QuoteMeta(strings.ToLower(word))
+			}
+
+			re := regexp.MustCompile(`(?i)\b(?:` + word + `)\b`)
+			for _, match := range re.FindAllStringIndex(data, -1) {
+				ind := utf8.RuneCountInString(data[:match[0]])
+				ln := utf8.RuneCountInString(data[match[0]:match[1]])
+				misspells = append(misspells, Misspell{Index: ind, Length: ln, Category: CategoryProfanity})
+			}
+		}
+		file.Close()
+	}
+	return misspells
+}
+
+func BenchmarkDirtySpellChecker(b *testing.B) {
+	if defaultProfanityLists() == nil {
+		b.Skip("Requires the spell checker to be initialized")
+	}
+	lists := defaultProfanityLists()
+	b.SetBytes(int64(len(profanitySample)))
+	b.ResetTimer()
+	for range b.N {
+		DirtySpellChecker(profanitySample, lists, nil, false)
+	}
+}
+
+func BenchmarkDirtySpellCheckerShortText(b *testing.B) {
+	if defaultProfanityLists() == nil {
+		b.Skip("Requires the spell checker to be initialized")
+	}
+	lists := defaultProfanityLists()
+	for range b.N {
+		DirtySpellChecker("What the hell is this?", lists, nil, false)
+	}
+}
+
+// Baselines for the per-call regex compilation the matcher replaced
+func BenchmarkDirtySpellCheckerRegex(b *testing.B) {
+	if DirtyPath == "" {
+		b.Skip("Requires the spell checker to be initialized")
+	}
+	b.SetBytes(int64(len(profanitySample)))
+	for range b.N {
+		regexDirtySpellChecker(b, profanitySample)
+	}
+}
+
+func BenchmarkDirtySpellCheckerRegexShortText(b *testing.B) {
+	if DirtyPath == "" {
+		b.Skip("Requires the spell checker to be initialized")
+	}
+	for range b.N {
+		regexDirtySpellChecker(b, "What the hell is this?")
+	}
+}
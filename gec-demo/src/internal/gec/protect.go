@@ -0,0 +1,408 @@
+// src/internal/gec/protect.go
+package gec
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gec-demo/src/internal/langdetect"
+	"gec-demo/src/internal/print"
+)
+
+// Kinds of text that are never spell checked or corrected
+const (
+	ProtectCode    = "CODE"
+	ProtectURL     = "URL"
+	ProtectEmail   = "EMAIL"
+	ProtectPath    = "PATH"
+	ProtectMention = "MENTION"
+	ProtectHashtag = "HASHTAG"
+	ProtectNumber  = "NUMBER"
+	ProtectForeign = "FOREIGN" // Quoted phrase or sentence in another language
+)
+
+var (
+	ProtectSpecialText = true // Skip URLs, emails, mentions, hashtags, paths, code & numbers
+	MinForeignLetters  = 20   // Shorter phrases & sentences are never treated as another language
+
+	// A phrase is only treated as another language when the language being checked is less likely than this.
+	// Names make short English phrases look foreign, so this is much stricter than MinLanguageConfidence
+	MaxForeignProbability = 0.003
+
+	// Patterns for each kind, in order of priority when they overlap.
+	// Patterns with a group protect the group, so they can check the character before it
+	protectPatterns = []struct {
+		kind string
+		re   *regexp.Regexp
+	}{
+		{ProtectCode, regexp.MustCompile("`[^`\n]+`")},
+		{ProtectURL, regexp.MustCompile(`(?i)\b(?:https?://|ftp://|www\.)[^\s<>"'` + "`" + `]+`)},
+		{ProtectEmail, regexp.MustCompile(`\b[\w.+-]+@[\w-]+(?:\.[\w-]+)+\b`)},
+		{ProtectPath, regexp.MustCompile(`(?:^|[\s(\["'])((?:~|\.{1,2})(?:/[\w.@-]+)+/?|(?:[\w.@-]*/[\w.@-]+){2,}/?|[A-Za-z]:\\[^\s"'<>|]+|[\w-]+\.(?:go|py|js|ts|jsx|tsx|json|ya?ml|toml|ini|env|txt|md|csv|html?|css|sh|c|h|cpp|hpp|rs|java|rb|php|sql|log|xml|gob|zip|docx?|pdf|png|jpe?g))\b`)},
+		{ProtectMention, regexp.MustCompile(`(?:^|[^\w@.])(@\w+)`)},
+		{ProtectHashtag, regexp.MustCompile(`(?:^|[^\w&#])(#\w*[^\W\d]\w*)`)},
+		{ProtectNumber, regexp.MustCompile(`\b\d(?:[\d.,:/-]*\d)?(?:%|(?:st|nd|rd|th|s|am|pm|k|m|x|px|ms)?\b)`)},
+	}
+
+	reQuoted     = regexp.MustCompile(`"([^"\n]+)"`)                 // Phrases in double quotes, checked for another language
+	reSegmentEnd = regexp.MustCompile(`[.!?]+["')\]]*(?:\s+|$)|\n+`) // Rough sentence ends, as Punkt isn't needed to find foreign sentences
+
+	// Placeholders swapped in for protected text while the model runs
+	rePlaceholder = regexp.MustCompile(`(?i)\b(CODE|URL|EMAIL|PATH|MENTION|HASHTAG|NUMBER|FOREIGN)(\d+)\b`)
+)
+
+// Text the checks leave alone, with byte offsets for masking and rune offsets for markups
+type protectedSpan struct {
+	Kind   string
+	Start  int
+	End    int
+	Index  int
+	Length int
+}
+
+// Find the text in `text` that must not be spell checked or corrected, sorted by offset.
+// Each request finds them once in its cleaned text, then slices them for its sentences with sentenceSpans()
+func findProtectedSpans(text string, opts CheckOptions) []protectedSpan {
+	if !ProtectSpecialText {
+		return nil
+	}
+
+	var spans []protectedSpan
+	add := func(kind string, start, end int) {
+		for _, s := range spans {
+			if start < s.End && s.Start < end {
+				return // Overlaps a span of higher priority
+			}
+		}
+		spans = append(spans, protectedSpan{Kind: kind, Start: start, End: end})
+	}
+
+	for _, p := range protectPatterns {
+		for _, m := range p.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[0], m[1]
+			if len(m) > 2 {
+				start, end = m[2], m[3]
+			}
+			if p.kind == ProtectURL {
+				end = trimUrl(text, start, end)
+			}
+			add(p.kind, start, end)
+		}
+	}
+
+	// Quoted phrases first, then whole sentences in another language
+	for _, m := range reQuoted.FindAllStringSubmatchIndex(text, -1) {
+		if isForeign(text[m[2]:m[3]], opts) {
+			add(ProtectForeign, m[2], m[3])
+		}
+	}
+	start := 0
+	for _, m := range append(reSegmentEnd.FindAllStringIndex(text, -1), []int{len(text), len(text)}) {
+		// Leave the punctuation ending the sentence to be checked
+		seg := strings.TrimSpace(text[start:m[0]])
+		if seg != "" && isForeign(seg, opts) {
+			segStart := start + strings.Index(text[start:m[0]], seg)
+			add(ProtectForeign, segStart, segStart+len(seg))
+		}
+		start = m[1]
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+	for i := range spans {
+		spans[i].Index = utf8.RuneCountInString(text[:spans[i].Start])
+		spans[i].Length = utf8.RuneCountInString(text[spans[i].Start:spans[i].End])
+	}
+	return spans
+}
+
+// Drop punctuation after a URL that ends the sentence, keeping brackets opened in the URL
+func trimUrl(text string, start, end int) int {
+	for end > start {
+		switch text[end-1] {
+		case '.', ',', ';', ':', '!', '?', '\'', '"', ']':
+			end--
+			continue
+		case ')':
+			if strings.Count(text[start:end], "(") < strings.Count(text[start:end], ")") {
+				end--
+				continue
+			}
+		}
+		break
+	}
+	return end
+}
+
+// Check if a phrase is confidently in a language other than the one being checked
+func isForeign(phrase string, opts CheckOptions) bool {
+	if !opts.DetectLanguage {
+		return false
+	}
+	lang := opts.Language
+	letters := 0
+	for _, r := range phrase {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters < MinForeignLetters {
+		return false
+	}
+	probs := langdetect.Probabilities(phrase)
+	if p, ok := probs[lang.code()]; ok && p >= MaxForeignProbability {
+		return false
+	}
+	for code, p := range probs {
+		if code != lang.code() && p >= MinLanguageConfidence {
+			return true
+		}
+	}
+	return false
+}
+
+// Spans inside text[start:end], moved to offsets within it. Spans crossing its edges are left out
+func sliceSpans(text string, spans []protectedSpan, start, end int) []protectedSpan {
+	var sliced []protectedSpan
+	runeStart := -1
+	for _, s := range spans {
+		if s.Start < start || s.End > end {
+			continue
+		}
+		if runeStart == -1 {
+			runeStart = utf8.RuneCountInString(text[:start])
+		}
+		s.Start, s.End, s.Index = s.Start-start, s.End-start, s.Index-runeStart
+		sliced = append(sliced, s)
+	}
+	return sliced
+}
+
+// Spans of each text PreprocessText() split `text` into. Newline literals and texts that can't be found get none
+func sentenceSpans(text string, allTexts []string, spans []protectedSpan) [][]protectedSpan {
+	result := make([][]protectedSpan, len(allTexts))
+	if len(spans) == 0 {
+		return result
+	}
+	located := locateSentences(text, allTexts)
+	k := 0
+	for i, t := range allTexts {
+		if k < len(located) && located[k].Text == t {
+			result[i] = sliceSpans(text, spans, located[k].Start, located[k].End)
+			k++
+		}
+	}
+	return result
+}
+
+// Check if a markup or misspelling covers any protected text.
+// Insertions at the edges of a span are allowed, so punctuation can still be added around it
+func overlapsProtected(spans []protectedSpan, index, length int, insert bool) bool {
+	for _, s := range spans {
+		if insert {
+			if index > s.Index && index < s.Index+s.Length {
+				return true
+			}
+			continue
+		}
+		if index < s.Index+s.Length && s.Index < index+length {
+			return true
+		}
+	}
+	return false
+}
+
+// Drop spelling mistakes found in protected text
+func dropProtectedSpelling(misspells []Misspell, spans []protectedSpan) []Misspell {
+	if len(spans) == 0 {
+		return misspells
+	}
+	var kept []Misspell
+	for _, miss := range misspells {
+		if miss.Category == CategorySpelling && overlapsProtected(spans, miss.Index, miss.Length, false) {
+			continue
+		}
+		kept = append(kept, miss)
+	}
+	return kept
+}
+
+// Protected text swapped for placeholders, so the sentence splitter & model can't change it
+type textMask struct {
+	texts     []string // Texts before masking
+	originals []string // Protected text, indexed by placeholder number
+	kinds     []string
+}
+
+// Swap the protected text in each text for numbered placeholders. `spans` holds the protected spans of each text.
+// Returns a nil mask, and the texts unchanged, when there is nothing to protect or the placeholders wouldn't survive
+func maskTexts(texts []string, spans [][]protectedSpan) ([]string, *textMask) {
+	for _, t := range texts {
+		if rePlaceholder.MatchString(t) {
+			print.Debug("Text already contains a placeholder, leaving it unmasked: %q", t)
+			return texts, nil
+		}
+	}
+
+	mask := &textMask{texts: texts}
+	masked := make([]string, len(texts))
+	for i, t := range texts {
+		var out strings.Builder
+		last := 0
+		var textSpans []protectedSpan
+		if i < len(spans) {
+			textSpans = spans[i]
+		}
+		for _, s := range textSpans {
+			out.WriteString(t[last:s.Start])
+			out.WriteString(s.Kind + strconv.Itoa(len(mask.originals)))
+			mask.originals = append(mask.originals, t[s.Start:s.End])
+			mask.kinds = append(mask.kinds, s.Kind)
+			last = s.End
+		}
+		out.WriteString(t[last:])
+		masked[i] = out.String()
+	}
+	if len(mask.originals) == 0 {
+		return texts, nil
+	}
+
+	// Placeholders run into a neighbouring word can't be found again
+	if _, ok := mask.unmask(strings.Join(masked, " ")); !ok {
+		print.Debug("Protected text is joined to a word, leaving it unmasked: %q", texts)
+		return texts, nil
+	}
+	return masked, mask
+}
+
+// Put the protected text back in place of its placeholders.
+// Reports whether every placeholder was restored exactly once
+func (m *textMask) unmask(text string) (string, bool) {
+	if m == nil {
+		return text, true
+	}
+	restored := make([]int, len(m.originals))
+	out := rePlaceholder.ReplaceAllStringFunc(text, func(ph string) string {
+		n, ok := m.placeholder(ph)
+		if !ok {
+			return ph
+		}
+		restored[n]++
+		return m.originals[n]
+	})
+	for _, count := range restored {
+		if count != 1 {
+			return out, false
+		}
+	}
+	return out, true
+}
+
+// Number of a placeholder of the mask, false if `ph` isn't one
+func (m *textMask) placeholder(ph string) (int, bool) {
+	parts := rePlaceholder.FindStringSubmatch(ph)
+	n, err := strconv.Atoi(parts[2])
+	if err != nil || n >= len(m.originals) || !strings.EqualFold(parts[1], m.kinds[n]) {
+		return 0, false
+	}
+	return n, true
+}
+
+// Put the protected text back in a sentence's correction like unmask(), moving the token offsets along with the text.
+// Tokens that were part of a placeholder cover all of its protected text
+func (m *textMask) unmaskTokens(text string, tokens []TokenScore) (string, []TokenScore) {
+	var out strings.Builder
+	var moves [][4]int // Start & end of each placeholder, and of the protected text it was replaced with
+	last := 0
+	for _, loc := range rePlaceholder.FindAllStringIndex(text, -1) {
+		n, ok := m.placeholder(text[loc[0]:loc[1]])
+		if !ok {
+			continue
+		}
+		out.WriteString(text[last:loc[0]])
+		start := out.Len()
+		out.WriteString(m.originals[n])
+		moves = append(moves, [4]int{loc[0], loc[1], start, out.Len()})
+		last = loc[1]
+	}
+	out.WriteString(text[last:])
+
+	move := func(pos int, end bool) int {
+		moved := pos
+		for _, mv := range moves {
+			switch {
+			case pos <= mv[0]:
+				return moved
+			case pos < mv[1] && end:
+				return mv[3]
+			case pos < mv[1]:
+				return mv[2]
+			}
+			moved = pos - mv[1] + mv[3]
+		}
+		return moved
+	}
+	var moved []TokenScore
+	for _, tok := range tokens {
+		moved = append(moved, TokenScore{Start: move(tok.Start, false), End: move(tok.End, true), LogProb: tok.LogProb})
+	}
+	return out.String(), moved
+}
+
+// Restore the model's corrected text. If the model dropped or repeated a placeholder
+// the protected text can't be put back safely, so the uncorrected texts are returned instead
+func (m *textMask) restoreCorrected(corrected string) string {
+	out, ok := m.unmask(corrected)
+	if !ok {
+		print.Warning("Model output lost protected text, leaving it uncorrected. Output: %q", corrected)
+		return joinTexts(m.texts)
+	}
+	return out
+}
+
+// Restore the protected text in the correction of each sentence. If the model dropped or repeated a placeholder
+// anywhere, every sentence is left uncorrected like restoreCorrected() does
+func (m *textMask) restoreSentences(sentences []SentenceResult) []SentenceResult {
+	if m == nil || sentences == nil {
+		return sentences
+	}
+	if len(sentences) != len(m.texts) {
+		print.Warning("Model returned %d sentences for %d texts", len(sentences), len(m.texts))
+		return nil
+	}
+
+	_, ok := m.unmask(joinSentences(sentences))
+	for i := range sentences {
+		if !ok {
+			sentences[i].Text, sentences[i].Merged, sentences[i].Tokens, sentences[i].Alternatives = m.texts[i], false, nil, nil
+			continue
+		}
+
+		// Alternatives only get their protected text back if they kept the placeholders of the best correction
+		var alternatives []string
+		for _, alt := range sentences[i].Alternatives {
+			if slices.Equal(m.placeholders(alt), m.placeholders(sentences[i].Text)) {
+				restored, _ := m.unmaskTokens(alt, nil)
+				alternatives = append(alternatives, restored)
+			}
+		}
+		sentences[i].Alternatives = alternatives
+		sentences[i].Text, sentences[i].Tokens = m.unmaskTokens(sentences[i].Text, sentences[i].Tokens)
+	}
+	return sentences
+}
+
+// Numbers of the mask's placeholders in a text, in order
+func (m *textMask) placeholders(text string) []int {
+	var nums []int
+	for _, ph := range rePlaceholder.FindAllString(text, -1) {
+		if n, ok := m.placeholder(ph); ok {
+			nums = append(nums, n)
+		}
+	}
+	return nums
+}
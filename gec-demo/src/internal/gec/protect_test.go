@@ -0,0 +1,151 @@
+package gec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindProtectedSpans(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string // Kind and text of each span
+	}{
+		{text: "See https://example.com/a?b=1. Then stop", expected: []string{ProtectURL, "https://example.com/a?b=1"}},
+		{text: "(www.example.com/wiki/Go_(lang))", expected: []string{ProtectURL, "www.example.com/wiki/Go_(lang)"}},
+		{text: "Mail jon.doe@mail.example.com today", expected: []string{ProtectEmail, "jon.doe@mail.example.com"}},
+		{text: "thanks @bob_s, #golang rocks", expected: []string{ProtectMention, "@bob_s", ProtectHashtag, "#golang"}},
+		{text: "Run `go test ./...` first", expected: []string{ProtectCode, "`go test ./...`"}},
+		{text: "Edit ~/notes.txt or src/internal/gec and main.go", expected: []string{ProtectPath, "~/notes.txt", ProtectPath, "src/internal/gec", ProtectPath, "main.go"}},
+		{text: `Open C:\Users\bob\file.txt now`, expected: []string{ProtectPath, `C:\Users\bob\file.txt`}},
+		{text: "Meet at 10am on the 3rd, 50% off 1,000.50", expected: []string{ProtectNumber, "10am", ProtectNumber, "3rd", ProtectNumber, "50%", ProtectNumber, "1,000.50"}},
+		{text: "He said \"je ne sais pas pourquoi il est parti\" and left", expected: []string{ProtectForeign, "je ne sais pas pourquoi il est parti"}},
+		{text: "we shood go. Ich habe keine Zeit, weil ich morgen arbeiten muss. ok", expected: []string{ProtectForeign, "Ich habe keine Zeit, weil ich morgen arbeiten muss"}},
+		{text: "Mario and Giovanni ate pizza in Roma", expected: nil}, // Names aren't enough to be another language
+		{text: "version2 and abc123 and email@ now", expected: nil},
+	}
+
+	for _, tt := range tests {
+		var result []string
+		for _, s := range findProtectedSpans(tt.text, DefaultOptions()) {
+			result = append(result, s.Kind, tt.text[s.Start:s.End])
+			if runeSubstring(tt.text, s.Index, s.Length) != tt.text[s.Start:s.End] {
+				t.Errorf("%q: rune offsets %d+%d don't match byte offsets %d:%d", tt.text, s.Index, s.Length, s.Start, s.End)
+			}
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%q\nResult: %q\nExpected: %q", tt.text, result, tt.expected)
+		}
+	}
+
+	// Foreign text is only protected when the request detects languages
+	opts := DefaultOptions()
+	opts.DetectLanguage = false
+	if spans := findProtectedSpans(tests[8].text, opts); len(spans) != 0 {
+		t.Errorf("%q\nResult: %+v\nExpected no spans without language detection", tests[8].text, spans)
+	}
+}
+
+// Protected spans of each text on its own
+func textsSpans(texts []string) [][]protectedSpan {
+	spans := make([][]protectedSpan, len(texts))
+	for i, t := range texts {
+		spans[i] = findProtectedSpans(t, DefaultOptions())
+	}
+	return spans
+}
+
+func TestSentenceSpans(t *testing.T) {
+	text := "Go to www.example.com now. Ask @bob at 5pm.\n\nDone."
+	allTexts := []string{"Go to www.example.com now.", "Ask @bob at 5pm.", "\n\n", "Done."}
+	spans := sentenceSpans(text, allTexts, findProtectedSpans(text, DefaultOptions()))
+
+	// Each text gets the spans found in the whole text, as if they were found in it alone
+	if !reflect.DeepEqual(spans, textsSpans(allTexts)) {
+		t.Errorf("\nResult: %+v\nExpected: %+v", spans, textsSpans(allTexts))
+	}
+}
+
+func TestMaskTexts(t *testing.T) {
+	texts := []string{"see www.example.com.", "\n\n", "call @bob at 5pm."}
+	masked, mask := maskTexts(texts, textsSpans(texts))
+	expected := []string{"see URL0.", "\n\n", "call MENTION1 at NUMBER2."}
+	if !reflect.DeepEqual(masked, expected) {
+		t.Fatalf("\nResult: %q\nExpected: %q", masked, expected)
+	}
+
+	// Placeholders survive case changes from the model
+	result := mask.restoreCorrected("See url0.\n\nCall MENTION1 at NUMBER2.")
+	if result != "See www.example.com.\n\nCall @bob at 5pm." {
+		t.Errorf("\nResult: %q\nExpected the protected text restored", result)
+	}
+
+	// Lost or repeated placeholders leave the text uncorrected
+	for _, corrected := range []string{"See.\n\nCall MENTION1 at NUMBER2.", "See URL0 URL0.\n\nCall MENTION1 at NUMBER2."} {
+		result = mask.restoreCorrected(corrected)
+		if result != joinTexts(texts) {
+			t.Errorf("%q\nResult: %q\nExpected: %q", corrected, result, joinTexts(texts))
+		}
+	}
+
+	// The correction of each sentence gets its protected text back too
+	sentences := mask.restoreSentences([]SentenceResult{{Text: "See url0."}, {Text: "\n\n", Group: -1}, {Text: "Call MENTION1 at NUMBER2."}})
+	if got := joinSentences(sentences); got != "See www.example.com.\n\nCall @bob at 5pm." {
+		t.Errorf("\nResult: %q\nExpected the protected text restored", got)
+	}
+	sentences = mask.restoreSentences([]SentenceResult{{Text: "See."}, {Text: "\n\n", Group: -1}, {Text: "Call MENTION1 at NUMBER2."}})
+	if got := joinSentences(sentences); got != joinTexts(texts) {
+		t.Errorf("\nResult: %q\nExpected: %q", got, joinTexts(texts))
+	}
+
+	// Token offsets move with the protected text, and a placeholder's tokens cover all of it
+	sentences = mask.restoreSentences([]SentenceResult{
+		{Text: "See url0.", Tokens: []TokenScore{{Start: 0, End: 3}, {Start: 3, End: 8}, {Start: 8, End: 9}}},
+		{Text: "\n\n", Group: -1},
+		{Text: "Call MENTION1 at NUMBER2."},
+	})
+	expectedTokens := []TokenScore{{Start: 0, End: 3}, {Start: 3, End: 19}, {Start: 19, End: 20}}
+	if !reflect.DeepEqual(sentences[0].Tokens, expectedTokens) {
+		t.Errorf("\nResult: %v\nExpected: %v", sentences[0].Tokens, expectedTokens)
+	}
+
+	// Alternatives get their protected text back too, unless they lost a placeholder
+	sentences = mask.restoreSentences([]SentenceResult{
+		{Text: "See url0.", Alternatives: []string{"Look at URL0.", "See it."}},
+		{Text: "\n\n", Group: -1},
+		{Text: "Call MENTION1 at NUMBER2."},
+	})
+	if expected := []string{"Look at www.example.com."}; !reflect.DeepEqual(sentences[0].Alternatives, expected) {
+		t.Errorf("\nResult: %q\nExpected: %q", sentences[0].Alternatives, expected)
+	}
+
+	// Texts that already look like placeholders, or have protected text joined to a word, are not masked
+	for _, text := range []string{"the URL1 field", "foo`bar`"} {
+		masked, mask = maskTexts([]string{text}, textsSpans([]string{text}))
+		if mask != nil || masked[0] != text {
+			t.Errorf("%q\nResult: %q\nExpected the text unmasked", text, masked)
+		}
+	}
+}
+
+func TestMarkupGrammarProtected(t *testing.T) {
+	if defaultLanguage() == nil {
+		t.Skip("languages not loaded")
+	}
+
+	text := "www.shood.com is down. i think `teh` broke at 5pm. ask @shood."
+	result, err := MarkupGrammar(text, DefaultOptions())
+	if err != nil {
+		t.Fatalf("MarkupGrammar() returned an error: %v", err)
+	}
+
+	// Only the pronoun and the sentence start outside protected text are corrected
+	expected := "www.shood.com is down. I think `teh` broke at 5pm. Ask @shood."
+	if result.CorrectedText != expected {
+		t.Errorf("\nResult: %q\nExpected: %q", result.CorrectedText, expected)
+	}
+	for _, markup := range result.TextMarkups {
+		if markup.Category == CategorySpelling {
+			t.Errorf("Unexpected spelling mistake in protected text: %+v", markup)
+		}
+	}
+}
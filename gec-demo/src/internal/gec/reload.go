@@ -0,0 +1,105 @@
+// src/internal/gec/reload.go
+package gec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gec-demo/src/internal/print"
+)
+
+// Reads GEC_DATA_DIR from env. Files in it override the embedded data files with the same name
+// (index.aff, index.dic, spelling_custom.txt, dirty-words.txt, profane-words.txt). Empty uses the embedded files only
+func GetDataDir() string {
+	return strings.TrimSpace(os.Getenv("GEC_DATA_DIR"))
+}
+
+// Reads GEC_RELOAD_INTERVAL (seconds) from env. Defaults to 10, and 0 turns off watching
+func GetReloadInterval() time.Duration {
+	defaultInterval := 10 * time.Second
+	s := strings.TrimSpace(os.Getenv("GEC_RELOAD_INTERVAL"))
+	if s == "" {
+		return defaultInterval
+	}
+	seconds, err := strconv.Atoi(s)
+	if err != nil || seconds < 0 {
+		print.Warning("Invalid GEC_RELOAD_INTERVAL=%q, using %v", s, defaultInterval)
+		return defaultInterval
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Reload every data file from disk: the spell checker & profanity data, the tenant profanity lists
+// and the custom dictionaries. Each is swapped in on its own, so one failing keeps only its old version
+func ReloadData() error {
+	var errs []error
+	if DoMisspellings {
+		if err := ReloadSpellChecker(); err != nil {
+			errs = append(errs, fmt.Errorf("failed reloading spell checker: %w", err))
+		}
+	}
+	if err := LoadProfanityLists(GetProfanityDir()); err != nil {
+		errs = append(errs, fmt.Errorf("failed reloading profanity lists: %w", err))
+	}
+	if err := LoadDictionaries(GetDictionaryDir()); err != nil {
+		errs = append(errs, fmt.Errorf("failed reloading dictionaries: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// Poll the data & profanity directories every `interval` and reload when a file changes.
+// Runs until `stop` is closed
+func WatchDataDirs(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := dataDirsState()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		state := dataDirsState()
+		if state == last {
+			continue
+		}
+		last = state
+
+		print.Info("Data files changed, reloading")
+		if err := ReloadData(); err != nil {
+			print.Error("%v", err)
+		}
+	}
+}
+
+// Name, size and modification time of every file in the watched directories.
+// Custom dictionaries are left out as the API already keeps them up to date
+func dataDirsState() string {
+	var sb strings.Builder
+	for _, dir := range []string{GetDataDir(), GetProfanityDir()} {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || info.IsDir() {
+				continue
+			}
+			fmt.Fprintf(&sb, "%s\x00%d\x00%d\n", filepath.Join(dir, entry.Name()), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,117 @@
+package gec
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReloadSpellChecker(t *testing.T) {
+	if spellers.Load() == nil {
+		t.Skip("spell checker not initialized")
+	}
+	text := "What the hell is a widget?"
+
+	// Files in the data directory override the embedded ones
+	dir := t.TempDir()
+	t.Setenv("GEC_DATA_DIR", dir)
+	if err := os.WriteFile(filepath.Join(dir, "dirty-words.txt"), []byte("widgets?\tmild\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Unsetenv("GEC_DATA_DIR")
+		if err := ReloadSpellChecker(); err != nil {
+			t.Errorf("ReloadSpellChecker() returned an error restoring the embedded files: %v", err)
+		}
+	})
+
+	// Options resolved before the reload keep the old lists
+	before := DefaultOptions()
+
+	// Requests in flight during the reload should keep working
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := MarkupGrammar("we shood go. "+text, DefaultOptions()); err != nil {
+					t.Errorf("MarkupGrammar() returned an error during reload: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	for range 5 {
+		if err := ReloadSpellChecker(); err != nil {
+			t.Fatalf("ReloadSpellChecker() returned an error: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	tests := []struct {
+		name     string
+		opts     CheckOptions
+		expWords []string
+	}{
+		{name: "Before reload", opts: before, expWords: []string{"hell"}},
+		{name: "After reload", opts: DefaultOptions(), expWords: []string{"widget"}},
+	}
+	for _, tt := range tests {
+		runes := []rune(text)
+		var words []string
+		for _, m := range DirtySpellChecker(text, tt.opts.ProfanityLists, nil, false) {
+			words = append(words, string(runes[m.Index:m.Index+m.Length]))
+		}
+		if len(words) != len(tt.expWords) || (len(words) > 0 && words[0] != tt.expWords[0]) {
+			t.Errorf("%s:\nResult: %q\nExpected: %q", tt.name, words, tt.expWords)
+		}
+	}
+
+	// A broken file keeps the lists already loaded
+	if err := os.WriteFile(filepath.Join(dir, "dirty-words.txt"), []byte("widgets?\tawful\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadSpellChecker(); err == nil {
+		t.Errorf("ReloadSpellChecker() should fail on an unknown severity")
+	}
+	if len(DirtySpellChecker(text, DefaultOptions().ProfanityLists, nil, false)) != 1 {
+		t.Errorf("A failed reload should keep the previous lists")
+	}
+}
+
+func TestWatchDataDirs(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GEC_PROFANITY_DIR", dir)
+	t.Setenv("GEC_DICTIONARY_DIR", t.TempDir())
+	if err := LoadProfanityLists(dir); err != nil {
+		t.Fatalf("LoadProfanityLists() returned an error: %v", err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go WatchDataDirs(10*time.Millisecond, stop)
+	time.Sleep(20 * time.Millisecond)
+
+	// Adding a list should load it without a restart
+	if err := os.WriteFile(filepath.Join(dir, "tenant.txt"), []byte("widget\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := GetProfanityList("tenant"); err == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("WatchDataDirs() did not load the new profanity list")
+}
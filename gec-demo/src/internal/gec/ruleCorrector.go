@@ -0,0 +1,86 @@
+// src/internal/gec/ruleCorrector.go
+package gec
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	_ = RegisterCorrector("rules", newRuleCorrector)
+	_ = RegisterCorrector("echo", newEchoCorrector)
+
+	rePronounI   = regexp.MustCompile(`(^|[\s"(])i([\s,;:!?]|\.(?:\s|$)|'[a-z]{1,2}\b|$)`) // Lowercase pronoun "i"
+	reMultiSpace = regexp.MustCompile(`[ ]{2,}`)                                           // Runs of spaces
+)
+
+// Pure-Go backend for running the pipeline without the native runtime.
+// With `rules` disabled it echoes the input back unchanged.
+type ruleCorrector struct {
+	gpuId int
+	rules bool
+}
+
+func newRuleCorrector(gpuId int) (Corrector, error) {
+	return &ruleCorrector{gpuId: gpuId, rules: true}, nil
+}
+
+func newEchoCorrector(gpuId int) (Corrector, error) {
+	return &ruleCorrector{gpuId: gpuId, rules: false}, nil
+}
+
+func (rc *ruleCorrector) Correct(allTexts []string, opts DecodeOptions) GrammarResult {
+	startTime := time.Now()
+	backend := "echo"
+	if rc.rules {
+		backend = "rules"
+	}
+
+	// Each sentence is corrected on its own
+	sentences := make([]SentenceResult, len(allTexts))
+	for i, t := range allTexts {
+		group := -1
+		if !strings.Contains(t, "\n") {
+			group = i
+			if rc.rules {
+				t = applyRules(t)
+			}
+		}
+		sentences[i] = SentenceResult{Text: t, Group: group}
+	}
+
+	return GrammarResult{
+		CorrectText: joinSentences(sentences),
+		Sentences:   sentences,
+		GpuId:       rc.gpuId,
+		Backend:     backend,
+		Decoding:    opts,
+		ServiceTime: time.Since(startTime).Seconds(),
+	}
+}
+
+func (rc *ruleCorrector) Close() {}
+
+// Apply simple deterministic corrections to a single sentence
+func applyRules(sent string) string {
+	sent = reMultiSpace.ReplaceAllString(sent, " ")
+
+	// Neighbouring pronouns share the whitespace between them, so "i i" takes more than one pass
+	for {
+		next := rePronounI.ReplaceAllString(sent, "${1}I${2}")
+		if next == sent {
+			break
+		}
+		sent = next
+	}
+
+	// Capitalize the first letter of the sentence
+	r, size := utf8.DecodeRuneInString(sent)
+	if unicode.IsLower(r) {
+		sent = string(unicode.ToUpper(r)) + sent[size:]
+	}
+	return sent
+}
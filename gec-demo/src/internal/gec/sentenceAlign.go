@@ -0,0 +1,167 @@
+// src/internal/gec/sentenceAlign.go
+package gec
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gec-demo/src/internal/print"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// A sentence or newline literal of the original text, and what the model corrected it to
+type sentencePair struct {
+	start     int // Rune offset of the sentence in the original text
+	original  string
+	corrected string
+	tokens    []TokenScore // Tokens the model generated the correction from, nil if unknown
+
+	alternatives []string // Less likely corrections of the sentence from n-best decoding
+}
+
+// Find the differences between the original & corrected text sentence by sentence, so a model that merges or
+// splits lines only loses the corrections of the sentences it changed the lines of.
+// `sentences` holds the model's correction of each sentence if it is known, so they don't need to be aligned.
+// `protected` holds the protected spans of the original text
+func FindSentenceDifferences(text, corrected string, sentences []SentenceResult, opts CheckOptions, protected []protectedSpan, Misspells []Misspell) []Markup {
+	var Differences []Markup
+	for _, pair := range alignSentences(text, corrected, sentences, opts, protected) {
+		if pair.original == pair.corrected {
+			continue
+		}
+		added := len(Differences)
+		if err := findDifference(pair.original, pair.corrected, pair.start, Misspells, &Differences, tokenScorer(pair.corrected, pair.tokens)); err != nil {
+			print.Warning("Leaving the sentence at %d uncorrected, %v. Sentence: %q, Corrected: %q", pair.start, err, pair.original, pair.corrected)
+			continue
+		}
+		addAlternatives(Differences[added:], pair, Misspells)
+	}
+
+	// Sort the diffs slice based on the Index field
+	sort.Slice(Differences, func(i, j int) bool {
+		return Differences[i].Index < Differences[j].Index
+	})
+	return Differences
+}
+
+// Add the replacements the model's other corrections of a sentence suggest for the text its best correction changes.
+// Changes only the other corrections make are left out, as the model thought the text was more likely right
+func addAlternatives(markups []Markup, pair sentencePair, Misspells []Misspell) {
+	for _, alt := range pair.alternatives {
+		var altMarkups []Markup
+		if findDifference(pair.original, alt, pair.start, Misspells, &altMarkups, nil) != nil {
+			continue
+		}
+		for _, am := range altMarkups {
+			for i := range markups {
+				m := &markups[i]
+				if m.Index == am.Index && m.Length == am.Length && m.insert == am.insert && !slices.Contains(m.Replacements, am.Replacements[0]) {
+					m.Replacements = append(m.Replacements, am.Replacements[0])
+				}
+			}
+		}
+	}
+}
+
+// Pair each sentence & newline literal PreprocessText() split the original text into with its corrected counterpart.
+// The model's correction of each sentence is used when there is one per sentence. Otherwise sentences are paired by
+// index when the corrected text splits into as many pieces, or get the corrected text their characters align with
+func alignSentences(text, corrected string, results []SentenceResult, opts CheckOptions, protected []protectedSpan) []sentencePair {
+	sentences := splitSentences(text, opts.Language, protected)
+	starts, ok := sentenceStarts(text, sentences)
+	if !ok {
+		print.Debug("Sentences not found in the original text, diffing it whole")
+		return []sentencePair{{original: text, corrected: corrected}}
+	}
+
+	if len(results) == len(sentences) {
+		return resultPairs(text, sentences, starts, results)
+	}
+	if results != nil {
+		print.Warning("Model corrected %d sentences, expected %d. Aligning the corrected text instead", len(results), len(sentences))
+	}
+
+	pairs := make([]sentencePair, len(sentences))
+	for i, sent := range sentences {
+		pairs[i] = sentencePair{start: starts[i], original: sent}
+	}
+
+	correctedSentences := splitSentences(corrected, opts.Language, findProtectedSpans(corrected, opts))
+	if len(correctedSentences) == len(sentences) {
+		for i := range pairs {
+			pairs[i].corrected = correctedSentences[i]
+		}
+		return pairs
+	}
+
+	print.Debug("Corrected text split into %d sentences, expected %d. Aligning them by character", len(correctedSentences), len(sentences))
+	units := alignTokens(text, corrected)
+	correctedRunes := []rune(corrected)
+	prevEnd := 0
+	for i := range pairs {
+		start := max(mapOffset(units, pairs[i].start, false), prevEnd)
+		end := max(mapOffset(units, pairs[i].start+utf8.RuneCountInString(pairs[i].original), true), start)
+		pairs[i].corrected = string(correctedRunes[start:end])
+		if !strings.Contains(pairs[i].original, "\n") {
+			// Sentences are trimmed, like the original ones
+			pairs[i].corrected = strings.TrimSpace(pairs[i].corrected)
+		}
+		prevEnd = end
+	}
+	return pairs
+}
+
+// Pair the sentences with the model's correction of each. Sentences corrected together are paired as one
+func resultPairs(text string, sentences []string, starts []int, results []SentenceResult) []sentencePair {
+	textRunes := []rune(text)
+	var pairs []sentencePair
+	for i, res := range results {
+		if res.Merged && len(pairs) > 0 {
+			last := &pairs[len(pairs)-1]
+			end := starts[i] + utf8.RuneCountInString(sentences[i])
+			last.original = string(textRunes[last.start:end])
+			continue
+		}
+		pairs = append(pairs, sentencePair{start: starts[i], original: sentences[i], corrected: res.Text, tokens: res.Tokens, alternatives: res.Alternatives})
+	}
+	return pairs
+}
+
+// Rune offsets of the sentences in the text they were split from. Returns false if one can't be found
+func sentenceStarts(text string, sentences []string) ([]int, bool) {
+	starts := make([]int, len(sentences))
+	cursor, runes := 0, 0 // Byte & rune offset of the end of the last sentence
+	for i, sent := range sentences {
+		ind := strings.Index(text[cursor:], sent)
+		if ind == -1 {
+			return nil, false
+		}
+		runes += utf8.RuneCountInString(text[cursor : cursor+ind])
+		starts[i] = runes
+		cursor += ind + len(sent)
+		runes += utf8.RuneCountInString(sent)
+	}
+	return starts, true
+}
+
+// Map a rune offset of the original text to the corrected text through its alignment. Offsets inside a change map
+// to its end. Text inserted at the offset is only counted before it when it ends a sentence, for `end`
+func mapOffset(units []diffUnit, offset int, end bool) int {
+	origPos, corrPos := 0, 0
+	for _, u := range units {
+		n := utf8.RuneCountInString(u.text)
+		if origPos > offset || (origPos == offset && (u.op != diffmatchpatch.DiffInsert || !end)) {
+			break
+		}
+		if u.op != diffmatchpatch.DiffInsert {
+			origPos += n
+		}
+		if u.op != diffmatchpatch.DiffDelete {
+			corrPos += n
+		}
+	}
+	return corrPos
+}
@@ -5,18 +5,23 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"unicode"
 	"unicode/utf8"
 	"embed"
 	
 	"gec-demo/src/internal/print"
-	hunspell "github.com/sthorne/go-hunspell"
 )
 
 var (
-	huns           *hunspell.Hunhandle
+	spellers      atomic.Pointer[spellData] // Swapped by ReloadSpellChecker() while requests are in flight
+	reloadMu      sync.Mutex                // Only one reload runs at a time
+	embedPaths    = map[string]string{}     // Embedded data files already written to temp files
 	DirtyPath     string // List of inappropriate words
 	ProfanePath   string // List of words to be marked as profanity
 
@@ -55,44 +60,146 @@ func writeTempFileFromEmbed(embedPath, prefix string) (string, error) {
 	return tmp.Name(), nil
 }
 
-func InitSpellChecker() (err error) {
+// Hunspell handles & default profanity lists, built from the same data files and swapped in together
+type spellData struct {
+	pools     map[string]*spellPool // By language code. Languages without a dictionary have none
+	profanity []*ProfanityList
+}
+
+// Path to load a data file from. Files in GEC_DATA_DIR override the embedded ones,
+// which are written out to temp files the first time they are needed
+func dataFilePath(embedPath, prefix string) (string, error) {
+	if dir := GetDataDir(); dir != "" {
+		path := filepath.Join(dir, filepath.Base(embedPath))
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	if path, ok := embedPaths[embedPath]; ok {
+		return path, nil
+	}
+	path, err := writeTempFileFromEmbed(embedPath, prefix)
+	if err != nil {
+		return "", err
+	}
+	embedPaths[embedPath] = path
+	return path, nil
+}
+
+func InitSpellChecker() error {
+	return ReloadSpellChecker()
+}
+
+// Build a new Hunspell handle & profanity matchers from the data files, then swap them in.
+// Requests already running keep the ones they started with. On error the old ones stay in use
+func ReloadSpellChecker() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
 	// Path to Affix for misspelled words
-	affPath, err := writeTempFileFromEmbed(affixFile, "gec-aff-")
+	affPath, err := dataFilePath(affixFile, "gec-aff-")
 	if err != nil {
 		return err
 	}
 
 	// Path to Dictionary for misspelled words
-	dictPath, err := writeTempFileFromEmbed(dictFile, "gec-dic-")
+	dictPath, err := dataFilePath(dictFile, "gec-dic-")
 	if err != nil {
 		return err
 	}
 
 	// Files holds list of words to add to our current dictionary
-	customSpellPath, err := writeTempFileFromEmbed(customFile, "gec-custom-")
+	customSpellPath, err := dataFilePath(customFile, "gec-custom-")
+	if err != nil {
+		return err
+	}
+
+	dirtyPath, err := dataFilePath(dirtyFile, "gec-dirty-")
 	if err != nil {
 		return err
 	}
 
-	DirtyPath, err = writeTempFileFromEmbed(dirtyFile, "gec-dirty-")
+	profanePath, err := dataFilePath(profaneFile, "gec-profane-")
 	if err != nil {
 		return err
 	}
 
-	ProfanePath, err = writeTempFileFromEmbed(profaneFile, "gec-profane-")
+	// Compile the profanity lists once for every request
+	profanity, err := loadDefaultProfanity(dirtyPath, profanePath)
 	if err != nil {
 		return err
 	}
 
-	// Load Hunspell using temp files
-	huns = hunspell.Hunspell(affPath, dictPath)
+	// Hunspell silently loads an empty dictionary from a missing file, so check they can be read
+	for _, path := range []string{affPath, dictPath} {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("failed loading hunspell file: %w", err)
+		}
+	}
+
+	// Load the Hunspell handles using the data files
+	pool, err := newSpellPool(GetSpellPoolSize(), affPath, dictPath)
+	if err != nil {
+		return err
+	}
 
 	// Add more words to the loaded dictionary
-	return addToDictionary(customSpellPath)
+	if err := addToDictionary(pool, customSpellPath); err != nil {
+		return err
+	}
+
+	// Load the dictionaries of the other languages
+	pools := map[string]*spellPool{DefaultLanguage: pool}
+	for _, code := range LanguageCodes() {
+		if code == DefaultLanguage {
+			continue
+		}
+		pool, err := loadLanguagePool(code)
+		if err != nil {
+			return err
+		}
+		if pool != nil {
+			pools[code] = pool
+		}
+	}
+
+	DirtyPath, ProfanePath = dirtyPath, profanePath
+	spellers.Store(&spellData{pools: pools, profanity: profanity})
+	return nil
+}
+
+// Load the spell checker for a language from index.aff, index.dic & spelling_custom.txt in its
+// GEC_DATA_DIR directory. Languages without a dictionary are not spell checked
+func loadLanguagePool(code string) (*spellPool, error) {
+	if GetDataDir() == "" {
+		print.Warning("No Hunspell dictionary for language %q without GEC_DATA_DIR, spelling will not be checked", code)
+		return nil, nil
+	}
+	dir := filepath.Join(GetDataDir(), code)
+	affPath, dictPath := filepath.Join(dir, filepath.Base(affixFile)), filepath.Join(dir, filepath.Base(dictFile))
+	for _, path := range []string{affPath, dictPath} {
+		if _, err := os.Stat(path); err != nil {
+			print.Warning("No Hunspell dictionary for language %q in %s, spelling will not be checked", code, dir)
+			return nil, nil
+		}
+	}
+
+	pool, err := newSpellPool(GetSpellPoolSize(), affPath, dictPath)
+	if err != nil {
+		return nil, err
+	}
+	customSpellPath := filepath.Join(dir, filepath.Base(customFile))
+	if _, err := os.Stat(customSpellPath); err == nil {
+		if err := addToDictionary(pool, customSpellPath); err != nil {
+			return nil, err
+		}
+	}
+	return pool, nil
 }
 
 // Read in a file and add valid words to the dictionary
-func addToDictionary(customSpellPath string) error {
+func addToDictionary(pool *spellPool, customSpellPath string) error {
 	// Read in a file
 	file, err := os.Open(customSpellPath)
 	if err != nil {
@@ -101,6 +208,7 @@ func addToDictionary(customSpellPath string) error {
 	defer file.Close()
 
 	// Read each line from the file
+	var words []string
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		word := strings.TrimSpace(scanner.Text())
@@ -112,7 +220,7 @@ func addToDictionary(customSpellPath string) error {
 
 		// If string is valid add it to the dictionary
 		if validStr.MatchString(word) {
-			huns.Add(word)
+			words = append(words, word)
 		}
 	}
 
@@ -120,12 +228,35 @@ func addToDictionary(customSpellPath string) error {
 	if err := scanner.Err(); err != nil {
 		return fmt.Errorf("failed scanning dictionary file: %w", err)
 	}
+	pool.add(words...)
+	return nil
+}
+
+// Add words to a language's loaded spell checker for every request. They are lost on the next
+// ReloadSpellChecker(), so words that must last belong in spelling_custom.txt or a custom dictionary
+func addSpellingWords(language string, words []string) error {
+	if err := validateWords(words); err != nil {
+		return err
+	}
+	spell := spellers.Load()
+	if spell == nil {
+		return fmt.Errorf("spell checker not initialized")
+	}
+	pool, ok := spell.pools[language]
+	if !ok {
+		return fmt.Errorf("%w %q has no spell checker", ErrUnsupportedLanguage, language)
+	}
+	trimmed := make([]string, len(words))
+	for i, word := range words {
+		trimmed[i] = strings.TrimSpace(word)
+	}
+	pool.add(trimmed...)
 	return nil
 }
 
 // Function to check for index collision
-func checkCollision(Misspells []Misspell, newIndex, newLength int) bool {
-	if IgnoreCollisions {
+func checkCollision(Misspells []Misspell, newIndex, newLength int, ignoreCollisions bool) bool {
+	if ignoreCollisions {
 		// If we are ignoring collisions then always mark it as never having a collision
 		return false
 	}
@@ -189,9 +320,21 @@ func cleanWord(word string) string {
 	return word
 }
 
-// SpellChecker
-func SpellChecker(misspells []Misspell, data string) []Misspell {
-	misspells = MarkEmojis(misspells, data)
+// SpellChecker for the language's dictionary. Words in the `custom` dictionary are never marked
+func SpellChecker(misspells []Misspell, data string, ignoreCollisions bool, custom CustomWords, lang *Language) []Misspell {
+	spell := spellers.Load()
+	if spell == nil {
+		return misspells
+	}
+	pool, ok := spell.pools[lang.code()]
+	if !ok {
+		return misspells
+	}
+
+	// Keep the handle for the whole text so each request only waits once
+	huns := pool.get()
+	defer pool.put(huns)
+
 	wordsInFile := strings.Fields(data)
 	wordStartIndex := 0
 
@@ -205,14 +348,19 @@ func SpellChecker(misspells []Misspell, data string) []Misspell {
 		cleaned := cleanWord(word)
 		cleanLen := utf8.RuneCountInString(cleaned)
 
-		if !(huns.Spell(cleaned)) {
+		// Words of only punctuation, like a `<` decoded from `&lt;`, have nothing left to check
+		if cleaned == "" {
+			continue
+		}
+
+		if !(huns.Spell(cleaned)) && !custom.Has(cleaned) {
 			// Add length of the removed prefix to the index
 			index += utf8.RuneCountInString(strings.Split(word, cleaned)[0])
 			suggested := huns.Suggest(cleaned)
 
 			// Check for collisions
-			if !checkCollision(misspells, index, cleanLen) {
-				misspells = append(misspells, Misspell{Index: index, Length: cleanLen, Category: "SPELLING_MISTAKE", Suggestions: suggested})
+			if !checkCollision(misspells, index, cleanLen, ignoreCollisions) {
+				misspells = append(misspells, Misspell{Index: index, Length: cleanLen, Category: CategorySpelling, Rule: RuleSpelling, Suggestions: suggested})
 			}
 		}
 	}
@@ -221,7 +369,7 @@ func SpellChecker(misspells []Misspell, data string) []Misspell {
 }
 
 // Mark emotoicons as misspelling errors
-func MarkEmojis(misspells []Misspell, text string) []Misspell {
+func MarkEmojis(misspells []Misspell, text string, ignoreCollisions bool) []Misspell {
 	// Find all matches and their positions
 	matches := emojiRe.FindAllStringIndex(text, -1)
 
@@ -233,94 +381,37 @@ func MarkEmojis(misspells []Misspell, text string) []Misspell {
 		print.Debug("Emoji: %q found at index: %d (Len: %d)\n", emoji, idx, ln)
 
 		// Check for collisions
-		if !checkCollision(misspells, idx, ln) {
-			misspells = append(misspells, Misspell{Index: idx, Length: ln, Category: "SPELLING_MISTAKE", Suggestions: []string{}})
+		if !checkCollision(misspells, idx, ln, ignoreCollisions) {
+			misspells = append(misspells, Misspell{Index: idx, Length: ln, Category: CategorySpelling, Rule: RuleEmoji, Suggestions: []string{}})
 		}
 	}
 	return misspells
 }
 
-func DirtySpellChecker(data string) ([]Misspell, error) {
-	// Reset Misspells to be empty
-	var misspells []Misspell
-
-	// Open and Read the "dirty-words.txt" file
-	file, err := os.Open(DirtyPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed opening 'dirty-words.txt' file: %w", err)
-	}
-	defer file.Close()
-
-	// Create a scanner to read the file
-	scanner := bufio.NewScanner(file)
-
-	// Read the "dirty-words.txt" file line by line
-	for scanner.Scan() {
-		content := scanner.Text()
-
-		// Create the regex pattern (case insensitive)
-		pattern := "(?i)\\b" + string(content) + "\\b"
-
-		re, err := regexp.Compile(pattern)
-		if err != nil {
-			return nil, fmt.Errorf("failed compiling regex pattern: %w", err)
-		}
-
-		// Find all occurrences of the word in the data string
-		matches := re.FindAllStringIndex(data, -1)
-		for _, match := range matches {
-			// M[0] is the start index, M[1] is the end index
-			ind := match[0]
-			ln := (match[1] - match[0])
-
-			// Check for collisions
-			if !checkCollision(misspells, ind, ln) {
-				misspells = append(misspells, Misspell{Index: ind, Length: ln, Category: "PROFANITY", Suggestions: nil})
+// Mark the words in the profanity lists, skipping allowlisted words.
+// The most severe match wins when matches collide
+func DirtySpellChecker(data string, lists []*ProfanityList, allow CustomWords, ignoreCollisions bool) []Misspell {
+	runes := []rune(data)
+	var hits []Misspell
+	for _, list := range lists {
+		list.findAll(data, runes, func(start, end int, severity string) {
+			if profanityAllowed(string(runes[start:end]), lists, allow) {
+				return
 			}
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed scanning file: %w", err)
+			hits = append(hits, Misspell{Index: start, Length: end - start, Category: CategoryProfanity, Rule: RuleProfanity, Severity: severity, Suggestions: nil})
+		})
 	}
 
-	// Check other bad words .txt file
-	profane_file, err := os.Open(ProfanePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed opening 'profane-words.txt' file: %w", err)
-	}
-	defer profane_file.Close()
-
-	scanner = bufio.NewScanner(profane_file)
-	for scanner.Scan() {
-		badWord := strings.TrimSpace(scanner.Text())
-		badWord = strings.ToLower(badWord)
-
-		// Create a regex pattern with word boundaries
-		pattern := fmt.Sprintf(`(?i)\b%s\b`, regexp.QuoteMeta(badWord))
-
-		re, err := regexp.Compile(pattern)
-		if err != nil {
-			return nil, fmt.Errorf("failed compiling profane regex pattern: %w", err)
-		}
-
-		// Find all occurrences of the word in the data string
-		matches := re.FindAllStringIndex(data, -1)
-		for _, match := range matches {
-			// M[0] is the start index, M[1] is the end index
-			ind := match[0]
-			ln := (match[1] - match[0])
+	sort.SliceStable(hits, func(i, j int) bool {
+		return severityRank[hits[i].Severity] > severityRank[hits[j].Severity]
+	})
 
-			// Check for collisions
-			if !checkCollision(misspells, ind, ln) {
-				misspells = append(misspells, Misspell{Index: ind, Length: ln, Category: "PROFANITY", Suggestions: nil})
-			}
+	var misspells []Misspell
+	for _, hit := range hits {
+		// Check for collisions
+		if !checkCollision(misspells, hit.Index, hit.Length, ignoreCollisions) {
+			misspells = append(misspells, hit)
 		}
 	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed scanning profane file: %w", err)
-	}
-
-	return misspells, nil
+	return misspells
 }
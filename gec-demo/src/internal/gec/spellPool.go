@@ -0,0 +1,80 @@
+// src/internal/gec/spellPool.go
+package gec
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gec-demo/src/internal/print"
+	hunspell "github.com/sthorne/go-hunspell"
+)
+
+// Hunspell handles can't be shared between goroutines, so each request checks one out of the pool.
+// Checkouts block once every handle is in use
+type spellPool struct {
+	idle    chan *hunspell.Hunhandle
+	handles []*hunspell.Hunhandle
+	addMu   sync.Mutex // Only one add() at a time, so two can't each hold half the handles
+}
+
+// Reads GEC_SPELL_POOL_SIZE from env. Defaults to 4
+func GetSpellPoolSize() int {
+	defaultSize := 4
+	s := strings.TrimSpace(os.Getenv("GEC_SPELL_POOL_SIZE"))
+	if s == "" {
+		return defaultSize
+	}
+	size, err := strconv.Atoi(s)
+	if err != nil || size < 1 {
+		print.Warning("Invalid GEC_SPELL_POOL_SIZE=%q, using %d", s, defaultSize)
+		return defaultSize
+	}
+	return size
+}
+
+// Load `size` Hunspell handles from the same affix & dictionary files
+func newSpellPool(size int, affPath, dictPath string) (*spellPool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("spell checker pool size must be at least 1, got %d", size)
+	}
+
+	pool := &spellPool{idle: make(chan *hunspell.Hunhandle, size)}
+	for range size {
+		huns := hunspell.Hunspell(affPath, dictPath)
+		pool.handles = append(pool.handles, huns)
+		pool.idle <- huns
+	}
+	return pool, nil
+}
+
+// Check out a handle, waiting for one to be returned if they are all in use
+func (p *spellPool) get() *hunspell.Hunhandle {
+	return <-p.idle
+}
+
+// Return a handle checked out with get()
+func (p *spellPool) put(huns *hunspell.Hunhandle) {
+	p.idle <- huns
+}
+
+// Add words to every handle in the pool. Waits for each handle to be returned so none is in use while it changes
+func (p *spellPool) add(words ...string) {
+	p.addMu.Lock()
+	defer p.addMu.Unlock()
+
+	held := make([]*hunspell.Hunhandle, 0, len(p.handles))
+	for range p.handles {
+		held = append(held, p.get())
+	}
+	for _, huns := range held {
+		for _, word := range words {
+			huns.Add(word)
+		}
+	}
+	for _, huns := range held {
+		p.put(huns)
+	}
+}
@@ -0,0 +1,116 @@
+package gec
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Check if `done` is closed within a short wait
+func closedSoon(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(50 * time.Millisecond):
+		return false
+	}
+}
+
+func TestSpellPool(t *testing.T) {
+	if spellers.Load() == nil {
+		t.Skip("spell checker not initialized")
+	}
+	if _, err := newSpellPool(0, embedPaths[affixFile], embedPaths[dictFile]); err == nil {
+		t.Errorf("newSpellPool() should fail for a size of 0")
+	}
+	pool, err := newSpellPool(2, embedPaths[affixFile], embedPaths[dictFile])
+	if err != nil {
+		t.Fatalf("newSpellPool() returned an error: %v", err)
+	}
+
+	// A third checkout waits for a handle to be returned
+	first, second := pool.get(), pool.get()
+	got := make(chan struct{})
+	go func() {
+		pool.put(pool.get())
+		close(got)
+	}()
+	if closedSoon(got) {
+		t.Fatalf("get() should block while every handle is checked out")
+	}
+
+	// Adding words waits for every handle to be returned
+	added := make(chan struct{})
+	go func() {
+		pool.add("Zorblatt")
+		close(added)
+	}()
+	pool.put(first)
+	if !closedSoon(got) {
+		t.Fatalf("get() should return once a handle is returned")
+	}
+	if closedSoon(added) {
+		t.Fatalf("add() should wait for every handle to be returned")
+	}
+	pool.put(second)
+	if !closedSoon(added) {
+		t.Fatalf("add() should finish once every handle is returned")
+	}
+}
+
+// Hammer the pipeline from many goroutines. Run with `-race`
+func TestMarkupGrammarConcurrent(t *testing.T) {
+	if Backend != "rules" {
+		t.Skipf("Requires the 'rules' backend (GEC_BACKEND=%q)", Backend)
+	}
+	texts := []string{
+		"we shood go home. i think so.",
+		"What the hell is teh point?",
+		"i recieve to many emails 😀 every day.",
+	}
+
+	// Results from one goroutine at a time
+	expected := make([]*GecResponse, len(texts))
+	for i, text := range texts {
+		result, err := MarkupGrammar(text, DefaultOptions())
+		if err != nil {
+			t.Fatalf("MarkupGrammar() returned an error: %v", err)
+		}
+		expected[i] = result
+	}
+
+	var wg sync.WaitGroup
+	for g := range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range 20 {
+				// Add custom words while the handles are in use
+				if g == 0 && n%5 == 0 {
+					words := []string{" Zorblatt "}
+					if err := addSpellingWords(DefaultLanguage, words); err != nil {
+						t.Errorf("addSpellingWords() returned an error: %v", err)
+						return
+					}
+					if words[0] != " Zorblatt " {
+						t.Errorf("addSpellingWords() changed the caller's words: %q", words)
+						return
+					}
+				}
+
+				i := (g + n) % len(texts)
+				result, err := MarkupGrammar(texts[i], DefaultOptions())
+				if err != nil {
+					t.Errorf("MarkupGrammar() returned an error: %v", err)
+					return
+				}
+				if result.CorrectedText != expected[i].CorrectedText || !reflect.DeepEqual(result.TextMarkups, expected[i].TextMarkups) {
+					t.Errorf("\nResult: %+v\nExpected: %+v", result, expected[i])
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
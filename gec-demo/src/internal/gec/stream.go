@@ -0,0 +1,234 @@
+// src/internal/gec/stream.go
+package gec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gec-demo/src/internal/print"
+)
+
+var StreamWindow = 8 // Number of sentences sent to the GEC channels in each work item while streaming
+
+// Run G.E.C. sentence by sentence, emitting a GecStreamSentence as each one finishes
+// followed by a final GecStreamSummary. Stops at the first error returned by `emit`.
+// Sentences the model merged, or a misspelling runs across, are emitted as one
+func MarkupGrammarStream(text string, opts CheckOptions, emit func(event any) error) error {
+	startTime := time.Now()
+	origText := text
+	text, norm := normalizeText(origText)
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("Text is empty without whitespace")
+	}
+	opts, detected := opts.detectLanguage(text)
+
+	// Find the protected text & spelling errors over the whole document
+	protected := findProtectedSpans(text, opts)
+	misspells, err := FindMisspells(text, opts, protected)
+	if err != nil {
+		return err
+	}
+
+	all_texts := PreprocessText(text, opts, protected)
+	if len(all_texts) <= 0 {
+		return fmt.Errorf("PreprocessText() returns an empty list")
+	}
+	spans := locateSentences(text, all_texts)
+	offsets := offsetTable(origText, opts.OffsetEncoding)
+
+	// Sentences are sent `StreamWindow` at a time in one work item, and the next window is sent
+	// before waiting on the current one so the model keeps working while results are emitted
+	windows := make([]WorkItem, (len(spans)+StreamWindow-1)/StreamWindow)
+	results := make([]SentenceResult, len(spans)) // Model's correction of each sentence, once its window is done
+	sent, done := 0, 0                            // Windows sent & sentences with results
+	var decoding *DecodingReport
+	sendWindow := func() error {
+		first, end := sent*StreamWindow, min((sent+1)*StreamWindow, len(spans))
+		texts := make([]string, 0, end-first)
+		textSpans := make([][]protectedSpan, 0, end-first)
+		for _, span := range spans[first:end] {
+			texts = append(texts, span.Text)
+			textSpans = append(textSpans, sliceSpans(text, protected, span.Start, span.End))
+		}
+		var err error
+		windows[sent], err = SendWorkItem(text[spans[first].Start:spans[end-1].End], texts, textSpans, opts.Language, opts.Decoding)
+		sent++
+		return err
+	}
+	// Wait until the model has corrected the first `n` sentences
+	waitSentences := func(n int) error {
+		for opts.Grammar && done < n {
+			w := done / StreamWindow
+			for sent < len(windows) && sent <= w+1 {
+				if err := sendWindow(); err != nil {
+					return err
+				}
+			}
+			gram_result, err := WaitWorkItem(windows[w])
+			if err != nil {
+				return err
+			}
+
+			// Without one correction per sentence, the window is diffed as a whole
+			first, end := done, min(done+StreamWindow, len(spans))
+			if len(gram_result.Sentences) != end-first {
+				print.Warning("Model corrected %d sentences, expected %d. Aligning the corrected text instead", len(gram_result.Sentences), end-first)
+				gram_result.Sentences = make([]SentenceResult, end-first)
+				gram_result.Sentences[0].Text = gram_result.CorrectText
+				for k := 1; k < len(gram_result.Sentences); k++ {
+					gram_result.Sentences[k].Merged = true
+				}
+			}
+			copy(results[first:end], gram_result.Sentences)
+			if narrowed := narrowedDecoding(opts.Decoding, gram_result); narrowed != nil {
+				decoding = narrowed
+			}
+			done = end
+		}
+		return nil
+	}
+
+	var corrected strings.Builder
+	last := 0 // Rune offset of the end of the last sentence
+	err_chars := 0
+	profane := false
+	var allMarkups []Markup // Kept for the profanity report
+	for i := 0; i < len(spans); {
+		if err := waitSentences(i + 1); err != nil {
+			return fmt.Errorf("error running GEC, %v. Input Text: %q", err, spans[i].Orig)
+		}
+
+		// Sentences the model corrected together, or a misspelling runs across, are emitted as one
+		j := i + 1
+		for ; j < len(spans) && (results[j].Merged || crossesSentence(misspells, spans[j])); j++ {
+			if err := waitSentences(j + 1); err != nil {
+				return fmt.Errorf("error running GEC, %v. Input Text: %q", err, spans[j].Orig)
+			}
+		}
+		span := textSpan{Orig: text[spans[i].Start:spans[j-1].End], Start: spans[i].Start, End: spans[j-1].End, Index: spans[i].Index}
+		span.Length = utf8.RuneCountInString(span.Orig)
+
+		// Put the corrections back together with the original text between the sentences
+		var correctedSent string
+		var sentResults []SentenceResult
+		if opts.Grammar {
+			var b strings.Builder
+			for k := i; k < j; k++ {
+				if results[k].Merged {
+					continue
+				}
+				if k > i {
+					b.WriteString(text[spans[k-1].End:spans[k].Start])
+				}
+				if sentence := strings.TrimSpace(results[k].Text); sentence != "" {
+					b.WriteString(sentence)
+				} else {
+					b.WriteString(spans[k].Orig)
+				}
+			}
+			correctedSent = b.String()
+			sentResults = results[i:j]
+		} else {
+			correctedSent = span.Orig
+		}
+
+		// Diff the sentences on their own, then shift the markups to document offsets
+		owned, stray := ownedMisspells(misspells, spans, i, j)
+		text_markups, chars, profanity_words, err := markupText(span.Orig, correctedSent, sentResults, owned, opts, sliceSpans(text, protected, span.Start, span.End))
+		if err != nil {
+			return err
+		}
+		for k := range text_markups {
+			text_markups[k].Index += span.Index
+		}
+
+		// Misspellings between the sentences are already at document offsets
+		if len(stray) > 0 {
+			stray_markups, stray_chars, stray_words, err := markupText(text, text, nil, stray, opts, nil)
+			if err != nil {
+				return err
+			}
+			text_markups = append(text_markups, stray_markups...)
+			chars += stray_chars
+			profanity_words = append(profanity_words, stray_words...)
+			sort.Slice(text_markups, func(a, b int) bool { return text_markups[a].Index < text_markups[b].Index })
+		}
+
+		// Map everything back onto the caller's original text
+		text_markups = assignMarkupIDs(origText, norm.mapMarkups(text_markups))
+		for k := range text_markups {
+			text_markups[k].Index, text_markups[k].Length = convertOffset(offsets, text_markups[k].Index, text_markups[k].Length)
+		}
+		sentIndex, sentLength := norm.mapSpan(span.Index, span.Length)
+		sentIndex, sentLength = convertOffset(offsets, sentIndex, sentLength)
+		correctedSent = norm.restore(span.Index, span.Index+span.Length, correctedSent)
+		err_chars += chars
+		profane = profane || len(profanity_words) > 0
+		allMarkups = append(allMarkups, text_markups...)
+
+		// Keep the original whitespace between sentences
+		corrected.WriteString(norm.origSpan(last, span.Index))
+		corrected.WriteString(correctedSent)
+		last = span.Index + span.Length
+
+		err = emit(GecStreamSentence{
+			Event:             "sentence",
+			SentenceIndex:     i,
+			Index:             sentIndex,
+			Length:            sentLength,
+			CorrectedSentence: correctedSent,
+			TextMarkups:       text_markups,
+		})
+		if err != nil {
+			return err
+		}
+		i = j
+	}
+	corrected.WriteString(norm.origSpan(last, len(norm.norm)))
+
+	return emit(GecStreamSummary{
+		Event:               "summary",
+		CorrectedText:       corrected.String(),
+		CharacterCount:      len(origText),
+		ErrorCharacterCount: err_chars,
+		ContainsProfanity:   profane,
+		Profanity:           profanityReport(allMarkups),
+		Language:            opts.Language.code(),
+		DetectedLanguage:    detected.code,
+		LanguageConfidence:  detected.confidence,
+		SkippedChecks:       detected.skipped,
+		Decoding:            decoding,
+		ServiceTime:         time.Since(startTime).Seconds(),
+	})
+}
+
+// Check if a misspelling starts before the sentence and runs into it
+func crossesSentence(misspells []Misspell, span textSpan) bool {
+	for _, miss := range misspells {
+		if miss.Index < span.Index && miss.Index+miss.Length > span.Index {
+			return true
+		}
+	}
+	return false
+}
+
+// Split the misspellings emitted with spans[i:j] into those inside them, relative to their start, and those
+// between them and the next sentences at document offsets. The first sentences also take any before them
+func ownedMisspells(misspells []Misspell, spans []textSpan, i, j int) (owned, stray []Misspell) {
+	span := textSpan{Index: spans[i].Index, Length: spans[j-1].Index + spans[j-1].Length - spans[i].Index}
+	for _, miss := range misspells {
+		if (i > 0 && miss.Index < span.Index) || (j < len(spans) && miss.Index >= spans[j].Index) {
+			continue
+		}
+		if miss.Index >= span.Index && miss.Index+miss.Length <= span.Index+span.Length {
+			miss.Index -= span.Index
+			owned = append(owned, miss)
+		} else {
+			stray = append(stray, miss)
+		}
+	}
+	return owned, stray
+}
@@ -4,6 +4,7 @@ package gec
 // ********* SERVER ENDPOINT *********
 type GecRequest struct {
 	Text string `json:"text"`
+	GecOptions
 }
 
 type GecResponse struct {
@@ -11,35 +12,224 @@ type GecResponse struct {
 	TextMarkups         []Markup      `json:"text_markups"`
 	CharacterCount      int           `json:"character_count"`
 	ErrorCharacterCount int           `json:"error_character_count"`
-	ContainsProfanity   bool          `json:"contains_profanity"`
-	ServiceTime         float64       `json:"service_time"`
+	ContainsProfanity   bool             `json:"contains_profanity"`
+	Profanity           *ProfanityReport `json:"profanity"`
+	Language            string           `json:"language"`
+	DetectedLanguage    string           `json:"detected_language,omitempty"`   // Most likely language of the text, empty when it is too short to tell
+	LanguageConfidence  float64          `json:"language_confidence,omitempty"` // Probability the detected language is right
+	SkippedChecks       []string         `json:"skipped_checks,omitempty"`      // Categories not checked as the text is in an unsupported language
+	Decoding            *DecodingReport  `json:"decoding,omitempty"`            // Set when the model had to narrow the requested beam_width or num_best
+	ServiceTime         float64          `json:"service_time"`
+}
+
+// Beam search the model fell back to when the requested beams didn't fit in its batch
+type DecodingReport struct {
+	BeamWidth int `json:"beam_width"`
+	NumBest   int `json:"num_best"`
+}
+
+// Profanity found in a response, by severity
+type ProfanityReport struct {
+	MaxSeverity string         `json:"max_severity,omitempty"` // Most severe profanity found, empty if none
+	Count       int            `json:"count"`
+	Counts      map[string]int `json:"counts"` // Number of markups per severity
+}
+
+type GecBatchRequest struct {
+	Documents []GecBatchDocument `json:"documents"`
+	GecOptions
+}
+
+type GecBatchDocument struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+type GecBatchResponse struct {
+	Results     []GecBatchResult `json:"results"`
+	ServiceTime float64          `json:"service_time"`
+}
+
+// Result for one document in a batch. Holds either the response fields or an error
+type GecBatchResult struct {
+	ID string `json:"id"`
+	*GecResponse
+	Error string `json:"error,omitempty"`
+}
+
+type ApplyRequest struct {
+	Text           string   `json:"text"`
+	TextMarkups    []Markup `json:"text_markups"`
+	AcceptedIDs    []string `json:"accepted_ids"`
+	OffsetEncoding string   `json:"offset_encoding,omitempty"` // Unit the markup offsets are in: runes, bytes or utf16
+}
+
+type ApplyResponse struct {
+	Text        string   `json:"text"`
+	TextMarkups []Markup `json:"text_markups"` // Markups that were not applied, with offsets into the new text
+}
+
+type DictionaryRequest struct {
+	Name  string   `json:"name,omitempty"` // Only used when creating a dictionary
+	Words []string `json:"words"`
+}
+
+type DictionaryResponse struct {
+	Name  string   `json:"name"`
+	Words []string `json:"words"`
+}
+
+type DictionaryListResponse struct {
+	Dictionaries []string `json:"dictionaries"`
+}
+
+type ReloadResponse struct {
+	Status      string  `json:"status"` // Always "reloaded"
+	ServiceTime float64 `json:"service_time"`
+}
+
+// Result of checking an uploaded .docx or .odt file, by paragraph
+type OfficeDocumentResponse struct {
+	Format              string            `json:"format"`     // docx or odt
+	Paragraphs          []OfficeParagraph `json:"paragraphs"` // Paragraphs with text, in document order
+	CharacterCount      int               `json:"character_count"`
+	ErrorCharacterCount int               `json:"error_character_count"`
+	ContainsProfanity   bool              `json:"contains_profanity"`
+	ServiceTime         float64           `json:"service_time"`
+}
+
+// A checked paragraph. Markup & run offsets are into the paragraph's text
+type OfficeParagraph struct {
+	Index         int            `json:"index"` // Position among all the paragraphs of the document, counting empty ones
+	Text          string         `json:"text"`
+	CorrectedText string         `json:"corrected_text"`
+	Runs          []OfficeRun    `json:"runs"` // Runs of the paragraph in order, w:r elements in .docx and spans in .odt
+	TextMarkups   []OfficeMarkup `json:"text_markups"`
+	Language      string         `json:"language,omitempty"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// A run of text with the same formatting
+type OfficeRun struct {
+	Index  int `json:"index"`
+	Length int `json:"length"`
+}
+
+// A markup with the run it starts in
+type OfficeMarkup struct {
+	Markup
+	Run       int `json:"run"`
+	RunOffset int `json:"run_offset"` // Offset of the markup from the start of the run
+}
+
+// Streamed per sentence on /api/gec. Markup indexes are relative to the whole document
+type GecStreamSentence struct {
+	Event             string   `json:"event"` // Always "sentence"
+	SentenceIndex     int      `json:"sentence_index"`
+	Index             int      `json:"index"`
+	Length            int      `json:"length"`
+	CorrectedSentence string   `json:"corrected_sentence"`
+	TextMarkups       []Markup `json:"text_markups"`
+}
+
+// Final record of a streamed response
+type GecStreamSummary struct {
+	Event               string           `json:"event"` // Always "summary"
+	CorrectedText       string           `json:"corrected_text"`
+	CharacterCount      int              `json:"character_count"`
+	ErrorCharacterCount int              `json:"error_character_count"`
+	ContainsProfanity   bool             `json:"contains_profanity"`
+	Profanity           *ProfanityReport `json:"profanity"`
+	Language            string           `json:"language"`
+	DetectedLanguage    string           `json:"detected_language,omitempty"`
+	LanguageConfidence  float64          `json:"language_confidence,omitempty"`
+	SkippedChecks       []string         `json:"skipped_checks,omitempty"`
+	Decoding            *DecodingReport  `json:"decoding,omitempty"`
+	ServiceTime         float64          `json:"service_time"`
+}
+
+// Sent in place of the summary when a stream fails part way through
+type GecStreamError struct {
+	Event string `json:"event"` // Always "error"
+	Error string `json:"error"`
 }
 
 // ********* GEC *********
 type Markup struct {
-	Index    int    `json:"index"`
-	Length   int    `json:"length"`
-	Message  string `json:"message"`
-	Category string `json:"category"`
+	ID           string   `json:"id"`
+	Index        int      `json:"index"`
+	Length       int      `json:"length"`
+	Message      string   `json:"message"`
+	Category     string   `json:"category"`
+	Rule         string   `json:"rule"`                 // Machine-readable code for the kind of fix
+	Severity     string   `json:"severity,omitempty"`   // Profanity severity: mild, strong or slur
+	Replacements []string `json:"replacements"`         // Suggested text to replace the marked text with
+	Confidence   *float64 `json:"confidence,omitempty"` // Model's confidence in a grammar suggestion, from 0 to 1
+	Hint         bool     `json:"hint,omitempty"`       // Less confident than the request's min_confidence, shown as a soft hint
+
+	insert bool // Marks an insertion before the character at Index (Length is padded to 1)
 }
 
 type Misspell struct {
 	Index       int      // The index of the misspelled word in the text
 	Length      int      // The length of the misspelled word
 	Category    string   // The type of error
+	Rule        string   // The specific rule that flagged it
+	Severity    string   // Profanity severity
 	Suggestions []string // Suggested word replacements
 }
 
+// A sentence's position in the cleaned text
+type textSpan struct {
+	Text   string // Text sent to the model
+	Orig   string // Original text covered by the span
+	Start  int    // Byte offsets
+	End    int
+	Index  int // Rune offset & length
+	Length int
+}
+
 type GrammarResult struct {
 	CorrectText string
+	Sentences   []SentenceResult // Correction of each text sent to the model, in order
 	GpuId       int
+	Backend     string        // Name of the Corrector backend that produced the result
+	Decoding    DecodeOptions // Options the texts were decoded with. Beams may be narrower than requested
 	Err         error
 	ServiceTime float64
 }
 
+// Corrected text of one of the sentences or newline literals sent to the model
+type SentenceResult struct {
+	Text         string
+	Merged       bool         // Corrected together with the sentence before it, whose Text holds the correction of both
+	Group        int          // Sequence the model corrected it in, -1 for newline literals & texts left uncorrected
+	InputTokens  int          // Tokens the sentence was encoded into, 0 for backends without a tokenizer
+	OutputTokens int          // Tokens generated for its sequence, only set on the first sentence of the sequence
+	Tokens       []TokenScore // Generated tokens of Text, nil when the backend doesn't score them
+	Alternatives []string     // Less likely corrections of Text from n-best decoding, best first
+}
+
+// A token the model generated, and how likely it was
+type TokenScore struct {
+	Start, End int // Byte offsets of the token in the sentence's corrected text
+	LogProb    float64
+}
+
+// How the model decodes a work item's texts
+type DecodeOptions struct {
+	BeamWidth     int     // Beams kept per sequence, 1 decodes greedily
+	NumBest       int     // Corrections returned per sequence, best first. At most BeamWidth
+	LengthPenalty float64 // Finished beams are scored by their log-probability / length^LengthPenalty
+	NoRepeatNgram int     // Size of the n-grams a sequence can't generate twice, 0 only stops ABABAB loops
+}
+
 type WorkItem struct {
 	Count    int
 	Text     string
 	AllTexts []string
+	Decoding DecodeOptions
 	Ch       chan GrammarResult
+
+	mask *textMask // Protected text swapped out of AllTexts, put back in the result
 }
@@ -4,7 +4,6 @@ package gec
 import (
 	"fmt"
 	"gec-demo/src/internal/print"
-	"gec-demo/src/internal/speechtagger"
 	"sort"
 	"strings"
 	"unicode"
@@ -36,6 +35,12 @@ func FormatToJson(text string, Differences []Markup, Misspells []Misspell) (mark
 			continue
 		}
 
+		// Insertions mark the next character, so keep it in the replacement
+		if diff.insert {
+			diff.Replacements = []string{diff.Replacements[0] + runeSubstring(text, diff.Index, 1)}
+			diff.insert = false
+		}
+
 		markups = append(markups, diff)
 	}
 
@@ -61,19 +66,24 @@ func FormatToJson(text string, Differences []Markup, Misspells []Misspell) (mark
 
 		if miss.Category == "SPELLING_MISTAKE" {
 			typo := Markup{
-				Index:    miss.Index,
-				Length:   miss.Length,
-				Message:  "Possible spelling mistake found.",
-				Category: miss.Category,
+				Index:        miss.Index,
+				Length:       miss.Length,
+				Message:      "Possible spelling mistake found.",
+				Category:     miss.Category,
+				Rule:         miss.Rule,
+				Replacements: limitReplacements(miss.Suggestions),
 			}
 			markups = append(markups, typo)
 		}
 		if miss.Category == "PROFANITY" {
 			dirtyMark := Markup{
-				Index:    miss.Index,
-				Length:   miss.Length,
-				Message:  "This word is considered offensive",
-				Category: miss.Category,
+				Index:        miss.Index,
+				Length:       miss.Length,
+				Message:      "This word is considered offensive",
+				Category:     miss.Category,
+				Rule:         miss.Rule,
+				Severity:     miss.Severity,
+				Replacements: []string{},
 			}
 			markups = append(markups, dirtyMark)
 
@@ -100,9 +110,64 @@ func FormatToJson(text string, Differences []Markup, Misspells []Misspell) (mark
 	return markups, err_chars, profanity_words, err
 }
 
-// Split the text into sentences and newline literals with surrounding whitespace
-func PreprocessText(text string) (allTexts []string) {
-	text = CleanText(text)
+// Returns at most `MaxReplacements` suggestions, never nil
+func limitReplacements(suggestions []string) []string {
+	if len(suggestions) > MaxReplacements {
+		suggestions = suggestions[:MaxReplacements]
+	}
+	return append([]string{}, suggestions...)
+}
+
+// Returns the substring of `length` runes starting at rune index `startInd`, clamped to the string
+func runeSubstring(str string, startInd int, length int) string {
+	runes := []rune(str)
+	if startInd < 0 || startInd >= len(runes) {
+		return ""
+	}
+	end := min(startInd+length, len(runes))
+	return string(runes[startInd:end])
+}
+
+// Split the text into sentences of the language and newline literals with surrounding whitespace,
+// ready to send to the model. `protected` holds the protected spans of the text
+func PreprocessText(text string, opts CheckOptions, protected []protectedSpan) (allTexts []string) {
+	clean := CleanText(text)
+	if clean != text {
+		protected = findProtectedSpans(clean, opts)
+	}
+	allTexts = splitSentences(clean, opts.Language, protected)
+
+	// Modify text starting with a T5 prefix
+	for i := range allTexts {
+		if strings.HasPrefix(allTexts[i], "summarize") {
+			allTexts[i] = strings.Replace(allTexts[i], "summarize", "Summarize", 1)
+		}
+
+		// Convert everything after "Translate" to lowercase, if the string matches this regex prefix
+		allTexts[i] = rePrefix.ReplaceAllStringFunc(allTexts[i], func(match string) string {
+			// Extract the matched part after "Translate"
+			parts := rePrefix.FindStringSubmatch(match)
+			if len(parts) == 2 {
+				return fmt.Sprintf("Translate english to %s", strings.ToLower(parts[1]))
+			}
+			return match
+		})
+	}
+
+	return allTexts
+}
+
+// Split the text into sentences of the language and newline literals. Sentences are trimmed, newline literals
+// keep the whitespace after the newline. Protected text is masked while splitting, so periods in URLs, paths
+// & numbers never end a sentence
+func splitSentences(text string, lang *Language, protected []protectedSpan) (allTexts []string) {
+	masked, mask := maskTexts([]string{text}, [][]protectedSpan{protected})
+	text = masked[0]
+	defer func() {
+		for i := range allTexts {
+			allTexts[i], _ = mask.unmask(allTexts[i])
+		}
+	}()
 	inds := rePreproc.FindAllStringIndex(text, -1)
 
 	lastIndex := 0
@@ -110,7 +175,7 @@ func PreprocessText(text string) (allTexts []string) {
 		start, end := ind[0], ind[1]
 		if lastIndex < start {
 			// Split text into sentences and append
-			sents := speechtagger.SplitBySentences(text[lastIndex:start])
+			sents := lang.splitBySentences(text[lastIndex:start])
 			for _, s := range sents {
 				if s != "" {
 					allTexts = append(allTexts, s)
@@ -129,7 +194,7 @@ func PreprocessText(text string) (allTexts []string) {
 	}
 	if lastIndex < len(text) {
 		// Split text into sentences and append
-		sents := speechtagger.SplitBySentences(text[lastIndex:])
+		sents := lang.splitBySentences(text[lastIndex:])
 
 		for _, s := range sents {
 			if s != "" {
@@ -137,60 +202,26 @@ func PreprocessText(text string) (allTexts []string) {
 			}
 		}
 	}
-
-	// Modify text starting with a T5 prefix
-	for i := range allTexts {
-		if strings.HasPrefix(allTexts[i], "summarize") {
-			allTexts[i] = strings.Replace(allTexts[i], "summarize", "Summarize", 1)
-		}
-
-		// Convert everything after "Translate" to lowercase, if the string matches this regex prefix
-		allTexts[i] = rePrefix.ReplaceAllStringFunc(allTexts[i], func(match string) string {
-			// Extract the matched part after "Translate"
-			parts := rePrefix.FindStringSubmatch(match)
-			if len(parts) == 2 {
-				return fmt.Sprintf("Translate english to %s", strings.ToLower(parts[1]))
-			}
-			return match
-		})
-	}
-
 	return allTexts
 }
 
-// Clean the Text of weird characters
+// Clean the Text of weird characters (see normalizeText())
 func CleanText(text string) string {
-	// Strip out any control characters that are not printable
-	ru := []rune(text)
-	cleanData := strings.Map(func(r rune) rune {
-		if unicode.IsControl(r) && !unicode.IsPrint(r) && !unicode.IsSpace(r) {
-			print.Debug("Control Character being dropped: %v", r)
-			return -1
-		}
-		if r == 160 {
-			return 32
-		}
-		return r
-	}, string(ru))
-
-	// Filter special quote characters (“, ”, ’, ‘)
-	cleanData = strings.ReplaceAll(cleanData, "“", "\"")
-	cleanData = strings.ReplaceAll(cleanData, "”", "\"")
-	cleanData = strings.ReplaceAll(cleanData, "‘", "'")
-	cleanData = strings.ReplaceAll(cleanData, "’", "'")
-	return cleanData
+	clean, _ := normalizeText(text)
+	return clean
 }
 
 // Returns the substring of a string given the starting index and length of the substring
 func GetSubstring(str string, startInd int, length int) (string, error) {
-	if startInd == len(str) && length == 1 {
+	runes := []rune(str)
+	if startInd == len(runes) && length == 1 {
 		// Wants to markup end of string
 		return "", nil
 	}
-	if startInd < 0 || length <= 0 || startInd+length > len(str) {
-		return "", fmt.Errorf("invalid start index(%v) or length(%v). Input string length=%v", startInd, length, len(str))
+	if startInd < 0 || length <= 0 || startInd+length > len(runes) {
+		return "", fmt.Errorf("invalid start index(%v) or length(%v). Input string length=%v", startInd, length, len(runes))
 	}
-	return str[startInd : startInd+length], nil
+	return string(runes[startInd : startInd+length]), nil
 }
 
 // Get leading & trailing whitespace around a string
@@ -247,3 +278,42 @@ func RuneIndex(s, substr string) int {
 	}
 	return -1
 }
+
+// Locate the sentences from PreprocessText() in the cleaned text. Newline literals are skipped
+func locateSentences(text string, allTexts []string) []textSpan {
+	var spans []textSpan
+	cursor := 0
+	for _, t := range allTexts {
+		if strings.Contains(t, "\n") || t == "" {
+			continue
+		}
+
+		start := strings.Index(text[cursor:], t)
+		if start == -1 {
+			// T5 prefixes are re-cased by PreprocessText(), so fallback to a case-insensitive search
+			for i := cursor; i+len(t) <= len(text); i++ {
+				if strings.EqualFold(text[i:i+len(t)], t) {
+					start = i - cursor
+					break
+				}
+			}
+		}
+		if start == -1 {
+			print.Warning("Failed locating sentence %q in the text", t)
+			continue
+		}
+
+		start += cursor
+		end := start + len(t)
+		spans = append(spans, textSpan{
+			Text:   t,
+			Orig:   text[start:end],
+			Start:  start,
+			End:    end,
+			Index:  utf8.RuneCountInString(text[:start]),
+			Length: utf8.RuneCountInString(text[start:end]),
+		})
+		cursor = end
+	}
+	return spans
+}
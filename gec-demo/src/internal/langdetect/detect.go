@@ -0,0 +1,186 @@
+// src/internal/langdetect/detect.go
+// Offline language identification from character n-gram profiles
+package langdetect
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Built from corpus/*.txt by `make langprofiles`
+//
+//go:embed data/profiles.gob
+var profileData []byte
+
+const (
+	unknownGram = ""  // Key for the log probability of n-grams missing from a profile
+	maxGramLen  = 3   // Longest n-gram in runes
+	wordPad     = '_' // Marks the start & end of a word, so n-grams can tell prefixes and suffixes apart
+)
+
+var (
+	MinLetters = 12   // Texts with fewer letters are too short to detect
+	MaxRunes   = 2000 // Only the start of longer texts is read
+
+	profiles map[string]map[string]float64 // Language code -> n-gram -> log probability
+)
+
+// Decode the embedded n-gram profiles
+func InitProfiles() error {
+	var p map[string]map[string]float64
+	if err := gob.NewDecoder(bytes.NewReader(profileData)).Decode(&p); err != nil {
+		return fmt.Errorf("failed decoding language profiles: %w", err)
+	}
+	profiles = p
+	return nil
+}
+
+// Codes of the languages that can be detected
+func Languages() []string {
+	codes := make([]string, 0, len(profiles))
+	for code := range profiles {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Call `found` with every n-gram of 1 to 3 runes in the lowercased words of the text
+func NGrams(text string, found func(gram string)) {
+	word := []rune{wordPad}
+	flush := func() {
+		if len(word) == 1 {
+			return
+		}
+		word = append(word, wordPad)
+		for n := 1; n <= maxGramLen; n++ {
+			for i := 0; i+n <= len(word); i++ {
+				if n == 1 && word[i] == wordPad {
+					continue
+				}
+				found(string(word[i : i+n]))
+			}
+		}
+		word = word[:1]
+	}
+
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			word = append(word, unicode.ToLower(r))
+			continue
+		}
+		flush()
+	}
+	flush()
+}
+
+// Build the profile of a training text: the log probability of its `size` most common n-grams,
+// with add-one smoothing so unseen n-grams get a small probability instead of zero
+func BuildProfile(text string, size int) map[string]float64 {
+	counts := make(map[string]int)
+	NGrams(text, func(gram string) { counts[gram]++ })
+
+	grams := make([]string, 0, len(counts))
+	for gram := range counts {
+		grams = append(grams, gram)
+	}
+	sort.Slice(grams, func(i, j int) bool {
+		if counts[grams[i]] != counts[grams[j]] {
+			return counts[grams[i]] > counts[grams[j]]
+		}
+		return grams[i] < grams[j]
+	})
+	if len(grams) > size {
+		grams = grams[:size]
+	}
+
+	total := 0
+	for _, gram := range grams {
+		total += counts[gram]
+	}
+	denom := float64(total + len(grams) + 1)
+
+	profile := make(map[string]float64, len(grams)+1)
+	for _, gram := range grams {
+		profile[gram] = math.Log(float64(counts[gram]+1) / denom)
+	}
+	profile[unknownGram] = math.Log(1 / denom)
+	return profile
+}
+
+// Find the most likely language of the text and the probability that it is right.
+// Returns an empty code when the text is too short or the profiles are not loaded
+func Detect(text string) (code string, confidence float64) {
+	probs := Probabilities(text)
+	for lang, p := range probs {
+		if p > confidence || (p == confidence && lang < code) {
+			code, confidence = lang, p
+		}
+	}
+	return code, confidence
+}
+
+// Probability of the text being in each language, summing to 1.
+// Returns nil when the text is too short or the profiles are not loaded
+func Probabilities(text string) map[string]float64 {
+	if len(profiles) == 0 {
+		return nil
+	}
+	if runes := []rune(text); len(runes) > MaxRunes {
+		text = string(runes[:MaxRunes])
+	}
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters < MinLetters {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	total := 0
+	NGrams(strings.TrimSpace(text), func(gram string) {
+		counts[gram]++
+		total++
+	})
+
+	// Naive Bayes log likelihood of the text under each profile
+	scores := make(map[string]float64, len(profiles))
+	best := math.Inf(-1)
+	for lang, profile := range profiles {
+		score := 0.0
+		for gram, n := range counts {
+			logp, ok := profile[gram]
+			if !ok {
+				logp = profile[unknownGram]
+			}
+			score += float64(n) * logp
+		}
+
+		// Summing the log likelihood of every n-gram treats them as independent and reports ~100%
+		// for any sentence, so it is divided by the square root of the n-gram count before the softmax.
+		// Confidence still grows with the length of the text, just more slowly
+		score /= math.Sqrt(float64(total))
+		scores[lang] = score
+		best = max(best, score)
+	}
+
+	// Softmax over the languages, shifted by the best score so it can't overflow
+	sum := 0.0
+	for lang, score := range scores {
+		scores[lang] = math.Exp(score - best)
+		sum += scores[lang]
+	}
+	for lang := range scores {
+		scores[lang] /= sum
+	}
+	return scores
+}
@@ -0,0 +1,60 @@
+package langdetect
+
+import (
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	if err := InitProfiles(); err != nil {
+		t.Fatalf("InitProfiles() returned an error: %v", err)
+	}
+
+	// None of these sentences are in the training texts
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{text: "I think this is a really good idea, thanks for sharing it with me.", expected: "en"},
+		{text: "we shood buy an car.", expected: "en"},
+		{text: "Hola, me llamo Carlos y vivo en Madrid con mi familia desde hace diez años.", expected: "es"},
+		{text: "Ich habe keine Zeit, weil ich morgen arbeiten muss.", expected: "de"},
+		{text: "Je ne sais pas pourquoi il est parti si tôt hier soir.", expected: "fr"},
+		{text: "Non so perché sia partito così presto ieri sera.", expected: "it"},
+		{text: "Eu não sei porque ele saiu tão cedo ontem à noite.", expected: "pt"},
+		{text: "Ik weet niet waarom hij gisteravond zo vroeg vertrok.", expected: "nl"},
+		{text: "Can you send me the report before lunch tomorrow?", expected: "en"},
+		{text: "¿Dónde está la estación de tren más cercana?", expected: "es"},
+		{text: "Kannst du mir bitte sagen, wann der Laden aufmacht?", expected: "de"},
+		{text: "On se voit demain devant le cinéma vers huit heures ?", expected: "fr"},
+		{text: "Mi puoi dire a che ora apre il negozio domani?", expected: "it"},
+		{text: "Podes enviar-me o relatório antes do almoço?", expected: "pt"},
+		{text: "Zullen we morgen samen boodschappen doen na het werk?", expected: "nl"},
+		{text: "Hello world", expected: ""}, // Too short to tell
+		{text: "12345 !!! :)", expected: ""},
+	}
+
+	for _, tt := range tests {
+		code, confidence := Detect(tt.text)
+		if code != tt.expected {
+			t.Errorf("%q\nResult: %q (%.3f)\nExpected: %q", tt.text, code, confidence, tt.expected)
+		}
+		if code != "" && (confidence <= 0.5 || confidence > 1) {
+			t.Errorf("%q: Confidence = %.3f, expected it to be more likely than every other language", tt.text, confidence)
+		}
+	}
+}
+
+func TestNGrams(t *testing.T) {
+	var grams []string
+	NGrams("Ab, c", func(gram string) { grams = append(grams, gram) })
+
+	expected := []string{"a", "b", "_a", "ab", "b_", "_ab", "ab_", "c", "_c", "c_", "_c_"}
+	if len(grams) != len(expected) {
+		t.Fatalf("\nResult: %q\nExpected: %q", grams, expected)
+	}
+	for i := range grams {
+		if grams[i] != expected[i] {
+			t.Fatalf("\nResult: %q\nExpected: %q", grams, expected)
+		}
+	}
+}
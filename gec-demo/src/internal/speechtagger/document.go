@@ -21,12 +21,23 @@ var (
 )
 
 // Initialize the part-of-speech tagging model
+// The English sentence tokenizer is registered first, so sentences can be split even without the tagger's weights
 func InitTaggingModel() error {
-	var wts map[string]map[string]float64
-	var tags map[string]string
-	var err error
+	// Load the Sentence Tokenizer
+	b, err := data.Asset("data/english.json")
+	if err != nil {
+		return fmt.Errorf("failed loading english data for sentence tokenizer: %w", err)
+	}
+	// Register English, and keep its tokenizer as the default
+	english, err := RegisterLanguage("en", b, nil)
+	if err != nil {
+		return err
+	}
+	SentTokenizer = english.tokenizer
 
 	// Decode the gob files
+	var wts map[string]map[string]float64
+	var tags map[string]string
 	err = decodeGob("data/tags.gob", &tags)
 	if err != nil {
 		return err
@@ -46,19 +57,7 @@ func InitTaggingModel() error {
 		Name:   "en-v2.0.0",
 		tagger: &perceptronTagger{model: &percepMod},
 	}
-
-	// Load the Sentence Tokenizer
-	b, err := data.Asset("data/english.json")
-	if err != nil {
-		return fmt.Errorf("failed loading english data for sentence tokenizer: %w", err)
-	}
-	// Load the training data
-	training, err := sentences.LoadTraining(b)
-	if err != nil {
-		return fmt.Errorf("failed loading training data for sentence tokenizer: %w", err)
-	}
-	// Create the sentence tokenizer
-	SentTokenizer = sentences.NewSentenceTokenizer(training)
+	english.Model = TaggerModel
 	return nil
 }
 
@@ -73,7 +72,7 @@ func TagSpeech(text string) []*Token {
 	return tokens
 }
 
-// Split text into sentences
+// Split English text into sentences
 func SplitBySentences(text string) (allTexts []string) {
 	sentences := SentTokenizer.Tokenize(text)
 	sents := make([]string, len(sentences))
@@ -0,0 +1,78 @@
+// src/internal/speechtagger/language.go
+package speechtagger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/neurosnap/sentences.v1"
+)
+
+// Sentence tokenizer & part-of-speech tagger for one language
+type Language struct {
+	Code      string // ISO 639-1 code, e.g. "en"
+	Model     *Model // Part-of-speech tagger. nil for languages without one
+	tokenizer *sentences.DefaultSentenceTokenizer
+}
+
+var (
+	languagesMu sync.RWMutex
+	languages   = map[string]*Language{}
+)
+
+// Register a language's sentence tokenizer from Punkt training data in the neurosnap/sentences JSON format.
+// Without training data the tokenizer still splits on sentence punctuation, but knows no abbreviations
+func RegisterLanguage(code string, training []byte, model *Model) (*Language, error) {
+	storage := sentences.NewStorage()
+	if len(training) > 0 {
+		var err error
+		storage, err = sentences.LoadTraining(training)
+		if err != nil {
+			return nil, fmt.Errorf("failed loading training data for %q sentence tokenizer: %w", code, err)
+		}
+	}
+
+	lang := &Language{
+		Code:      code,
+		Model:     model,
+		tokenizer: sentences.NewSentenceTokenizer(storage),
+	}
+
+	languagesMu.Lock()
+	defer languagesMu.Unlock()
+	languages[code] = lang
+	return lang, nil
+}
+
+// Get a registered language by its code
+func GetLanguage(code string) (*Language, bool) {
+	languagesMu.RLock()
+	defer languagesMu.RUnlock()
+	lang, ok := languages[code]
+	return lang, ok
+}
+
+// Codes of all registered languages
+func LanguageCodes() []string {
+	languagesMu.RLock()
+	defer languagesMu.RUnlock()
+
+	codes := make([]string, 0, len(languages))
+	for code := range languages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Split text into sentences
+func (l *Language) SplitBySentences(text string) []string {
+	sents := l.tokenizer.Tokenize(text)
+	out := make([]string, len(sents))
+	for i, s := range sents {
+		out[i] = strings.TrimSpace(s.Text)
+	}
+	return out
+}
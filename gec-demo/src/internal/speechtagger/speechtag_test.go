@@ -19,11 +19,24 @@ func failed_test(result []string, exp []string) string {
 	return failStr + "]"
 }
 
+// Skip tests that need the tagger's weights, which aren't checked in with the repo
+func requireWeights(t *testing.T) {
+	if _, err := modelData.ReadFile("data/weights.gob"); err != nil {
+		t.Skip("tagger weights (data/weights.gob) not available")
+	}
+}
+
 func TestInitTaggingModel(t *testing.T) {
 	// Functions Tested: InitTaggingModel(), decodeGob()
 	// Call the InitTaggingModel function
 	err := InitTaggingModel()
 
+	// Check if the SentTokenizer is initialized, it doesn't need the tagger's weights
+	if SentTokenizer == nil {
+		t.Errorf("SentTokenizer is nil after initialization")
+	}
+	requireWeights(t)
+
 	// Check if the initialization returned an error
 	if err != nil {
 		t.Errorf("InitTaggingModel() returned an error: %v", err)
@@ -34,11 +47,6 @@ func TestInitTaggingModel(t *testing.T) {
 		t.Errorf("TaggerModel is nil after initialization")
 	}
 
-	// Check if the SentTokenizer is initialized
-	if SentTokenizer == nil {
-		t.Errorf("SentTokenizer is nil after initialization")
-	}
-
 	/* if TagsGob == "" || WeightsGob == "" {
 		t.Errorf("ERROR: TagsGob or WeightsGob is empty")
 	} */
@@ -150,13 +158,14 @@ func TestSplitBySentences(t *testing.T) {
 			result := SplitBySentences(tt.text)
 			if !reflect.DeepEqual(result, tt.expected) {
 				failStr := failed_test(result, tt.expected)
-				t.Errorf(failStr)
+				t.Error(failStr)
 			}
 		})
 	}
 }
 
 func TestTagSpeech(t *testing.T) {
+	requireWeights(t)
 	tests := []struct {
 		name     string
 		text     string
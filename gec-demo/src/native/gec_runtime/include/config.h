@@ -7,5 +7,6 @@
 #define GIBB_CLASSES 4      // Clean, Mild, Word-Salad, Noise
 #define MAX_TOKENS 100      // Maximum sequence length allowed 
 #define MAX_BATCH_SIZE 500  // Maximum batch size allowed
+#define MAX_BEAM_WIDTH 8    // Maximum beams kept per sequence
 
 #endif // CONFIG_H
\ No newline at end of file
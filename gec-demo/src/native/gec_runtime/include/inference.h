@@ -7,12 +7,25 @@
 #include "config.h"
 #include "logger.h"
 #include "onnxruntime_c_api.h"
+#include "sentencepiece_wrapper.h"
 
 // Decoder Input/Output Names
 extern char* decoder_output_names[51];
 extern char* decPast_input_names[51];
 extern char* decPast_output_names[25];
 
+// Score of a beam that can't be continued, such as the copies of a sequence's first beam before
+// the first token is generated
+#define BEAM_SCORE_NONE -1e9f
+
+// How GecoRun() decodes the texts
+typedef struct {
+    int beam_width;       // Beams kept per sequence, 1 decodes greedily
+    int num_best;         // Corrections returned per sequence, best first. At most beam_width
+    float length_penalty; // Finished beams are scored by their log-probability / length^length_penalty
+    int no_repeat_ngram;  // Size of the n-grams a sequence can't generate twice, 0 only stops ABABAB loops
+} GecoDecodeOptions;
+
 // G.E.C.O. => Grammar Error Corrector Onnx
 typedef struct {
     OrtValue* input_tensor;
@@ -45,6 +58,17 @@ typedef struct {
 
     // Generated Tokens
     int generated_tokens[MAX_BATCH_SIZE][MAX_TOKENS]; // Array of generated tokens for each sequence in the batch
+    float generated_logprobs[MAX_BATCH_SIZE][MAX_TOKENS]; // Log-probability of each generated token
+
+    // Beam Search
+    GecoDecodeOptions decoding;          // Decoding options of the current run
+    int num_sequences;                   // Sequences being decoded, each with decoding.beam_width rows
+    float beam_scores[MAX_BATCH_SIZE];   // Summed log-probability of the tokens of each beam
+    int beam_origins[MAX_BATCH_SIZE];    // Row each beam was continued from in the last run
+    int finished_tokens[MAX_BATCH_SIZE][MAX_TOKENS];    // Beams that generated their EOS token, decoding.beam_width slots per sequence
+    float finished_logprobs[MAX_BATCH_SIZE][MAX_TOKENS];
+    float finished_scores[MAX_BATCH_SIZE];              // Length penalized score of each finished beam
+    int finished_count[MAX_BATCH_SIZE];                 // Number of finished beams of each sequence
 
     // SentencePiece Utilities
     void* processor;
@@ -87,19 +111,45 @@ void free_binded_tensors(Geco* geco);
 
 
 /**
- * @brief Checks if last 6 tokens in an array are the same or alternating between two distinct
- * values.
+ * @brief Finds the tokens that would repeat an n-gram already in a sequence if generated next.
+ *
+ * @param arr Array of tokens in a sequence, starting with the decoder start token
+ * @param lastInd The last index with a generated token in the array
+ * @param ngram Size of the n-grams that can't repeat. 0 only bans the tokens continuing an ABABAB loop
+ * @param banned Array of at least MAX_TOKENS values to hold the banned token IDs
+ *
+ * @return Number of banned tokens
+ */
+int bannedTokens(const int* arr, int lastInd, int ngram, int* banned);
+
+/**
+ * @brief Log of the softmax denominator of a sequence's logits over the whole vocabulary.
+ * Subtracting it from a logit gives the token's log-probability
+ *
+ * @param logits LOGIT_SIZE logits of one sequence
+ * @param fp16 True if the logits are _Float16 values, otherwise they are float values
+ *
+ * @return The log-sum-exp of the logits
+ */
+float logSoftmaxNorm(const void* logits, bool fp16);
+
+/**
+ * @brief Log-probability of a token, from the log-softmax of a sequence's logits over the whole
+ * vocabulary.
  *
- * @param arr Array of tokens in a sequence to be checked for repeating tokens.
- * @param lastInd The last index with a valid value in the array. (Not a padding 0 token)
+ * @param logits LOGIT_SIZE logits of one sequence
+ * @param fp16 True if the logits are _Float16 values, otherwise they are float values
+ * @param token Token ID to get the log-probability of
  *
- * @return True if the last 6 tokens are repeating/alternating. Returns False otherwise
+ * @return The log-probability of the token
  */
-bool checkRepeating(int* arr, int lastInd);
+float tokenLogProb(const void* logits, bool fp16, int token);
 
 /**
  * @brief Takes a logits tensor and adds the maximum token IDs for each sequence into the newTokens
- * array.
+ * array. The log-probability of each new token is stored in generated_logprobs.
+ * Tokens that would repeat an n-gram of size decoding.no_repeat_ngram, or continue an ABABAB loop
+ * when it is 0, are skipped
  *
  * @param geco GECO object for context
  * @param newTokens Array of batchSize-many values to hold the token ID of the most probable next
@@ -112,6 +162,44 @@ bool checkRepeating(int* arr, int lastInd);
  */
 int getMaxTokens(Geco* geco, int64_t* newTokens, int batchSize, int* completed_sequences, int runNum);
 
+/**
+ * @brief Takes a logits tensor and continues the beams of each sequence with their 2*beam_width
+ * most probable next tokens. Beams that generate their EOS token are kept as finished
+ * hypotheses, the best of the others become the new beams. The beam histories are reordered in
+ * generated_tokens, and the row each beam continued from is stored in beam_origins.
+ * A sequence is completed once none of its beams can beat its worst finished hypothesis
+ *
+ * @param geco GECO object for context
+ * @param newTokens Array of batchSize-many values to hold the next token of each beam
+ * @param batchSize Number of beams being processed at once, num_sequences * beam_width
+ * @param completed_sequences Array marking which beams' sequences have already been completed
+ * @param runNum Number of run
+ *
+ * @return 0 if successful, -1 if an error occurs
+ */
+int getBeamTokens(Geco* geco, int64_t* newTokens, int batchSize, int* completed_sequences, int runNum);
+
+/**
+ * @brief Reorders the rows of the decoder's past key & value tensors to follow beam_origins, so
+ * each beam keeps attending to the tokens it continued from
+ *
+ * @param geco GECO object for context. Its binded_tensors hold the outputs of decoder_with_past
+ * @param batchSize Number of beams being processed at once
+ *
+ * @return 0 if successful, -1 if an error occurs
+ */
+int reorderBeams(Geco* geco, int batchSize);
+
+/**
+ * @brief Picks the decoding.num_best best hypotheses of each sequence once decoding is done.
+ * Beams that never finished are scored as they are. The hypotheses of sequence i are written to
+ * the generated_tokens rows starting at i*num_best, best first
+ *
+ * @param geco GECO object for context
+ * @param completed_sequences Array marking which beams' sequences were completed
+ */
+void finalizeBeams(Geco* geco, int* completed_sequences);
+
 
 /**
  * @brief Recursive run of decoder_wtih_past_model.onnx
@@ -150,10 +238,18 @@ void runDecoders(Geco* geco, int batchSize);
  * @param context GECO object to run the inference with
  * @param texts Array of texts to be processed
  * @param num_texts Number of texts split into sentences
+ * @param opts How to decode the texts. Options out of range are clamped
+ * @param result Filled with the corrected text of each input text and the beam width & n-best used.
+ * Its sentences are left NULL if an error occurs, otherwise free them with FreeGecoResult()
+ */
+void GecoRun(void* context, char** texts, int num_texts, GecoDecodeOptions opts, GecoResult* result);
+void InferModel(Geco* geco, char** texts, int num_texts, GecoDecodeOptions opts, GecoResult* result);
+
+/**
+ * @brief Frees the corrected texts of a GecoRun() result
  *
- * @return String containing the corrected text.
+ * @param result Result filled by GecoRun()
  */
-void GecoRun(void* context, char** texts, int num_texts, char** result);
-void InferModel(Geco* geco, char** texts, int num_texts, char** result);
+void FreeGecoResult(GecoResult* result);
 
 #endif // INFERENCE_H
\ No newline at end of file
@@ -12,11 +12,38 @@ typedef struct {
     int64_t attention_mask[MAX_BATCH_SIZE * MAX_TOKENS];
     int64_t shape[2];
     size_t data_len;
-    int newline_size;                 // Number of newline strings
-    int newline_inds[MAX_BATCH_SIZE]; // Indicies of the newline strings in the full array of texts
-    char** newline_strs;              // Newline strings to add back to the text later
+    int num_texts;    // Number of texts that were prepared
+    int* text_groups; // Index of the sequence each text was grouped into, -1 for newline strings & texts left out
+    int* text_tokens; // Number of tokens each text was encoded into
 } TokenizedTexts;
 
+// A generated token of a corrected text
+typedef struct {
+    int begin;     // Byte offset of the token's text in the corrected text
+    int end;       // Byte offset of the end of the token's text
+    float logprob; // Log-probability the model generated the token with
+} GecoToken;
+
+// Corrected text of one of the texts given to GecoRun()
+typedef struct {
+    char* text;        // Corrected text, NULL when it was corrected in the same sequence as the text before it
+    int group;         // Index of the sequence it was corrected in, -1 for newline strings & texts left out
+    int input_tokens;  // Number of tokens the text was encoded into
+    int output_tokens; // Number of tokens generated for the sequence, only set on its first text
+    GecoToken* tokens; // Tokens decoded into the text, NULL when there is no text or it wasn't generated
+    int num_tokens;
+    char** alternatives; // Less likely corrections of the sequence from n-best decoding, best first
+    int num_alternatives;
+} GecoSentence;
+
+// Results of GecoRun(), one sentence per input text
+typedef struct {
+    GecoSentence* sentences;
+    int num_sentences;
+    int beam_width; // Beams kept per sequence, narrower than requested when the batch couldn't fit them all
+    int num_best;   // Corrections returned per sequence
+} GecoResult;
+
 #ifdef __cplusplus
 extern "C" {
 #endif
@@ -39,23 +66,32 @@ void* initialize_processor(const char* model_path);
  * @param num_texts Number of texts in the texts array
  *
  * @return Structure containing the tokenized ids and attention mask, as well as
- * newline string info to add back once the models results are decoded
+ * the sequence each text was grouped into
  */
 TokenizedTexts* prepare_texts(void* processor_ptr, char** texts, int num_texts);
 
 /**
- * @brief Groups the texts into strings less than total max_tokens
- * Then tokenizes those strings
+ * @brief Decodes the generated token IDs of each sequence into the corrected text of the texts
+ * grouped into it. Newline strings & texts that didn't fit in the batch are returned unchanged
  *
  * @param processor_ptr Void pointer to the SentencePieceProcessor object
- * @param decoded_ids Array of token IDs from the decoder model which will be turned into text
+ * @param decoded_ids Array of token IDs from the decoder model which will be turned into text.
+ * Sequence i has num_best rows starting at row i*num_best, best first
+ * @param logprobs Log-probability of each token in decoded_ids
+ * @param num_best Number of corrections generated for each sequence
  * @param tokensObj Pointer to the TokenizedTexts object
+ * @param texts Array of texts given to prepare_texts()
+ * @param result Result to fill with one sentence per text. Free it with FreeGecoResult()
  *
- * @return The final grammatically corrected string
+ * @return 0 if successful, -1 if an error occurs
  */
-char* decode_texts(void* processor_ptr,
-                   int decoded_ids[MAX_BATCH_SIZE][MAX_TOKENS],
-                   TokenizedTexts* tokensObj);
+int decode_texts(void* processor_ptr,
+                 int decoded_ids[MAX_BATCH_SIZE][MAX_TOKENS],
+                 float logprobs[MAX_BATCH_SIZE][MAX_TOKENS],
+                 int num_best,
+                 TokenizedTexts* tokensObj,
+                 char** texts,
+                 GecoResult* result);
 
 /**
  * @brief Free memory allocated by SentencePieceProcessor object
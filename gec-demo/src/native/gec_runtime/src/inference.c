@@ -1,5 +1,6 @@
 #include "inference.h"
 #include "sentencepiece_wrapper.h"
+#include <math.h>
 
 // Path Variables
 static char PATH_ENCODER[1000] = "/models/GecModel/encoder_model.onnx";
@@ -217,21 +218,78 @@ void free_binded_tensors(Geco* geco) {
     }
 }
 
-bool checkRepeating(int* arr, int lastInd) {
-    if (arr == NULL) {
-        Log(ERROR, "checkRepeating() Array pointer is NULL");
-        return false;
+int bannedTokens(const int* arr, int lastInd, int ngram, int* banned) {
+    if (arr == NULL || banned == NULL) {
+        Log(ERROR, "bannedTokens() Array pointer is NULL");
+        return 0;
     }
-    if (lastInd < 5) {
-        return false;
+    // Without an n-gram size only a loop over one or two tokens (ABABAB) is stopped, by banning both
+    if (ngram <= 0) {
+        if (lastInd < 5) {
+            return 0;
+        }
+        if (arr[lastInd] == arr[lastInd-2] && arr[lastInd] == arr[lastInd-4] && arr[lastInd-1] == arr[lastInd-3] && arr[lastInd-1] == arr[lastInd-5]) {
+            banned[0] = arr[lastInd];
+            banned[1] = arr[lastInd-1];
+            return 2;
+        }
+        return 0;
+    }
+    // The generated tokens start after the decoder start token, and need a whole n-gram to repeat
+    if (lastInd < ngram) {
+        return 0;
+    }
+
+    // Every n-gram starting with the last ngram-1 tokens bans the token that ended it
+    int numBanned = 0;
+    int prefix = lastInd - ngram + 2;
+    for (int i = 1; i + ngram - 1 <= lastInd; i++) {
+        int j = 0;
+        while (j < ngram - 1 && arr[i+j] == arr[prefix+j]) {
+            j++;
+        }
+        if (j == ngram - 1) {
+            banned[numBanned++] = arr[i+ngram-1];
+        }
     }
+    return numBanned;
+}
 
-    if (arr[lastInd] == arr[lastInd-2] && arr[lastInd] == arr[lastInd-4] && arr[lastInd-1] == arr[lastInd-3] && arr[lastInd-1] == arr[lastInd-5]) {
-        return true;
+// Check if a token is in a list of banned tokens
+static bool isBanned(const int* banned, int numBanned, int token) {
+    for (int i = 0; i < numBanned; i++) {
+        if (banned[i] == token) {
+            return true;
+        }
     }
     return false;
 }
 
+// Logit of a token, from _Float16 or float logits
+static inline float logitAt(const void* logits, bool fp16, int token) {
+    return fp16 ? (float)((const _Float16*)logits)[token] : ((const float*)logits)[token];
+}
+
+float logSoftmaxNorm(const void* logits, bool fp16) {
+    // Subtract the largest logit so the exponents can't overflow
+    float maxVal = logitAt(logits, fp16, 0);
+    for (int i = 1; i < LOGIT_SIZE; i++) {
+        float val = logitAt(logits, fp16, i);
+        if (val > maxVal) {
+            maxVal = val;
+        }
+    }
+    float sum = 0.0f;
+    for (int i = 0; i < LOGIT_SIZE; i++) {
+        sum += expf(logitAt(logits, fp16, i) - maxVal);
+    }
+    return maxVal + logf(sum);
+}
+
+float tokenLogProb(const void* logits, bool fp16, int token) {
+    return logitAt(logits, fp16, token) - logSoftmaxNorm(logits, fp16);
+}
+
 int getMaxTokens(Geco* geco, int64_t* newTokens, int batchSize, int* completed_sequences, int runNum) {
     // Check for NULL pointers
     if (!geco || !geco->binded_tensors[0] || !newTokens || !completed_sequences) {
@@ -255,12 +313,9 @@ int getMaxTokens(Geco* geco, int64_t* newTokens, int batchSize, int* completed_s
                 continue;
             }
 
-            // If the sequence is starting a repeating loop then mark the indexes we won't allow to be generated next
-            int ignore_indexes[2] = {-1, -1};
-            if (checkRepeating(geco->generated_tokens[seqNum], runNum-1)) {
-                ignore_indexes[0] = geco->generated_tokens[seqNum][runNum-1];
-                ignore_indexes[1] = geco->generated_tokens[seqNum][runNum-2];
-            }
+            // Mark the tokens that would repeat an n-gram so they won't be generated next
+            int banned[MAX_TOKENS];
+            int numBanned = bannedTokens(geco->generated_tokens[seqNum], runNum-1, geco->decoding.no_repeat_ngram, banned);
 
             int start_index = seqNum * LOGIT_SIZE;
             _Float16 maxVal = logitData[start_index];
@@ -268,8 +323,8 @@ int getMaxTokens(Geco* geco, int64_t* newTokens, int batchSize, int* completed_s
 
             for (int i = (start_index+1); i < (start_index+LOGIT_SIZE); i++) {
                 if (logitData[i] > maxVal) {
-                    if ((i-start_index) == ignore_indexes[0] || (i-start_index) == ignore_indexes[1]) {
-                        continue;   // Token is in the ignore list, so skip it
+                    if (isBanned(banned, numBanned, i-start_index)) {
+                        continue;   // Token would repeat an n-gram, so skip it
                     }
                     maxVal = logitData[i];
                     nextToken = i - start_index;
@@ -277,6 +332,7 @@ int getMaxTokens(Geco* geco, int64_t* newTokens, int batchSize, int* completed_s
             }
             // Add to array of generated tokens
             newTokens[seqNum] = (int64_t)nextToken;
+            geco->generated_logprobs[seqNum][runNum] = tokenLogProb(&logitData[start_index], true, nextToken);
             
             // If this sequence reaches its eos token, mark it as completed
             if (nextToken == 1) {
@@ -300,12 +356,9 @@ int getMaxTokens(Geco* geco, int64_t* newTokens, int batchSize, int* completed_s
                 continue;
             }
 
-            // If the sequence is starting a repeating loop then mark the indexes we won't allow to be generated next
-            int ignore_indexes[2] = {-1, -1};
-            if (checkRepeating(geco->generated_tokens[seqNum], runNum-1)) {
-                ignore_indexes[0] = geco->generated_tokens[seqNum][runNum-1];
-                ignore_indexes[1] = geco->generated_tokens[seqNum][runNum-2];
-            }
+            // Mark the tokens that would repeat an n-gram so they won't be generated next
+            int banned[MAX_TOKENS];
+            int numBanned = bannedTokens(geco->generated_tokens[seqNum], runNum-1, geco->decoding.no_repeat_ngram, banned);
 
             int start_index = seqNum * LOGIT_SIZE;
             float maxVal = logitData[start_index];
@@ -313,8 +366,8 @@ int getMaxTokens(Geco* geco, int64_t* newTokens, int batchSize, int* completed_s
 
             for (int i = (start_index+1); i < (start_index+LOGIT_SIZE); i++) {
                 if (logitData[i] > maxVal) {
-                    if ((i-start_index) == ignore_indexes[0] || (i-start_index) == ignore_indexes[1]) {
-                        continue;   // Token is in the ignore list, so skip it
+                    if (isBanned(banned, numBanned, i-start_index)) {
+                        continue;   // Token would repeat an n-gram, so skip it
                     }
                     maxVal = logitData[i];
                     nextToken = i - start_index;
@@ -322,6 +375,7 @@ int getMaxTokens(Geco* geco, int64_t* newTokens, int batchSize, int* completed_s
             }
             // Add to array of generated tokens
             newTokens[seqNum] = (int64_t)nextToken;
+            geco->generated_logprobs[seqNum][runNum] = tokenLogProb(&logitData[start_index], false, nextToken);
             
             // If this sequence reaches its eos token, mark it as completed
             if (nextToken == 1) {
@@ -334,13 +388,291 @@ int getMaxTokens(Geco* geco, int64_t* newTokens, int batchSize, int* completed_s
     return 0;
 }
 
+// A possible next token of a beam
+typedef struct {
+    int row;       // Row of the beam it continues
+    int token;
+    float logprob; // Log-probability of the token
+    float score;   // Summed log-probability of the beam with the token
+} BeamCandidate;
+
+// Insert a candidate into an array sorted by descending score, keeping at most maxCands of them
+static void addCandidate(BeamCandidate* cands, int* numCands, int maxCands, BeamCandidate cand) {
+    if (*numCands == maxCands && cand.score <= cands[maxCands-1].score) {
+        return;
+    }
+    int i = (*numCands < maxCands) ? (*numCands)++ : maxCands-1;
+    while (i > 0 && cands[i-1].score < cand.score) {
+        cands[i] = cands[i-1];
+        i--;
+    }
+    cands[i] = cand;
+}
+
+// Keep a beam ending with `token` at runNum as one of the beam_width best hypotheses of its sequence
+static void addHypothesis(Geco* geco, int seq, int row, int runNum, int token, float logprob, float score) {
+    int width = geco->decoding.beam_width;
+    int slot = seq*width + geco->finished_count[seq];
+    if (geco->finished_count[seq] == width) {
+        // Replace the worst hypothesis if this one is better
+        slot = seq*width;
+        for (int i = slot+1; i < (seq+1)*width; i++) {
+            if (geco->finished_scores[i] < geco->finished_scores[slot]) {
+                slot = i;
+            }
+        }
+        if (score <= geco->finished_scores[slot]) {
+            return;
+        }
+    } else {
+        geco->finished_count[seq]++;
+    }
+
+    memset(geco->finished_tokens[slot], 0, sizeof(geco->finished_tokens[slot]));
+    memset(geco->finished_logprobs[slot], 0, sizeof(geco->finished_logprobs[slot]));
+    memcpy(geco->finished_tokens[slot], geco->generated_tokens[row], runNum*sizeof(int));
+    memcpy(geco->finished_logprobs[slot], geco->generated_logprobs[row], runNum*sizeof(float));
+    geco->finished_tokens[slot][runNum] = token;
+    geco->finished_logprobs[slot][runNum] = logprob;
+    geco->finished_scores[slot] = score;
+}
+
+// Lowest score of a sequence's finished hypotheses
+static float worstHypothesis(Geco* geco, int seq) {
+    int width = geco->decoding.beam_width;
+    float worst = geco->finished_scores[seq*width];
+    for (int i = seq*width+1; i < seq*width + geco->finished_count[seq]; i++) {
+        if (geco->finished_scores[i] < worst) {
+            worst = geco->finished_scores[i];
+        }
+    }
+    return worst;
+}
+
+int getBeamTokens(Geco* geco, int64_t* newTokens, int batchSize, int* completed_sequences, int runNum) {
+    // Check for NULL pointers
+    if (!geco || !geco->binded_tensors[0] || !newTokens || !completed_sequences) {
+        Log(ERROR, "NULL pointer detected in input arguments");
+        return -1;
+    }
+
+    // Get the logits data as a readable array
+    char* logitData = NULL;
+    if (geco->g_ort->GetTensorMutableData(geco->binded_tensors[0], (void**)&logitData) != NULL) {
+        Log(ERROR, "Failed to get logits data");
+        return -1;
+    }
+    size_t logitBytes = USING_F16_MODEL ? sizeof(_Float16) : sizeof(float);
+
+    int width = geco->decoding.beam_width;
+    float lengthPenalty = powf((float)runNum, geco->decoding.length_penalty);
+    int banned[MAX_TOKENS];
+    int prevTokens[MAX_BEAM_WIDTH][MAX_TOKENS];
+    float prevLogprobs[MAX_BEAM_WIDTH][MAX_TOKENS];
+
+    for (int seq = 0; seq < geco->num_sequences; seq++) {
+        int first = seq * width;
+        if (completed_sequences[first] == 1) {
+            // This sequence is already completed, so its beams get 0's and stay in place
+            for (int row = first; row < first+width && row < batchSize; row++) {
+                newTokens[row] = 0;
+                geco->beam_origins[row] = row;
+            }
+            continue;
+        }
+
+        // Find the 2*width most probable continuations of the sequence's beams, so width of them are left
+        // after the ones generating the EOS token
+        BeamCandidate cands[2*MAX_BEAM_WIDTH];
+        int numCands = 0;
+        for (int row = first; row < first+width; row++) {
+            if (geco->beam_scores[row] <= BEAM_SCORE_NONE) {
+                continue;
+            }
+            const void* logits = logitData + (size_t)row*LOGIT_SIZE*logitBytes;
+            float norm = logSoftmaxNorm(logits, USING_F16_MODEL);
+            int numBanned = bannedTokens(geco->generated_tokens[row], runNum-1, geco->decoding.no_repeat_ngram, banned);
+            for (int tok = 0; tok < LOGIT_SIZE; tok++) {
+                float logprob = logitAt(logits, USING_F16_MODEL, tok) - norm;
+                BeamCandidate cand = {row, tok, logprob, geco->beam_scores[row] + logprob};
+                if (numCands == 2*width && cand.score <= cands[numCands-1].score) {
+                    continue;
+                }
+                if (isBanned(banned, numBanned, tok)) {
+                    continue;   // Token would repeat an n-gram, so skip it
+                }
+                addCandidate(cands, &numCands, 2*width, cand);
+            }
+        }
+
+        // EOS tokens among the width best candidates finish their beam, the best of the others are kept
+        BeamCandidate next[MAX_BEAM_WIDTH];
+        int numNext = 0;
+        for (int c = 0; c < numCands && numNext < width; c++) {
+            if (cands[c].token == 1) {
+                if (c < width) {
+                    addHypothesis(geco, seq, cands[c].row, runNum, 1, cands[c].logprob, cands[c].score / lengthPenalty);
+                }
+                continue;
+            }
+            next[numNext++] = cands[c];
+        }
+
+        // Copy the histories of the beams being continued before their rows are overwritten
+        for (int k = 0; k < numNext; k++) {
+            memcpy(prevTokens[k], geco->generated_tokens[next[k].row], runNum*sizeof(int));
+            memcpy(prevLogprobs[k], geco->generated_logprobs[next[k].row], runNum*sizeof(float));
+        }
+        for (int k = 0; k < width; k++) {
+            int row = first + k;
+            newTokens[row] = 0;
+            geco->beam_origins[row] = row;
+            if (k >= numNext) {
+                // Not enough candidates to continue every beam
+                geco->beam_scores[row] = BEAM_SCORE_NONE;
+                continue;
+            }
+            memcpy(geco->generated_tokens[row], prevTokens[k], runNum*sizeof(int));
+            memcpy(geco->generated_logprobs[row], prevLogprobs[k], runNum*sizeof(float));
+            geco->generated_tokens[row][runNum] = next[k].token;
+            geco->generated_logprobs[row][runNum] = next[k].logprob;
+            geco->beam_scores[row] = next[k].score;
+            geco->beam_origins[row] = next[k].row;
+            newTokens[row] = (int64_t)next[k].token;
+        }
+
+        // The sequence is completed once its best beam can't beat its worst finished hypothesis
+        bool done = numNext == 0;
+        if (geco->finished_count[seq] == width) {
+            done = done || next[0].score / lengthPenalty <= worstHypothesis(geco, seq);
+        }
+        if (done) {
+            for (int row = first; row < first+width; row++) {
+                completed_sequences[row] = 1;
+            }
+        }
+    }
+    return 0;
+}
+
+int reorderBeams(Geco* geco, int batchSize) {
+    // Nothing to move if every beam continued from its own row
+    bool moved = false;
+    for (int i = 0; i < batchSize; i++) {
+        if (geco->beam_origins[i] != i) {
+            moved = true;
+            break;
+        }
+    }
+    if (!moved) {
+        return 0;
+    }
+
+    OrtTensorTypeAndShapeInfo* shape_info = NULL;
+    char* rows = NULL;
+    for (int i = 1; i < 25; i++) {
+        // Get the tensor's size & element type
+        size_t total_len;
+        ONNXTensorElementDataType elem_type;
+        ORT_CLEAN_ON_ERROR(reorder_error_clean, geco, geco->g_ort->GetTensorTypeAndShape(geco->binded_tensors[i], &shape_info));
+        ORT_CLEAN_ON_ERROR(reorder_error_clean, geco, geco->g_ort->GetTensorShapeElementCount(shape_info, &total_len));
+        ORT_CLEAN_ON_ERROR(reorder_error_clean, geco, geco->g_ort->GetTensorElementType(shape_info, &elem_type));
+        geco->g_ort->ReleaseTensorTypeAndShapeInfo(shape_info);
+        shape_info = NULL;
+
+        size_t elem_size = (elem_type == ONNX_TENSOR_ELEMENT_DATA_TYPE_FLOAT16) ? sizeof(_Float16) : sizeof(float);
+        size_t row_bytes = (total_len / batchSize) * elem_size;
+        char* data = NULL;
+        ORT_CLEAN_ON_ERROR(reorder_error_clean, geco, geco->g_ort->GetTensorMutableData(geco->binded_tensors[i], (void**)&data));
+
+        // Copy the rows out, then put each beam's origin row in its place
+        rows = (char*)malloc(row_bytes * batchSize);
+        if (rows == NULL) {
+            Log(ERROR, "Memory allocation for beam reordering failed");
+            goto reorder_error_clean;
+        }
+        memcpy(rows, data, row_bytes * batchSize);
+        for (int row = 0; row < batchSize; row++) {
+            if (geco->beam_origins[row] != row) {
+                memcpy(data + row*row_bytes, rows + geco->beam_origins[row]*row_bytes, row_bytes);
+            }
+        }
+        free(rows);
+        rows = NULL;
+    }
+    return 0;
+
+    reorder_error_clean:
+    if (shape_info != NULL) {
+        geco->g_ort->ReleaseTensorTypeAndShapeInfo(shape_info);
+    }
+    free(rows);
+    return -1;
+}
+
+void finalizeBeams(Geco* geco, int* completed_sequences) {
+    int width = geco->decoding.beam_width;
+    int numBest = geco->decoding.num_best;
+
+    // Decoding stopped before these sequences were done, so their beams are scored as they are
+    for (int seq = 0; seq < geco->num_sequences; seq++) {
+        if (completed_sequences[seq*width] == 1) {
+            continue;
+        }
+        for (int row = seq*width; row < (seq+1)*width; row++) {
+            int lastInd = MAX_TOKENS-1;
+            while (lastInd > 0 && geco->generated_tokens[row][lastInd] == 0) {
+                lastInd--;
+            }
+            if (geco->beam_scores[row] <= BEAM_SCORE_NONE || lastInd == 0) {
+                continue;
+            }
+            float score = geco->beam_scores[row] / powf((float)lastInd, geco->decoding.length_penalty);
+            addHypothesis(geco, seq, row, lastInd, geco->generated_tokens[row][lastInd], geco->generated_logprobs[row][lastInd], score);
+        }
+    }
+
+    // Write the best hypotheses of each sequence in order. Missing ones are left empty
+    for (int seq = 0; seq < geco->num_sequences; seq++) {
+        int order[MAX_BEAM_WIDTH];
+        int count = geco->finished_count[seq];
+        for (int i = 0; i < count; i++) {
+            int slot = seq*width + i;
+            int j = i;
+            while (j > 0 && geco->finished_scores[order[j-1]] < geco->finished_scores[slot]) {
+                order[j] = order[j-1];
+                j--;
+            }
+            order[j] = slot;
+        }
+        for (int r = 0; r < numBest; r++) {
+            int row = seq*numBest + r;
+            if (r < count) {
+                memcpy(geco->generated_tokens[row], geco->finished_tokens[order[r]], sizeof(geco->generated_tokens[row]));
+                memcpy(geco->generated_logprobs[row], geco->finished_logprobs[order[r]], sizeof(geco->generated_logprobs[row]));
+            } else {
+                memset(geco->generated_tokens[row], 0, sizeof(geco->generated_tokens[row]));
+                memset(geco->generated_logprobs[row], 0, sizeof(geco->generated_logprobs[row]));
+            }
+        }
+    }
+}
+
+// Pick the next tokens greedily, or with beam search when more than one beam is kept
+static int selectTokens(Geco* geco, int64_t* newTokens, int batchSize, int* completed_sequences, int runNum) {
+    if (geco->decoding.beam_width > 1) {
+        return getBeamTokens(geco, newTokens, batchSize, completed_sequences, runNum);
+    }
+    return getMaxTokens(geco, newTokens, batchSize, completed_sequences, runNum);
+}
+
 void runPast(Geco* geco, int runNum, int64_t* nextToks, int batchSize, int* completed_sequences) {
     // Run the Model with IO Bindings and get the output tensors
     ORT_CLEAN_ON_ERROR(decPast_cleanup, geco, geco->g_ort->RunWithBinding(geco->decPast_session, geco->run_options, geco->decPast_io_binding));
     ORT_CLEAN_ON_ERROR(decPast_cleanup, geco, geco->g_ort->GetBoundOutputValues(geco->decPast_io_binding, geco->allocator, &geco->binded_tensors, &geco->binded_tensors_len));
 
     // Get the most likely next tokens
-    if (getMaxTokens(geco, nextToks, batchSize, completed_sequences, runNum)) {
+    if (selectTokens(geco, nextToks, batchSize, completed_sequences, runNum)) {
         goto decPast_cleanup;
     }
 
@@ -359,6 +691,11 @@ void runPast(Geco* geco, int runNum, int64_t* nextToks, int batchSize, int* comp
         goto decPast_cleanup;
     }
 
+    // Beams continue from the past keys & values of the beam they were picked from
+    if (geco->decoding.beam_width > 1 && reorderBeams(geco, batchSize)) {
+        goto decPast_cleanup;
+    }
+
     // Bind the input tensor: "input_ids"
     ORT_CLEAN_ON_ERROR(decPast_cleanup, geco, geco->g_ort->CreateTensorWithDataAsOrtValue(geco->memory_info, nextToks, (batchSize*sizeof(int64_t)), (int64_t[]){batchSize, 1}, 2, ONNX_TENSOR_ELEMENT_DATA_TYPE_INT64, &geco->input_tensor));
     ORT_CLEAN_ON_ERROR(decPast_cleanup, geco, geco->g_ort->BindInput(geco->decPast_io_binding, "input_ids", geco->input_tensor));
@@ -420,7 +757,8 @@ void runDecoders(Geco* geco, int batchSize) {
 
     // Get the return output values as OrtValue* Tensors
     ORT_CLEAN_ON_ERROR(decoder_cleanup, geco, geco->g_ort->GetBoundOutputValues(geco->dec_io_binding, geco->allocator, &geco->binded_tensors, &geco->binded_tensors_len));
-    if (getMaxTokens(geco, newTokens, batchSize, completed_sequences, 1)) {
+    // Every beam of a sequence starts out the same, so there are no past keys & values to reorder yet
+    if (selectTokens(geco, newTokens, batchSize, completed_sequences, 1)) {
         goto decoder_cleanup;
     }
 
@@ -451,6 +789,9 @@ void runDecoders(Geco* geco, int batchSize) {
 
     // Run and recurse
     runPast(geco, 2, newTokens, batchSize, completed_sequences);
+    if (geco->decoding.beam_width > 1) {
+        finalizeBeams(geco, completed_sequences);
+    }
 
     // Clean up
     decoder_cleanup:
@@ -505,7 +846,11 @@ int extract_float16_from_tensor(Geco* geco) {
     return -1;
 }
 
-void GecoRun(void* context, char** texts, int num_texts, char** result) {
+void GecoRun(void* context, char** texts, int num_texts, GecoDecodeOptions opts, GecoResult* result) {
+    result->sentences = NULL;
+    result->num_sentences = 0;
+    result->beam_width = opts.beam_width;
+    result->num_best = opts.num_best;
     if (context == NULL) {
         Log(ERROR, "Invalid Geco context!");
         return;
@@ -516,13 +861,74 @@ void GecoRun(void* context, char** texts, int num_texts, char** result) {
 
     // Call the InferModel function with the geco and input texts
     Log(DEBUG, "Infer GEC on device '%s'", geco->device_id);
-    InferModel(geco, texts, num_texts, result);
+    InferModel(geco, texts, num_texts, opts, result);
 }
 
-void InferModel(Geco* geco, char** texts, int num_texts, char** result) {
+// Clamp the decoding options to what the runtime supports. Beams are narrowed if the batch can't fit all of them,
+// and the result reports the width that was used
+static GecoDecodeOptions clampDecodeOptions(GecoDecodeOptions opts, int batchSize) {
+    int maxWidth = MAX_BATCH_SIZE / batchSize;
+    if (maxWidth > MAX_BEAM_WIDTH) {
+        maxWidth = MAX_BEAM_WIDTH;
+    }
+    if (opts.beam_width < 1) {
+        opts.beam_width = 1;
+    }
+    if (opts.beam_width > maxWidth) {
+        Log(WARNING, "Beam width %d is too large for %d sequences, using %d", opts.beam_width, batchSize, maxWidth);
+        opts.beam_width = maxWidth;
+    }
+    if (opts.num_best < 1) {
+        opts.num_best = 1;
+    }
+    if (opts.num_best > opts.beam_width) {
+        opts.num_best = opts.beam_width;
+    }
+    if (opts.no_repeat_ngram < 0) {
+        opts.no_repeat_ngram = 0;
+    }
+    return opts;
+}
+
+// Repeat each sequence's row of the encoder's output for every one of its beams, so the decoders run on one row per beam
+static int expandEncoderOutput(Geco* geco, int batchSize, int width) {
+    OrtTensorTypeAndShapeInfo* shape_info = NULL;
+    OrtValue* expanded = NULL;
+    int64_t shape[3];
+    float* src = NULL;
+    float* dst = NULL;
+    ORT_CLEAN_ON_ERROR(expand_error_clean, geco, geco->g_ort->GetTensorTypeAndShape(geco->output_tensor, &shape_info));
+    ORT_CLEAN_ON_ERROR(expand_error_clean, geco, geco->g_ort->GetDimensions(shape_info, shape, 3));
+    geco->g_ort->ReleaseTensorTypeAndShapeInfo(shape_info);
+    shape_info = NULL;
+
+    size_t row_len = (size_t)(shape[1] * shape[2]);
+    shape[0] = (int64_t)batchSize * width;
+    ORT_CLEAN_ON_ERROR(expand_error_clean, geco, geco->g_ort->CreateTensorAsOrtValue(geco->allocator, shape, 3, ONNX_TENSOR_ELEMENT_DATA_TYPE_FLOAT, &expanded));
+    ORT_CLEAN_ON_ERROR(expand_error_clean, geco, geco->g_ort->GetTensorMutableData(geco->output_tensor, (void**)&src));
+    ORT_CLEAN_ON_ERROR(expand_error_clean, geco, geco->g_ort->GetTensorMutableData(expanded, (void**)&dst));
+    for (int row = 0; row < batchSize*width; row++) {
+        memcpy(dst + row*row_len, src + (row/width)*row_len, row_len*sizeof(float));
+    }
+
+    // The expanded output replaces the encoder's own
+    geco->g_ort->ReleaseValue(geco->output_tensor);
+    geco->output_tensor = expanded;
+    return 0;
+
+    expand_error_clean:
+    if (shape_info != NULL) {
+        geco->g_ort->ReleaseTensorTypeAndShapeInfo(shape_info);
+    }
+    geco->g_ort->ReleaseValue(expanded);
+    return -1;
+}
+
+void InferModel(Geco* geco, char** texts, int num_texts, GecoDecodeOptions opts, GecoResult* result) {
     geco->input_tensor = NULL;
     geco->output_tensor = NULL;
     geco->output_tensor_fp16 = NULL;
+    int64_t* beam_mask = NULL;  // Attention mask repeated for each beam
 
     // Group and tokenize the texts
     TokenizedTexts *tokTexts = prepare_texts(geco->processor, texts, num_texts);
@@ -543,34 +949,71 @@ void InferModel(Geco* geco, char** texts, int num_texts, char** result) {
         goto infer_cleanup;
     }
 
-    // Reset the generated tokens array to all 0's
+    geco->decoding = clampDecodeOptions(opts, batchSize);
+    result->beam_width = geco->decoding.beam_width;
+    result->num_best = geco->decoding.num_best;
+    geco->num_sequences = batchSize;
+    int width = geco->decoding.beam_width;
+    int numRows = batchSize * width;
+    Log(DEBUG, "Decoding %d sequences with %d beams, %d best", batchSize, width, geco->decoding.num_best);
+
+    // Reset the generated tokens array to all 0's. Only the first beam of each sequence starts out live
     for (int i = 0; i < MAX_BATCH_SIZE; i++) {
         for (int j = 0; j < MAX_TOKENS; j++) {
             geco->generated_tokens[i][j] = 0;
+            geco->generated_logprobs[i][j] = 0.0f;
         }
+        geco->beam_scores[i] = (i % width == 0) ? 0.0f : BEAM_SCORE_NONE;
+        geco->beam_origins[i] = i;
+        geco->finished_count[i] = 0;
+    }
+
+    // The encoder runs once per sequence. Its outputs are repeated for each beam afterwards
+    int64_t input_shape[2] = {batchSize, tokTexts->shape[1]};
+    int64_t* attention_mask = tokTexts->attention_mask;
+    size_t mask_len = tokTexts->data_len;
+    if (width > 1) {
+        mask_len = numRows * tokTexts->shape[1] * sizeof(int64_t);
+        beam_mask = (int64_t*)malloc(mask_len);
+        if (beam_mask == NULL) {
+            Log(ERROR, "Memory allocation for the beam attention mask failed");
+            goto infer_cleanup;
+        }
+        for (int row = 0; row < numRows; row++) {
+            memcpy(&beam_mask[row*tokTexts->shape[1]], &tokTexts->attention_mask[(row/width)*tokTexts->shape[1]], tokTexts->shape[1]*sizeof(int64_t));
+        }
+        attention_mask = beam_mask;
     }
 
 
     // Create & Bind the Input/Output tensors
     // Tensor: "attention_mask"
-    ORT_CLEAN_ON_ERROR(infer_cleanup, geco, geco->g_ort->CreateTensorWithDataAsOrtValue(geco->memory_info, tokTexts->attention_mask, tokTexts->data_len, tokTexts->shape, 2, ONNX_TENSOR_ELEMENT_DATA_TYPE_INT64, &geco->input_tensor));
+    ORT_CLEAN_ON_ERROR(infer_cleanup, geco, geco->g_ort->CreateTensorWithDataAsOrtValue(geco->memory_info, tokTexts->attention_mask, tokTexts->data_len, input_shape, 2, ONNX_TENSOR_ELEMENT_DATA_TYPE_INT64, &geco->input_tensor));
     ORT_CLEAN_ON_ERROR(infer_cleanup, geco, geco->g_ort->BindInput(geco->enc_io_binding, "attention_mask", geco->input_tensor));
+    free_inpTensor(geco);
+
+    // Tensor: "encoder_attention_mask", one row per beam
+    int64_t beam_shape[2] = {numRows, tokTexts->shape[1]};
+    ORT_CLEAN_ON_ERROR(infer_cleanup, geco, geco->g_ort->CreateTensorWithDataAsOrtValue(geco->memory_info, attention_mask, mask_len, beam_shape, 2, ONNX_TENSOR_ELEMENT_DATA_TYPE_INT64, &geco->input_tensor));
     ORT_CLEAN_ON_ERROR(infer_cleanup, geco, geco->g_ort->BindInput(geco->dec_io_binding, "encoder_attention_mask", geco->input_tensor));
     ORT_CLEAN_ON_ERROR(infer_cleanup, geco, geco->g_ort->BindInput(geco->decPast_io_binding, "encoder_attention_mask", geco->input_tensor));
     free_inpTensor(geco);
 
     // Tensor: "input_ids"
-    ORT_CLEAN_ON_ERROR(infer_cleanup, geco, geco->g_ort->CreateTensorWithDataAsOrtValue(geco->memory_info, tokTexts->ids, tokTexts->data_len, tokTexts->shape, 2, 7, &geco->input_tensor));
+    ORT_CLEAN_ON_ERROR(infer_cleanup, geco, geco->g_ort->CreateTensorWithDataAsOrtValue(geco->memory_info, tokTexts->ids, tokTexts->data_len, input_shape, 2, 7, &geco->input_tensor));
     ORT_CLEAN_ON_ERROR(infer_cleanup, geco, geco->g_ort->BindInput(geco->enc_io_binding, "input_ids", geco->input_tensor));
     free_inpTensor(geco);
 
     // Tensor: "last_hidden_state"
-    int64_t output_shape[3] = {tokTexts->shape[0], tokTexts->shape[1], 768};
+    int64_t output_shape[3] = {input_shape[0], input_shape[1], 768};
     ORT_CLEAN_ON_ERROR(infer_cleanup, geco, geco->g_ort->CreateTensorAsOrtValue(geco->allocator, output_shape, 3, ONNX_TENSOR_ELEMENT_DATA_TYPE_FLOAT, &geco->output_tensor));
     ORT_CLEAN_ON_ERROR(infer_cleanup, geco, geco->g_ort->BindOutput(geco->enc_io_binding, "last_hidden_state", geco->output_tensor));
 
     // Run the Encoder
     ORT_CLEAN_ON_ERROR(infer_cleanup, geco, geco->g_ort->RunWithBinding(geco->encoder_session, geco->run_options, geco->enc_io_binding));
+    if (width > 1 && expandEncoderOutput(geco, batchSize, width)) {
+        goto infer_cleanup;
+    }
     
     if (USING_F16_MODEL) {
         // Create new tensor with float16 data from 'last_hidden_state' tensor
@@ -596,14 +1039,18 @@ void InferModel(Geco* geco, char** texts, int num_texts, char** result) {
     geco->output_tensor = NULL;
 
     // Run Decoder and Decoder-With-Past model sessions
-    runDecoders(geco, batchSize);
+    runDecoders(geco, numRows);
 
     // Decode results
-    *result = decode_texts(geco->processor, geco->generated_tokens, tokTexts);
-    
+    if (decode_texts(geco->processor, geco->generated_tokens, geco->generated_logprobs, geco->decoding.num_best, tokTexts, texts, result) == -1) {
+        Log(ERROR, "Failed to decode the generated tokens");
+        FreeGecoResult(result);
+    }
+
     // CLEAN UP
     infer_cleanup:
     free_tokenized_texts(tokTexts);
+    free(beam_mask);
     free_binded_tensors(geco);
     free_inpTensor(geco);
     geco->g_ort->ReleaseValue(geco->output_tensor);
@@ -618,3 +1065,19 @@ void InferModel(Geco* geco, char** texts, int num_texts, char** result) {
     geco->g_ort->ClearBoundOutputs(geco->decPast_io_binding);
 }
 
+void FreeGecoResult(GecoResult* result) {
+    if (result == NULL || result->sentences == NULL) {
+        return;
+    }
+    for (int i = 0; i < result->num_sentences; i++) {
+        free(result->sentences[i].text);
+        free(result->sentences[i].tokens);
+        for (int j = 0; j < result->sentences[i].num_alternatives; j++) {
+            free(result->sentences[i].alternatives[j]);
+        }
+        free(result->sentences[i].alternatives);
+    }
+    free(result->sentences);
+    result->sentences = NULL;
+    result->num_sentences = 0;
+}
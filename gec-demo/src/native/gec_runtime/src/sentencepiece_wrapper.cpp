@@ -29,13 +29,19 @@ TokenizedTexts* prepare_texts(void* processor_ptr, char** texts, int num_texts)
         return nullptr;
     }
     TokenizedTexts* output = new TokenizedTexts();
+    output->num_texts = num_texts;
+    output->text_groups = new int[num_texts];
+    output->text_tokens = new int[num_texts];
+    for (int i = 0; i < num_texts; ++i) {
+        output->text_groups[i] = -1;
+        output->text_tokens[i] = 0;
+    }
 
     // Group texts into sequences of tokens less than MAX_TOKENS
     int max_length = 0;    // Length of longest sequence
     int running_total = 0; // # of tokens in current_group
     std::vector<std::vector<int>> grouped_ids = {};
     std::vector<int> current_group = {};
-    std::vector<std::string> newlineStrings = {};
 
     for (int i = 0; i < num_texts; ++i) {
         // Check for newline characters
@@ -53,10 +59,7 @@ TokenizedTexts* prepare_texts(void* processor_ptr, char** texts, int num_texts)
                 running_total = 0;
             }
 
-            // Append newline strings to their own seperate groups
-            int newLn_idx = (int)grouped_ids.size() + (int)newlineStrings.size();
-            output->newline_inds[(int)newlineStrings.size()] = newLn_idx;
-            newlineStrings.push_back(texts[i]);
+            // Newline strings aren't sent to the model, they are added back as they are when decoding
         } else {
             // Check if we've reached the maximum number of texts
             if (grouped_ids.size() >= MAX_BATCH_SIZE) {
@@ -85,6 +88,8 @@ TokenizedTexts* prepare_texts(void* processor_ptr, char** texts, int num_texts)
                 current_group.insert(current_group.end(), pieces.begin(), pieces.end());
                 running_total += pieceSz;
             }
+            output->text_groups[i] = (int)grouped_ids.size();
+            output->text_tokens[i] = pieceSz;
         }
     }
     if (!current_group.empty() && grouped_ids.size() < MAX_BATCH_SIZE) {
@@ -109,7 +114,6 @@ TokenizedTexts* prepare_texts(void* processor_ptr, char** texts, int num_texts)
     output->shape[0] = grouped_ids.size();
     output->shape[1] = max_length;
     output->data_len = num_tokens * sizeof(int64_t);
-    output->newline_size = (int)newlineStrings.size();
 
     // Set the token ids and attention mask
     for (int i = 0; i < (int)output->shape[0]; ++i) {
@@ -138,78 +142,125 @@ TokenizedTexts* prepare_texts(void* processor_ptr, char** texts, int num_texts)
         }
     }
 
-    // Convert vector of newline strings to a char** array
-    output->newline_strs = new char*[newlineStrings.size() + 1];
-    for (int i = 0; i < (int)newlineStrings.size(); i++) {
-        output->newline_strs[i] = strdup(newlineStrings[i].c_str());
-    }
-    // NULL terminate the array
-    output->newline_strs[newlineStrings.size()] = nullptr;
-
     return output;
 }
 
-char* decode_texts(void* processor_ptr,
-                   int decoded_ids[MAX_BATCH_SIZE][MAX_TOKENS],
-                   TokenizedTexts* tokensObj) {
-    std::string final_text = "";
-    int num_texts = (int)tokensObj->shape[0];
+// Collect the generated tokens of a row, after the decoder start token & up to the EOS token.
+// Padding & unknown token IDs(2) aren't decoded. Returns the number of tokens generated
+static int collect_ids(const int* ids,
+                       const float* logprobs,
+                       std::vector<int>& dec_ids,
+                       std::vector<float>& dec_logprobs) {
+    int generated = 0;
+    for (int j = 1; j < MAX_TOKENS && ids[j] != 1; ++j) {
+        if (ids[j] == 0)
+            continue;
+        generated++;
+        if (ids[j] != 2) {
+            dec_ids.push_back(ids[j]);
+            dec_logprobs.push_back(logprobs[j]);
+        }
+    }
+    return generated;
+}
+
+int decode_texts(void* processor_ptr,
+                 int decoded_ids[MAX_BATCH_SIZE][MAX_TOKENS],
+                 float logprobs[MAX_BATCH_SIZE][MAX_TOKENS],
+                 int num_best,
+                 TokenizedTexts* tokensObj,
+                 char** texts,
+                 GecoResult* result) {
+    int num_groups = (int)tokensObj->shape[0];
     sentencepiece::SentencePieceProcessor* processor =
         static_cast<sentencepiece::SentencePieceProcessor*>(processor_ptr);
     if (processor == NULL) {
         Log(ERROR, "failed casting pointer to sentencepiece processor");
-        return nullptr;
+        return -1;
     }
 
-    // Decode the token IDs into texts
-    int true_idx = 0; // Tracks index of texts including newline strings
-    int newLn_count = 0;
-    for (int i = 0; i < num_texts; ++i) {
-        if (newLn_count < tokensObj->newline_size &&
-            true_idx == tokensObj->newline_inds[newLn_count]) {
-            // Previous text was not a newline literal so remove the space at the end
-            if (final_text != "")
-                final_text.pop_back();
-
-            // Append newline string to the final text
-            final_text += tokensObj->newline_strs[newLn_count];
-            newLn_count++;
-            true_idx++;
-        }
+    result->sentences = (GecoSentence*)calloc(tokensObj->num_texts, sizeof(GecoSentence));
+    if (result->sentences == nullptr) {
+        Log(ERROR, "Memory allocation failed.");
+        return -1;
+    }
+    result->num_sentences = tokensObj->num_texts;
 
-        // Convert int* array to std::vector<int> & Remove unknown token IDs(2)
-        std::vector<int> dec_ids(decoded_ids[i], decoded_ids[i] + MAX_TOKENS);
-        dec_ids.erase(std::remove(dec_ids.begin(), dec_ids.end(), 2), dec_ids.end());
+    // Decode the token IDs of each group into the text of its first sentence
+    int last_group = -1;
+    for (int i = 0; i < tokensObj->num_texts; ++i) {
+        GecoSentence* sent = &result->sentences[i];
+        int group = tokensObj->text_groups[i];
+        sent->input_tokens = tokensObj->text_tokens[i];
+        if (group < 0 || group >= num_groups) {
+            // Newline strings & texts that didn't fit in the batch are left unchanged
+            sent->group = -1;
+            sent->text = strdup(texts[i]);
+        } else if (group == last_group) {
+            // Corrected in the same sequence as the text before it
+            sent->group = group;
+            continue;
+        } else {
+            int row = group * num_best;
+            std::vector<int> dec_ids;
+            std::vector<float> dec_logprobs;
+            sent->output_tokens = collect_ids(decoded_ids[row], logprobs[row], dec_ids, dec_logprobs);
 
-        // Decode the token IDs
-        std::string res;
-        processor->Decode(dec_ids, &res);
+            // Decode the token IDs, with the span of each token in the decoded text
+            sentencepiece::ImmutableSentencePieceText spt =
+                processor->DecodeIdsAsImmutableProto(dec_ids);
+            std::string res = spt.text();
+            if (spt.pieces_size() == dec_ids.size() && !dec_ids.empty()) {
+                sent->tokens = (GecoToken*)calloc(dec_ids.size(), sizeof(GecoToken));
+                if (sent->tokens == nullptr) {
+                    Log(ERROR, "Memory allocation failed.");
+                    return -1;
+                }
+                sent->num_tokens = (int)dec_ids.size();
+                for (int k = 0; k < sent->num_tokens; ++k) {
+                    sent->tokens[k].begin = (int)spt.pieces(k).begin();
+                    sent->tokens[k].end = (int)spt.pieces(k).end();
+                    sent->tokens[k].logprob = dec_logprobs[k];
+                }
+            }
+            sent->group = group;
+            sent->text = strdup(res.c_str());
+            last_group = group;
 
-        // Add a space between texts
-        final_text += res + " ";
-        if (i == num_texts - 1) {
-            final_text.pop_back(); // Remove the last space
+            // The other corrections of the sequence, leaving out empty & repeated ones
+            std::vector<std::string> alternatives;
+            for (int k = 1; k < num_best; ++k) {
+                std::vector<int> alt_ids;
+                std::vector<float> alt_logprobs;
+                collect_ids(decoded_ids[row + k], logprobs[row + k], alt_ids, alt_logprobs);
+                std::string alt = processor->DecodeIds(alt_ids);
+                if (!alt.empty() && alt != res &&
+                    std::find(alternatives.begin(), alternatives.end(), alt) == alternatives.end()) {
+                    alternatives.push_back(alt);
+                }
+            }
+            if (!alternatives.empty()) {
+                sent->alternatives = (char**)calloc(alternatives.size(), sizeof(char*));
+                if (sent->alternatives == nullptr) {
+                    Log(ERROR, "Memory allocation failed.");
+                    return -1;
+                }
+                for (const std::string& alt : alternatives) {
+                    sent->alternatives[sent->num_alternatives] = strdup(alt.c_str());
+                    if (sent->alternatives[sent->num_alternatives] == nullptr) {
+                        Log(ERROR, "Memory allocation failed.");
+                        return -1;
+                    }
+                    sent->num_alternatives++;
+                }
+            }
+        }
+        if (sent->text == nullptr) {
+            Log(ERROR, "Memory allocation failed.");
+            return -1;
         }
-        true_idx++;
-    }
-    if (newLn_count < tokensObj->newline_size && true_idx == tokensObj->newline_inds[newLn_count]) {
-        // Append newline string to the final text
-        final_text += tokensObj->newline_strs[newLn_count];
-    }
-
-
-    // Convert to a char* string
-    // char* result = strdup(final_text.c_str());
-    // Allocate memory for the C-style string
-    char* result = (char*)malloc(final_text.length() + 1); // +1 for the null terminator
-    if (result == nullptr) {
-        Log(ERROR, "Memory allocation failed.");
-        return nullptr;
     }
-
-    // Copy the contents of the std::string to the new char*
-    std::strcpy(result, final_text.c_str());
-    return result;
+    return 0;
 }
 
 
@@ -224,14 +275,8 @@ void free_processor(void* processor_ptr) {
 // Free the TokenizedTexts objects
 void free_tokenized_texts(TokenizedTexts* obj) {
     if (obj != nullptr) {
-        if (obj->newline_strs != nullptr) {
-            for (size_t i = 0; obj->newline_strs[i] != nullptr; ++i) {
-                free(obj->newline_strs[i]);
-            }
-            delete[] obj->newline_strs;
-            // delete static_cast<std::vector<std::string>*>(obj->newline_strs);
-            // obj->newline_strs = nullptr;
-        }
+        delete[] obj->text_groups;
+        delete[] obj->text_tokens;
         delete obj;
     }
 }